@@ -3,12 +3,18 @@ package main
 import (
 	"flag"
 	"fmt"
+	"github.com/blent/beagle/pkg/delivery"
+	"github.com/blent/beagle/pkg/discovery/peripherals"
+	"github.com/blent/beagle/pkg/monitoring/activity"
+	"github.com/blent/beagle/pkg/notification"
 	"github.com/blent/beagle/pkg/tracking"
 	"github.com/blent/beagle/server"
 	"github.com/blent/beagle/server/http"
 	"github.com/blent/beagle/server/storage"
 	"github.com/pkg/errors"
+	"io/ioutil"
 	"os"
+	"path/filepath"
 	"strings"
 	"time"
 )
@@ -22,7 +28,33 @@ var (
 	ErrStaticRoute              = errors.New("static route must be non-empty string")
 	ErrInvalidTtlDuration       = errors.New("ttl value must be greater than 0")
 	ErrInvalidHeartbeatInterval = errors.New("heartbeat value must be greater than 0")
+	ErrInvalidConfirmations     = errors.New("proximity confirmations value must be greater than 0")
+	ErrInvalidDeliveryEvents    = errors.New("delivery events value must be non-empty list")
+	ErrInvalidDeliveryAttempts  = errors.New("delivery attempts value must be greater than 0")
+	ErrInvalidResponseSize      = errors.New("max response size value must be greater than 0")
+	ErrInvalidDeliveryTimeout   = errors.New("delivery timeout value must not be negative")
+	ErrInvalidConnectTimeout    = errors.New("delivery connect timeout value must not be negative")
+	ErrInvalidMaxInFlight       = errors.New("delivery endpoint concurrency value must not be negative")
+	ErrInvalidRetryWorkers      = errors.New("delivery retry workers value must not be negative")
+	ErrInvalidAuditSample       = errors.New("delivery audit sample value must not be negative")
+	ErrInvalidAddressMode       = errors.New("delivery address value must be one of: plain, hash, omit")
+	ErrInvalidAddressSalt       = errors.New("delivery address salt value must be non-empty string for hashed addresses")
+	ErrInvalidQuietTimezone     = errors.New("delivery quiet timezone value must be a known timezone")
+	ErrInvalidDeliverySchemas   = errors.New("delivery schemas value must be a list of name=path pairs")
+	ErrInvalidProximityLabels   = errors.New("delivery proximity labels value must be a list of band=label pairs of bands: immediate, near, far, uknown")
+	ErrInvalidReconnectBackoff  = errors.New("delivery reconnect backoff values must be greater than 0")
+	ErrInvalidReconnectJitter   = errors.New("delivery reconnect jitter value must be between 0 and 1")
+	ErrInvalidPathLossExponent  = errors.New("tracking path loss exponent value must not be negative")
+	ErrInvalidMaxStaleness      = errors.New("delivery max staleness value must not be negative")
+	ErrInvalidCooldown          = errors.New("delivery cooldown value must not be negative")
+	ErrInvalidEndpointState     = errors.New("delivery endpoint state values must not be negative")
 	ErrInvalidStorageConnection = errors.New("storage connection value must be non-empty string")
+	ErrInvalidMaxRecords        = errors.New("activity max records value must not be negative")
+	ErrInvalidActivityTimezone  = errors.New("activity timezone value must be a known timezone")
+	ErrInvalidRenotifyInterval  = errors.New("delivery renotify interval value must not be negative")
+	ErrInvalidSnapshotInterval  = errors.New("delivery snapshot interval value must not be negative")
+	ErrInvalidWarmUpInterval    = errors.New("delivery warmup interval value must not be negative")
+	ErrInvalidFileRoot          = errors.New("delivery file root must be an absolute path")
 )
 
 var (
@@ -75,6 +107,196 @@ var (
 		int(DefaultSettings.Tracking.Heartbeat/time.Second),
 		"peripheral heartbeat interval in seconds",
 	)
+	trackingConfirmations = flag.Int(
+		"tracking-proximity-confirmations",
+		DefaultSettings.Tracking.ProximityConfirmations,
+		"number of consecutive readings in a new proximity band required to report a proximity change",
+	)
+	trackingPathLossExponent = flag.Float64(
+		"tracking-path-loss-exponent",
+		0,
+		"path loss exponent of the site estimating distances to peripherals by the log-distance model, 0 keeps the built-in estimation",
+	)
+	deliveryEvents = flag.String(
+		"delivery-events",
+		strings.Join(DefaultSettings.Delivery.EventNames, ","),
+		"comma separated list of delivered events",
+	)
+	deliveryAttempts = flag.Int(
+		"delivery-attempts",
+		DefaultSettings.Delivery.MaxAttempts,
+		"maximum number of delivery attempts per subscriber",
+	)
+	deliveryAuditFile = flag.String(
+		"delivery-audit-file",
+		"",
+		"file appending an audit record of every delivery attempt as a json line",
+	)
+	deliveryAuditSample = flag.Int(
+		"delivery-audit-sample",
+		DefaultSettings.Delivery.AuditSampleRate,
+		"keeps the request of every nth delivered attempt in the audit file, failed attempts always keep theirs, 0 disables sampling",
+	)
+	deliveryPendingDir = flag.String(
+		"delivery-pending-dir",
+		"",
+		"directory persisting pending delivery retries across restarts",
+	)
+	deliveryFileRoot = flag.String(
+		"delivery-file-root",
+		"",
+		"absolute path of the directory file:// endpoints write payloads under, file endpoints are disabled unless it is set",
+	)
+	deliveryMaxResponseSize = flag.Int64(
+		"delivery-max-response-size",
+		DefaultSettings.Delivery.MaxResponseSize,
+		"maximum size of an endpoint response body in bytes",
+	)
+	deliveryTimeout = flag.Int(
+		"delivery-timeout",
+		int(DefaultSettings.Delivery.Timeout/time.Second),
+		"default delivery timeout in seconds, 0 disables it",
+	)
+	deliveryConnectTimeout = flag.Int(
+		"delivery-connect-timeout",
+		int(DefaultSettings.Delivery.ConnectTimeout/time.Second),
+		"default timeout of establishing a connection in seconds, 0 disables it",
+	)
+	deliveryMaxStaleness = flag.Int(
+		"delivery-max-staleness",
+		int(DefaultSettings.Delivery.MaxStaleness/time.Second),
+		"drops deliveries of events detected longer ago in seconds, retries included, 0 disables it",
+	)
+	deliveryCooldown = flag.Int(
+		"delivery-cooldown",
+		int(DefaultSettings.Delivery.Cooldown/time.Second),
+		"minimum time between notifications about a single peripheral in seconds, whatever their event, 0 disables it",
+	)
+	deliveryMaxInFlight = flag.Int(
+		"delivery-endpoint-concurrency",
+		DefaultSettings.Delivery.MaxInFlight,
+		"maximum number of concurrent requests to a single endpoint, 0 disables the limit",
+	)
+	deliveryRetryWorkers = flag.Int(
+		"delivery-retry-workers",
+		DefaultSettings.Delivery.RetryWorkers,
+		"maximum number of retry attempts made at once, apart from first attempts, 0 disables the limit",
+	)
+	deliveryEndpointStateTtl = flag.Int(
+		"delivery-endpoint-state-ttl",
+		int(DefaultSettings.Delivery.EndpointStateTTL/time.Second),
+		"forgets health and limits of endpoints unused for longer in seconds, 0 keeps them",
+	)
+	deliveryMaxEndpoints = flag.Int(
+		"delivery-max-endpoints",
+		DefaultSettings.Delivery.MaxTrackedEndpoints,
+		"maximum number of endpoints with tracked health and limits, the least recently used one is forgotten over it, 0 disables the limit",
+	)
+	deliveryDryRun = flag.Bool(
+		"delivery-dry-run",
+		DefaultSettings.Delivery.DryRun,
+		"logs notifications instead of delivering them",
+	)
+	deliveryDuplicates = flag.Bool(
+		"delivery-duplicates",
+		DefaultSettings.Delivery.Duplicates,
+		"delivers found events of peripherals which are already present",
+	)
+	deliveryUnregistered = flag.Bool(
+		"delivery-unregistered",
+		DefaultSettings.Delivery.Unregistered,
+		"delivers events of peripherals which are not registered to the default endpoints",
+	)
+	deliveryAddress = flag.String(
+		"delivery-address",
+		DefaultSettings.Delivery.AddressMode,
+		"peripheral address in payloads: plain, hash or omit",
+	)
+	deliveryAddressSalt = flag.String(
+		"delivery-address-salt",
+		DefaultSettings.Delivery.AddressSalt,
+		"secret key of hashed peripheral addresses, required by the hash address mode",
+	)
+	deliveryProximityLabels = flag.String(
+		"delivery-proximity-labels",
+		"",
+		"comma separated band=label pairs of custom labels of proximity bands sent along with the proximity",
+	)
+	deliveryStrict = flag.Bool(
+		"delivery-strict",
+		DefaultSettings.Delivery.Strict,
+		"fails deliveries of peripherals which cannot be fully serialized",
+	)
+	deliveryQuietHours = flag.String(
+		"delivery-quiet-hours",
+		"",
+		"comma separated daily windows like 22:00-07:00 when only priority subscribers are notified",
+	)
+	deliveryQuietTimezone = flag.String(
+		"delivery-quiet-timezone",
+		"UTC",
+		"timezone of the quiet hours windows",
+	)
+	deliveryReconnectBackoff = flag.Int(
+		"delivery-reconnect-backoff",
+		int(DefaultSettings.Delivery.Reconnect.Initial/time.Millisecond),
+		"delay of reconnecting a persistent connection after the first failed attempt in milliseconds, doubled for every next one",
+	)
+	deliveryReconnectMaxBackoff = flag.Int(
+		"delivery-reconnect-max-backoff",
+		int(DefaultSettings.Delivery.Reconnect.Max/time.Millisecond),
+		"maximum delay of reconnecting a persistent connection in milliseconds",
+	)
+	deliveryReconnectJitter = flag.Float64(
+		"delivery-reconnect-jitter",
+		DefaultSettings.Delivery.Reconnect.Jitter,
+		"fraction of the reconnect delay by which it is randomly changed",
+	)
+	deliveryConnectionEvents = flag.Bool(
+		"delivery-connection-events",
+		DefaultSettings.Delivery.ConnectionEvents,
+		"reports connection state changes of persistent connections as delivery events",
+	)
+	deliverySchemas = flag.String(
+		"delivery-schemas",
+		"",
+		"comma separated name=path pairs of json schemas endpoints can validate their payloads against",
+	)
+	deliveryRenotifyInterval = flag.Int(
+		"delivery-renotify-interval",
+		int(DefaultSettings.Renotify.Interval/time.Second),
+		"interval of present events of peripherals in seconds, 0 disables them unless endpoints set their own",
+	)
+	deliverySnapshotInterval = flag.Int(
+		"delivery-snapshot-interval",
+		int(DefaultSettings.Heartbeat.Interval/time.Second),
+		"interval of delivering all present peripherals at once to the snapshot urls in seconds, 0 disables it",
+	)
+	deliverySnapshotUrls = flag.String(
+		"delivery-snapshot-urls",
+		"",
+		"comma separated urls receiving snapshots of all present peripherals as json posts",
+	)
+	deliveryWarmUpInterval = flag.Int(
+		"delivery-warmup-interval",
+		int(DefaultSettings.WarmUp.Interval/time.Second),
+		"interval of probing the warmup urls and default endpoints in seconds to keep their connections open, 0 disables it",
+	)
+	deliveryWarmUpUrls = flag.String(
+		"delivery-warmup-urls",
+		"",
+		"comma separated urls of endpoints kept warm besides the default endpoints",
+	)
+	activityMaxRecords = flag.Int(
+		"activity-max-records",
+		DefaultSettings.Activity.MaxRecords,
+		"maximum number of monitored peripherals, the least recently seen one is evicted over it, 0 disables the limit",
+	)
+	activityTimezone = flag.String(
+		"activity-timezone",
+		"UTC",
+		"timezone of activity times in responses and exports, they are stored in UTC",
+	)
 	storageConnection = flag.String(
 		"storage-connection",
 		DefaultSettings.Storage.ConnectionString,
@@ -124,8 +346,262 @@ func setTrackingSettings(settings *tracking.Settings) error {
 		return ErrInvalidHeartbeatInterval
 	}
 
+	trackingConfirmationsVal := *trackingConfirmations
+
+	if trackingConfirmationsVal <= 0 {
+		return ErrInvalidConfirmations
+	}
+
 	settings.Ttl = time.Second * time.Duration(trackingTtlVal)
 	settings.Heartbeat = time.Second * time.Duration(trackingHeartbeat)
+	settings.ProximityConfirmations = trackingConfirmationsVal
+
+	return nil
+}
+
+func setDeliverySettings(settings *delivery.Settings) error {
+	events := make([]string, 0, 3)
+
+	for _, event := range strings.Split(*deliveryEvents, ",") {
+		event = strings.TrimSpace(event)
+
+		if event != "" {
+			events = append(events, event)
+		}
+	}
+
+	if len(events) == 0 {
+		return ErrInvalidDeliveryEvents
+	}
+
+	if *deliveryAttempts <= 0 {
+		return ErrInvalidDeliveryAttempts
+	}
+
+	if *deliveryMaxResponseSize <= 0 {
+		return ErrInvalidResponseSize
+	}
+
+	if *deliveryFileRoot != "" && !filepath.IsAbs(*deliveryFileRoot) {
+		return ErrInvalidFileRoot
+	}
+
+	if *deliveryTimeout < 0 {
+		return ErrInvalidDeliveryTimeout
+	}
+
+	if *deliveryConnectTimeout < 0 {
+		return ErrInvalidConnectTimeout
+	}
+
+	if *deliveryMaxStaleness < 0 {
+		return ErrInvalidMaxStaleness
+	}
+
+	if *deliveryCooldown < 0 {
+		return ErrInvalidCooldown
+	}
+
+	if *deliveryMaxInFlight < 0 {
+		return ErrInvalidMaxInFlight
+	}
+
+	if *deliveryRetryWorkers < 0 {
+		return ErrInvalidRetryWorkers
+	}
+
+	if *deliveryAuditSample < 0 {
+		return ErrInvalidAuditSample
+	}
+
+	if *deliveryEndpointStateTtl < 0 || *deliveryMaxEndpoints < 0 {
+		return ErrInvalidEndpointState
+	}
+
+	switch *deliveryAddress {
+	case delivery.ADDRESS_MODE_PLAIN, delivery.ADDRESS_MODE_OMIT:
+	case delivery.ADDRESS_MODE_HASH:
+		if *deliveryAddressSalt == "" {
+			return ErrInvalidAddressSalt
+		}
+	default:
+		return ErrInvalidAddressMode
+	}
+
+	settings.EventNames = events
+	settings.MaxAttempts = *deliveryAttempts
+	settings.MaxResponseSize = *deliveryMaxResponseSize
+	settings.FileRoot = *deliveryFileRoot
+	settings.Strict = *deliveryStrict
+	settings.DryRun = *deliveryDryRun
+	settings.Duplicates = *deliveryDuplicates
+	settings.Unregistered = *deliveryUnregistered
+	settings.AddressMode = *deliveryAddress
+	settings.AddressSalt = *deliveryAddressSalt
+	settings.Timeout = time.Second * time.Duration(*deliveryTimeout)
+	settings.ConnectTimeout = time.Second * time.Duration(*deliveryConnectTimeout)
+	settings.MaxInFlight = *deliveryMaxInFlight
+	settings.RetryWorkers = *deliveryRetryWorkers
+	settings.MaxStaleness = time.Second * time.Duration(*deliveryMaxStaleness)
+	settings.Cooldown = time.Second * time.Duration(*deliveryCooldown)
+	settings.EndpointStateTTL = time.Second * time.Duration(*deliveryEndpointStateTtl)
+	settings.MaxTrackedEndpoints = *deliveryMaxEndpoints
+
+	if err := setQuietHours(settings); err != nil {
+		return err
+	}
+
+	if err := setSchemas(settings); err != nil {
+		return err
+	}
+
+	if err := setProximityLabels(settings); err != nil {
+		return err
+	}
+
+	if err := setReconnectPolicy(settings); err != nil {
+		return err
+	}
+
+	if *deliveryAuditFile != "" {
+		sink, err := delivery.NewFileAuditSink(*deliveryAuditFile)
+
+		if err != nil {
+			return err
+		}
+
+		settings.AuditSink = sink
+		settings.AuditSampleRate = *deliveryAuditSample
+	}
+
+	if *deliveryPendingDir != "" {
+		store, err := delivery.NewFilePendingStore(*deliveryPendingDir)
+
+		if err != nil {
+			return err
+		}
+
+		settings.PendingStore = store
+	}
+
+	return nil
+}
+
+func setQuietHours(settings *delivery.Settings) error {
+	windows := make([]delivery.QuietWindow, 0, 2)
+
+	for _, value := range strings.Split(*deliveryQuietHours, ",") {
+		if strings.TrimSpace(value) == "" {
+			continue
+		}
+
+		window, err := delivery.ParseQuietWindow(value)
+
+		if err != nil {
+			return err
+		}
+
+		windows = append(windows, window)
+	}
+
+	if len(windows) == 0 {
+		return nil
+	}
+
+	location, err := time.LoadLocation(strings.TrimSpace(*deliveryQuietTimezone))
+
+	if err != nil {
+		return ErrInvalidQuietTimezone
+	}
+
+	settings.QuietHours = &delivery.QuietHours{
+		Windows:  windows,
+		Location: location,
+	}
+
+	return nil
+}
+
+func setReconnectPolicy(settings *delivery.Settings) error {
+	if *deliveryReconnectBackoff <= 0 || *deliveryReconnectMaxBackoff <= 0 {
+		return ErrInvalidReconnectBackoff
+	}
+
+	if *deliveryReconnectJitter < 0 || *deliveryReconnectJitter > 1 {
+		return ErrInvalidReconnectJitter
+	}
+
+	settings.Reconnect = &delivery.ReconnectPolicy{
+		Initial: time.Millisecond * time.Duration(*deliveryReconnectBackoff),
+		Max:     time.Millisecond * time.Duration(*deliveryReconnectMaxBackoff),
+		Jitter:  *deliveryReconnectJitter,
+	}
+	settings.ConnectionEvents = *deliveryConnectionEvents
+
+	return nil
+}
+
+// Schemas are compiled once on start, so an invalid one stops the application instead of failing deliveries
+func setSchemas(settings *delivery.Settings) error {
+	for _, value := range strings.Split(*deliverySchemas, ",") {
+		if strings.TrimSpace(value) == "" {
+			continue
+		}
+
+		pair := strings.SplitN(value, "=", 2)
+
+		if len(pair) != 2 || strings.TrimSpace(pair[0]) == "" || strings.TrimSpace(pair[1]) == "" {
+			return ErrInvalidDeliverySchemas
+		}
+
+		data, err := ioutil.ReadFile(strings.TrimSpace(pair[1]))
+
+		if err != nil {
+			return err
+		}
+
+		schema, err := delivery.CompileSchema(data)
+
+		if err != nil {
+			return errors.Wrap(err, pair[1])
+		}
+
+		if settings.Schemas == nil {
+			settings.Schemas = make(map[string]*delivery.Schema)
+		}
+
+		settings.Schemas[strings.TrimSpace(pair[0])] = schema
+	}
+
+	return nil
+}
+
+func setProximityLabels(settings *delivery.Settings) error {
+	for _, value := range strings.Split(*deliveryProximityLabels, ",") {
+		if strings.TrimSpace(value) == "" {
+			continue
+		}
+
+		pair := strings.SplitN(value, "=", 2)
+
+		if len(pair) != 2 || strings.TrimSpace(pair[1]) == "" {
+			return ErrInvalidProximityLabels
+		}
+
+		band := strings.TrimSpace(pair[0])
+
+		switch band {
+		case peripherals.PROXIMITY_IMMEDIATE, peripherals.PROXIMITY_NEAR, peripherals.PROXIMITY_FAR, peripherals.PROXIMITY_UKNOWN:
+		default:
+			return ErrInvalidProximityLabels
+		}
+
+		if settings.ProximityLabels == nil {
+			settings.ProximityLabels = make(map[string]string)
+		}
+
+		settings.ProximityLabels[band] = strings.TrimSpace(pair[1])
+	}
 
 	return nil
 }
@@ -140,6 +616,104 @@ func setStorageSettings(settings *storage.Settings) error {
 	return nil
 }
 
+func setActivitySettings(settings *activity.Settings) error {
+	if *activityMaxRecords < 0 {
+		return ErrInvalidMaxRecords
+	}
+
+	settings.MaxRecords = *activityMaxRecords
+
+	location, err := time.LoadLocation(strings.TrimSpace(*activityTimezone))
+
+	if err != nil {
+		return ErrInvalidActivityTimezone
+	}
+
+	settings.Location = location
+
+	return nil
+}
+
+func setRenotifySettings(settings *notification.RenotifierSettings) error {
+	if *deliveryRenotifyInterval < 0 {
+		return ErrInvalidRenotifyInterval
+	}
+
+	settings.Interval = time.Second * time.Duration(*deliveryRenotifyInterval)
+
+	return nil
+}
+
+func setHeartbeatSettings(settings *delivery.HeartbeatSettings) error {
+	if *deliverySnapshotInterval < 0 {
+		return ErrInvalidSnapshotInterval
+	}
+
+	endpoints := make([]*notification.Endpoint, 0, 2)
+
+	for _, value := range strings.Split(*deliverySnapshotUrls, ",") {
+		value = strings.TrimSpace(value)
+
+		if value == "" {
+			continue
+		}
+
+		// snapshots are json regardless of the default format
+		endpoints = append(endpoints, &notification.Endpoint{
+			Name:    value,
+			Url:     value,
+			Method:  "POST",
+			Options: notification.EndpointOptions{Serializer: delivery.FORMAT_JSON},
+		})
+	}
+
+	settings.Interval = time.Second * time.Duration(*deliverySnapshotInterval)
+	settings.Endpoints = endpoints
+
+	return nil
+}
+
+func setWarmUpSettings(settings *delivery.WarmUpSettings) error {
+	if *deliveryWarmUpInterval < 0 {
+		return ErrInvalidWarmUpInterval
+	}
+
+	endpoints := make([]*notification.Endpoint, 0, 2)
+
+	for _, value := range strings.Split(*deliveryWarmUpUrls, ",") {
+		value = strings.TrimSpace(value)
+
+		if value == "" {
+			continue
+		}
+
+		endpoints = append(endpoints, &notification.Endpoint{
+			Name:   value,
+			Url:    value,
+			Method: "GET",
+		})
+	}
+
+	settings.Interval = time.Second * time.Duration(*deliveryWarmUpInterval)
+	settings.Endpoints = endpoints
+
+	return nil
+}
+
+func setAccuracyCalculator(settings *server.Settings) error {
+	exponent := *trackingPathLossExponent
+
+	if exponent < 0 {
+		return ErrInvalidPathLossExponent
+	}
+
+	if exponent > 0 {
+		settings.AccuracyCalculator = peripherals.NewPathLossCalculator(exponent)
+	}
+
+	return nil
+}
+
 func createSettings() (*server.Settings, error) {
 	res := server.NewDefaultSettings()
 
@@ -158,10 +732,34 @@ func createSettings() (*server.Settings, error) {
 		return nil, err
 	}
 
+	if err := setAccuracyCalculator(res); err != nil {
+		return nil, err
+	}
+
 	if err := setStorageSettings(res.Storage); err != nil {
 		return nil, err
 	}
 
+	if err := setDeliverySettings(res.Delivery); err != nil {
+		return nil, err
+	}
+
+	if err := setActivitySettings(res.Activity); err != nil {
+		return nil, err
+	}
+
+	if err := setRenotifySettings(res.Renotify); err != nil {
+		return nil, err
+	}
+
+	if err := setHeartbeatSettings(res.Heartbeat); err != nil {
+		return nil, err
+	}
+
+	if err := setWarmUpSettings(res.WarmUp); err != nil {
+		return nil, err
+	}
+
 	return res, nil
 }
 
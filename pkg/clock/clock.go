@@ -0,0 +1,29 @@
+package clock
+
+import "time"
+
+type (
+	Timer interface {
+		Stop() bool
+	}
+
+	// Clock abstracts the time source, so time dependent code can be tested without sleeps
+	Clock interface {
+		Now() time.Time
+		AfterFunc(d time.Duration, f func()) Timer
+	}
+
+	RealClock struct{}
+)
+
+func New() *RealClock {
+	return &RealClock{}
+}
+
+func (c *RealClock) Now() time.Time {
+	return time.Now()
+}
+
+func (c *RealClock) AfterFunc(d time.Duration, f func()) Timer {
+	return time.AfterFunc(d, f)
+}
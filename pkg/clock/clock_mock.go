@@ -0,0 +1,101 @@
+package clock
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+type (
+	// MockClock only moves forward by Add, due timers run in the calling goroutine
+	MockClock struct {
+		mu     sync.Mutex
+		now    time.Time
+		timers []*MockTimer
+	}
+
+	MockTimer struct {
+		clock   *MockClock
+		when    time.Time
+		fn      func()
+		stopped bool
+	}
+)
+
+func NewMockClock(now time.Time) *MockClock {
+	return &MockClock{now: now}
+}
+
+func (c *MockClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.now
+}
+
+func (c *MockClock) AfterFunc(d time.Duration, f func()) Timer {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	timer := &MockTimer{clock: c, when: c.now.Add(d), fn: f}
+
+	c.timers = append(c.timers, timer)
+
+	return timer
+}
+
+// Add moves the clock forward and runs the timers which became due
+func (c *MockClock) Add(d time.Duration) {
+	c.mu.Lock()
+	c.now = c.now.Add(d)
+	c.mu.Unlock()
+
+	for {
+		timer := c.nextDue()
+
+		if timer == nil {
+			return
+		}
+
+		timer.fn()
+	}
+}
+
+func (c *MockClock) nextDue() *MockTimer {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	active := c.timers[:0]
+
+	for _, timer := range c.timers {
+		if !timer.stopped {
+			active = append(active, timer)
+		}
+	}
+
+	c.timers = active
+
+	sort.SliceStable(c.timers, func(i, j int) bool {
+		return c.timers[i].when.Before(c.timers[j].when)
+	})
+
+	if len(c.timers) == 0 || c.timers[0].when.After(c.now) {
+		return nil
+	}
+
+	timer := c.timers[0]
+	timer.stopped = true
+	c.timers = c.timers[1:]
+
+	return timer
+}
+
+func (t *MockTimer) Stop() bool {
+	t.clock.mu.Lock()
+	defer t.clock.mu.Unlock()
+
+	active := !t.stopped
+	t.stopped = true
+
+	return active
+}
@@ -0,0 +1,190 @@
+package delivery
+
+import (
+	"encoding/json"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+	"net/http"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Outcomes of audited delivery attempts
+const (
+	AUDIT_OUTCOME_DELIVERED = "delivered"
+	AUDIT_OUTCOME_FAILED    = "failed"
+)
+
+// Value of credential headers in audited requests, such headers are dropped by a replay
+const AUDIT_REDACTED = "[redacted]"
+
+// Headers carrying credentials, which are not written to the audit trail
+var auditRedactedHeaders = map[string]bool{
+	"Authorization":       true,
+	"Proxy-Authorization": true,
+	"Cookie":              true,
+	"X-Api-Key":           true,
+	"X-Auth-Token":        true,
+}
+
+type (
+	// AuditRecord describes a single delivery attempt, retries and attempts of dry runs included
+	AuditRecord struct {
+		Timestamp time.Time `json:"timestamp"`
+		// Name of the event, e.g. found, or EVENT_BATCH for retries of batches
+		Event         string `json:"event"`
+		Target        string `json:"target"`
+		Subscriber    string `json:"subscriber"`
+		Endpoint      string `json:"endpoint"`
+		Url           string `json:"url"`
+		Attempt       int    `json:"attempt"`
+		CorrelationId string `json:"correlationId,omitempty"`
+		// One of AUDIT_OUTCOME_* constants
+		Outcome string `json:"outcome"`
+		// Response status of a failed attempt, 0 if the endpoint did not respond with an error status
+		Status int `json:"status,omitempty"`
+		// Category of the error, one of ERROR_CATEGORY_* constants
+		Category   string `json:"category,omitempty"`
+		Error      string `json:"error,omitempty"`
+		DryRun     bool   `json:"dryRun,omitempty"`
+		DurationMs int64  `json:"durationMs"`
+		// The attempt re-submitted the request of another record
+		Replayed bool `json:"replayed,omitempty"`
+		// Request of a failed attempt as it was built before the request hook, nil for delivered ones unless sampled.
+		// Values of credential headers are replaced by AUDIT_REDACTED.
+		Request *AuditRequest `json:"request,omitempty"`
+		// The request of a delivered attempt is kept by AuditSampleRate of the settings
+		Sampled bool `json:"sampled,omitempty"`
+	}
+
+	// AuditRequest is everything needed to send the request of a failed attempt again
+	AuditRequest struct {
+		Method string      `json:"method"`
+		Url    string      `json:"url"`
+		Header http.Header `json:"header"`
+		Body   []byte      `json:"body"`
+	}
+
+	// AuditSink keeps an audit trail of delivery attempts, independent of the logger and the stats
+	AuditSink interface {
+		Write(record *AuditRecord) error
+	}
+
+	// FileAuditSink appends records to a file as JSON lines, it never truncates or rewrites the file
+	FileAuditSink struct {
+		mu   sync.Mutex
+		file *os.File
+	}
+)
+
+func NewFileAuditSink(path string) (*FileAuditSink, error) {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &FileAuditSink{file: file}, nil
+}
+
+func (sink *FileAuditSink) Write(record *AuditRecord) error {
+	data, err := json.Marshal(record)
+
+	if err != nil {
+		return err
+	}
+
+	sink.mu.Lock()
+	defer sink.mu.Unlock()
+
+	// a record is written at once, so a crash leaves at most the last line incomplete
+	_, err = sink.file.Write(append(data, '\n'))
+
+	return err
+}
+
+func (sink *FileAuditSink) Close() error {
+	sink.mu.Lock()
+	defer sink.mu.Unlock()
+
+	return sink.file.Close()
+}
+
+// Writes an audit record of an event describing a delivery attempt, other events are ignored
+func (sender *Sender) audit(evt *Event) {
+	if sender.settings.AuditSink == nil || evt.Attempt == 0 {
+		return
+	}
+
+	record := &AuditRecord{
+		Timestamp:     evt.Timestamp.UTC(),
+		Event:         evt.Name,
+		Target:        evt.TargetName,
+		Attempt:       evt.Attempt,
+		CorrelationId: evt.CorrelationId,
+		Outcome:       AUDIT_OUTCOME_DELIVERED,
+		DryRun:        evt.DryRun,
+		DurationMs:    int64(evt.Duration / time.Millisecond),
+		Replayed:      evt.Replayed,
+	}
+
+	if evt.Subscriber != nil {
+		record.Subscriber = evt.Subscriber.Name
+
+		if evt.Subscriber.Endpoint != nil {
+			record.Endpoint = evt.Subscriber.Endpoint.Name
+			record.Url = evt.Subscriber.Endpoint.Url
+		}
+	}
+
+	if evt.Error != nil {
+		record.Outcome = AUDIT_OUTCOME_FAILED
+		record.Category = evt.Category
+		record.Error = evt.Error.Error()
+		record.Request = auditRequest(evt.request)
+
+		var deliveryErr *DeliveryError
+
+		if errors.As(evt.Error, &deliveryErr) {
+			record.Status = deliveryErr.StatusCode
+		}
+	} else if rate := sender.settings.AuditSampleRate; rate > 0 && atomic.AddUint64(&sender.audited, 1)%uint64(rate) == 0 {
+		record.Request = auditRequest(evt.request)
+		record.Sampled = record.Request != nil
+	}
+
+	if err := sender.settings.AuditSink.Write(record); err != nil {
+		sender.logger.Error(
+			"Failed to write an audit record",
+			zap.String("correlation id", evt.CorrelationId),
+			zap.Error(err),
+		)
+	}
+}
+
+func auditRequest(pending *Pending) *AuditRequest {
+	if pending == nil {
+		return nil
+	}
+
+	return &AuditRequest{
+		Method: pending.Method,
+		Url:    pending.Url,
+		Header: redactHeader(pending.Header),
+		Body:   pending.Body,
+	}
+}
+
+func redactHeader(header http.Header) http.Header {
+	redacted := header.Clone()
+
+	for name := range redacted {
+		if auditRedactedHeaders[http.CanonicalHeaderKey(name)] {
+			redacted[name] = []string{AUDIT_REDACTED}
+		}
+	}
+
+	return redacted
+}
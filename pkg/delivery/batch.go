@@ -0,0 +1,294 @@
+package delivery
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/blent/beagle/pkg/clock"
+	"github.com/blent/beagle/pkg/notification"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+	"net/http"
+	"time"
+)
+
+// Name of events reporting retries of failed batches.
+// Messages collected into a batch get their own events once the batch is sent.
+const EVENT_BATCH = "batch"
+
+const (
+	// Upper bound of options.batchInterval in milliseconds
+	MAX_BATCH_INTERVAL = uint64(time.Hour / time.Millisecond)
+	// Upper bound of the items allocated for a new batch, larger batches grow as messages are collected
+	maxBatchCapacity = 100
+)
+
+type (
+	batchItem struct {
+		msg        *notification.Message
+		subscriber *notification.Subscriber
+		body       []byte
+	}
+
+	// Messages collected for an endpoint with options.batchInterval, sent as a single JSON array.
+	// Retries are made on behalf of the subscriber of the first message.
+	endpointBatch struct {
+		key        string
+		subscriber *notification.Subscriber
+		url        string
+		header     http.Header
+		items      []batchItem
+		timer      clock.Timer
+	}
+)
+
+// Returned by sendSingle for messages collected into a batch, their events are emitted when the batch is sent
+var errBatched = errors.New("delivery batched")
+
+func isBatched(endpoint *notification.Endpoint) bool {
+	return endpoint.Options.BatchInterval > 0
+}
+
+// ValidateBatch checks that the batch size of the endpoint is not negative and its batch interval is at most MAX_BATCH_INTERVAL
+func ValidateBatch(endpoint *notification.Endpoint) error {
+	options := endpoint.Options
+
+	if options.BatchSize < 0 {
+		return errors.Wrapf(ErrInvalidBatch, "size %d", options.BatchSize)
+	}
+
+	if options.BatchInterval > MAX_BATCH_INTERVAL {
+		return errors.Wrapf(ErrInvalidBatch, "interval of %d ms over %d ms", options.BatchInterval, MAX_BATCH_INTERVAL)
+	}
+
+	return nil
+}
+
+// Number of items allocated for a new batch of the endpoint
+func batchCapacity(endpoint *notification.Endpoint) int {
+	size := endpoint.Options.BatchSize
+
+	if size <= 0 || size > maxBatchCapacity {
+		return maxBatchCapacity
+	}
+
+	return size
+}
+
+// Adds the message to the batch of the endpoint, the batch is sent when its interval elapses or it reaches options.batchSize.
+// The url and headers of the batch request are those of its first message.
+func (sender *Sender) addToBatch(msg *notification.Message, subscriber *notification.Subscriber) error {
+	endpoint := subscriber.Endpoint
+	pending, err := sender.prepareRequest(msg, subscriber, nil)
+
+	if err != nil {
+		return newDeliveryError(subscriber, 1, err)
+	}
+
+	if pending.Method != http.MethodPost || !json.Valid(pending.Body) {
+		return newDeliveryError(subscriber, 1, errors.Wrap(ErrUnsupportedBatch, endpoint.Name))
+	}
+
+	// a message over the limit on its own would fail the whole batch
+	if err := checkBodySize(endpoint, pending.Body); err != nil {
+		return newDeliveryError(subscriber, 1, err)
+	}
+
+	key := endpoint.Name + "|" + endpoint.Url
+
+	sender.batchMu.Lock()
+	batch, found := sender.pendingBatches[key]
+
+	if !found {
+		header := pending.Header.Clone()
+		header.Del(CORRELATION_ID_HEADER)
+		header.Set("Content-Type", CONTENT_TYPE_JSON)
+
+		batch = &endpointBatch{
+			key:        key,
+			subscriber: subscriber,
+			url:        pending.Url,
+			header:     header,
+			items:      make([]batchItem, 0, batchCapacity(endpoint)),
+		}
+
+		sender.pendingBatches[key] = batch
+
+		batch.timer = sender.clock.AfterFunc(time.Duration(endpoint.Options.BatchInterval)*time.Millisecond, func() {
+			if sender.takeBatch(batch) {
+				defer sender.batchWg.Done()
+
+				sender.sendEndpointBatch(batch)
+			}
+		})
+	}
+
+	batch.items = append(batch.items, batchItem{msg, subscriber, pending.Body})
+	full := endpoint.Options.BatchSize > 0 && len(batch.items) >= endpoint.Options.BatchSize
+
+	// a full batch is sent apart from the worker, as its timer would do
+	if full {
+		batch.timer.Stop()
+		delete(sender.pendingBatches, key)
+		sender.batchWg.Add(1)
+	}
+
+	sender.batchMu.Unlock()
+
+	if full {
+		go func() {
+			defer sender.batchWg.Done()
+
+			sender.sendEndpointBatch(batch)
+		}()
+	}
+
+	return errBatched
+}
+
+// Takes the batch out of the collected ones, false if it was already taken by another flush
+func (sender *Sender) takeBatch(batch *endpointBatch) bool {
+	sender.batchMu.Lock()
+	defer sender.batchMu.Unlock()
+
+	if sender.pendingBatches[batch.key] != batch {
+		return false
+	}
+
+	delete(sender.pendingBatches, batch.key)
+	sender.batchWg.Add(1)
+
+	return true
+}
+
+// Sends all collected batches right away and waits for batches being sent by their timers
+func (sender *Sender) flushBatches() {
+	sender.batchMu.Lock()
+	batches := make([]*endpointBatch, 0, len(sender.pendingBatches))
+
+	for key, batch := range sender.pendingBatches {
+		batch.timer.Stop()
+		delete(sender.pendingBatches, key)
+		batches = append(batches, batch)
+	}
+
+	sender.batchMu.Unlock()
+
+	for _, batch := range batches {
+		sender.sendEndpointBatch(batch)
+	}
+
+	sender.batchWg.Wait()
+}
+
+func (sender *Sender) sendEndpointBatch(batch *endpointBatch) {
+	var body bytes.Buffer
+
+	detectedAt := time.Time{}
+
+	body.WriteByte('[')
+
+	for idx, item := range batch.items {
+		if idx > 0 {
+			body.WriteByte(',')
+		}
+
+		body.Write(item.body)
+
+		// the oldest detection bounds the staleness of retries
+		if detected := item.msg.DetectedAt(); !detected.IsZero() && (detectedAt.IsZero() || detected.Before(detectedAt)) {
+			detectedAt = detected
+		}
+	}
+
+	body.WriteByte(']')
+
+	subscriber := batch.subscriber
+
+	pending := &Pending{
+		EventName:  EVENT_BATCH,
+		TargetName: subscriber.Endpoint.Name,
+		Subscriber: subscriber,
+		Method:     http.MethodPost,
+		Url:        batch.url,
+		Header:     batch.header,
+		Body:       body.Bytes(),
+		Attempt:    1,
+		DetectedAt: detectedAt,
+	}
+
+	start := sender.clock.Now()
+	req, err := pending.request()
+	// a batch which cannot be built, e.g. over the body size limit, would fail every retry too
+	built := err == nil
+
+	if built {
+		err = sender.do(req, subscriber.Endpoint)
+	}
+
+	duration := sender.clock.Now().Sub(start)
+	retrying := false
+
+	if err != nil && built && sender.settings.MaxAttempts > 1 {
+		id, idErr := notification.GenerateId()
+
+		if idErr == nil {
+			pending.Id = id
+			retrying = sender.retry(pending, err)
+		}
+	}
+
+	if err == nil {
+		sender.logger.Info(
+			"Succeeded to send a batch to an endpoint",
+			zap.String("endpoint name", subscriber.Endpoint.Name),
+			zap.Int("size", len(batch.items)),
+		)
+	} else {
+		sender.logger.Info(
+			"Failed to send a batch to an endpoint",
+			zap.String("endpoint name", subscriber.Endpoint.Name),
+			zap.Int("size", len(batch.items)),
+			zap.String("reason", categorizeError(err)),
+			zap.Error(err),
+		)
+	}
+
+	events := make([]*Event, len(batch.items))
+
+	for idx, item := range batch.items {
+		itemErr := newDeliveryError(item.subscriber, 1, err)
+
+		events[idx] = &Event{
+			Name:          item.msg.EventName(),
+			Timestamp:     sender.clock.Now(),
+			TargetName:    item.msg.TargetName(),
+			Subscriber:    item.subscriber,
+			Delivered:     err == nil,
+			Error:         itemErr,
+			Category:      categorizeError(err),
+			DryRun:        sender.settings.DryRun,
+			Duration:      duration,
+			Latency:       sender.latency(item.msg.DetectedAt(), err),
+			Attempt:       1,
+			WillRetry:     retrying,
+			CorrelationId: item.msg.CorrelationId(),
+		}
+
+		// a message of a failed batch is replayed as a batch of its own, a delivered one may be sampled by the audit
+		if err != nil || sender.settings.AuditSampleRate > 0 {
+			events[idx].request = &Pending{
+				EventName:     item.msg.EventName(),
+				TargetName:    item.msg.TargetName(),
+				Subscriber:    item.subscriber,
+				Method:        http.MethodPost,
+				Url:           batch.url,
+				Header:        batch.header,
+				Body:          append(append([]byte{'['}, item.body...), ']'),
+				CorrelationId: item.msg.CorrelationId(),
+				DetectedAt:    item.msg.DetectedAt(),
+			}
+		}
+	}
+
+	sender.emit(events)
+}
@@ -0,0 +1,106 @@
+package delivery
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"github.com/pkg/errors"
+	"net"
+	"syscall"
+)
+
+const (
+	ERROR_CATEGORY_TIMEOUT = "timeout"
+	ERROR_CATEGORY_DNS     = "dns"
+	ERROR_CATEGORY_REFUSED = "refused"
+	ERROR_CATEGORY_TLS     = "tls"
+	// The message could not be turned into a request, a data problem
+	ERROR_CATEGORY_SERIALIZATION = "serialization"
+	// The endpoint cannot be reached as configured, e.g. by an unsupported scheme
+	ERROR_CATEGORY_CONFIG = "config"
+	// A persistent connection is lost or waits to be reestablished
+	ERROR_CATEGORY_DISCONNECTED = "disconnected"
+	// The receiver does not keep up with the messages
+	ERROR_CATEGORY_BACKPRESSURE = "backpressure"
+	ERROR_CATEGORY_OTHER        = "other"
+)
+
+// CategorizeError tells the category of an error of a delivery or a probe, one of ERROR_CATEGORY_* constants
+func CategorizeError(err error) string {
+	return categorizeError(err)
+}
+
+func categorizeError(err error) string {
+	if err == nil {
+		return ""
+	}
+
+	if errors.Is(err, ErrMissedPeripheral) || errors.Is(err, ErrUnableToSerializePeripheral) {
+		return ERROR_CATEGORY_SERIALIZATION
+	}
+
+	if errors.Is(err, ErrUnsupportedScheme) ||
+		errors.Is(err, ErrMissedTransport) ||
+		errors.Is(err, ErrMissingEnvVariable) ||
+		errors.Is(err, ErrForbiddenEnvVariable) ||
+		errors.Is(err, ErrInvalidHeaderName) ||
+		errors.Is(err, ErrInvalidHeaderValue) ||
+		errors.Is(err, ErrUnknownSchema) ||
+		errors.Is(err, ErrSchemaViolation) ||
+		errors.Is(err, ErrUnsupportedSchema) ||
+		errors.Is(err, ErrUnsupportedBatch) ||
+		errors.Is(err, ErrInvalidBatch) ||
+		errors.Is(err, ErrUnsupportedProbe) ||
+		errors.Is(err, ErrUnsupportedSnapshot) ||
+		errors.Is(err, ErrPayloadTooLarge) ||
+		errors.Is(err, ErrUnsupportedValue) ||
+		errors.Is(err, ErrInvalidFileUrl) ||
+		errors.Is(err, ErrInvalidBalanceTarget) ||
+		errors.Is(err, ErrInvalidCondition) ||
+		errors.Is(err, ErrFieldCollision) ||
+		errors.Is(err, ErrMissingAddressSalt) {
+		return ERROR_CATEGORY_CONFIG
+	}
+
+	if errors.Is(err, ErrWebSocketUnavailable) || errors.Is(err, ErrWebSocketConnectionLost) {
+		return ERROR_CATEGORY_DISCONNECTED
+	}
+
+	if errors.Is(err, ErrWebSocketBackpressure) {
+		return ERROR_CATEGORY_BACKPRESSURE
+	}
+
+	var dnsErr *net.DNSError
+
+	if errors.As(err, &dnsErr) {
+		return ERROR_CATEGORY_DNS
+	}
+
+	var netErr net.Error
+
+	if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, ErrStaleDelivery) || (errors.As(err, &netErr) && netErr.Timeout()) {
+		return ERROR_CATEGORY_TIMEOUT
+	}
+
+	if errors.Is(err, syscall.ECONNREFUSED) {
+		return ERROR_CATEGORY_REFUSED
+	}
+
+	if isTlsError(err) {
+		return ERROR_CATEGORY_TLS
+	}
+
+	return ERROR_CATEGORY_OTHER
+}
+
+func isTlsError(err error) bool {
+	var recordErr tls.RecordHeaderError
+	var authorityErr x509.UnknownAuthorityError
+	var hostnameErr x509.HostnameError
+	var invalidErr x509.CertificateInvalidError
+
+	return errors.As(err, &recordErr) ||
+		errors.As(err, &authorityErr) ||
+		errors.As(err, &hostnameErr) ||
+		errors.As(err, &invalidErr)
+}
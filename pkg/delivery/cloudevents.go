@@ -0,0 +1,67 @@
+package delivery
+
+import (
+	"encoding/json"
+	"github.com/blent/beagle/pkg/clock"
+	"github.com/blent/beagle/pkg/discovery/peripherals"
+	"github.com/blent/beagle/pkg/notification"
+	"time"
+)
+
+const (
+	cloudEventsSpecVersion = "1.0"
+	cloudEventsTypePrefix  = "com.beagle.peripheral."
+)
+
+type (
+	CloudEvent struct {
+		SpecVersion     string      `json:"specversion"`
+		Type            string      `json:"type"`
+		Source          string      `json:"source"`
+		Id              string      `json:"id"`
+		Time            time.Time   `json:"time"`
+		DataContentType string      `json:"datacontenttype"`
+		Data            interface{} `json:"data"`
+	}
+
+	// CloudEventsSerializer wraps serialized peripherals into a CloudEvents envelope
+	CloudEventsSerializer struct {
+		source string
+		clock  clock.Clock
+	}
+)
+
+func NewCloudEventsSerializer(source string, clock clock.Clock) *CloudEventsSerializer {
+	return &CloudEventsSerializer{source, clock}
+}
+
+func (s *CloudEventsSerializer) Serialize(name string, peripheral peripherals.Peripheral, event string) ([]byte, string, error) {
+	fields, err := peripheralFields(name, peripheral, event)
+
+	if err != nil {
+		return nil, "", err
+	}
+
+	return s.SerializeFields(event, fields)
+}
+
+// The id is generated once per delivery, so that every retry of the request carries the same one.
+func (s *CloudEventsSerializer) SerializeFields(event string, fields map[string]interface{}) ([]byte, string, error) {
+	id, err := notification.GenerateId()
+
+	if err != nil {
+		return nil, "", err
+	}
+
+	body, err := json.Marshal(&CloudEvent{
+		SpecVersion:     cloudEventsSpecVersion,
+		Type:            cloudEventsTypePrefix + event,
+		Source:          s.source,
+		Id:              id,
+		Time:            s.clock.Now().UTC(),
+		DataContentType: CONTENT_TYPE_JSON,
+		Data:            fields,
+	})
+
+	return body, CONTENT_TYPE_CLOUDEVENTS, err
+}
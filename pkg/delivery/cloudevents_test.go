@@ -0,0 +1,88 @@
+package delivery_test
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/blent/beagle/pkg/clock"
+	"github.com/blent/beagle/pkg/delivery"
+	"github.com/blent/beagle/pkg/notification"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"io/ioutil"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestSenderCloudEvents(t *testing.T) {
+	now := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	mockClock := clock.NewMockClock(now)
+	events := make([]*delivery.CloudEvent, 0, 2)
+	contentTypes := make([]string, 0, 2)
+
+	transport := delivery.NewMockTransport(func(req *http.Request) error {
+		body, err := ioutil.ReadAll(req.Body)
+
+		if err != nil {
+			return err
+		}
+
+		evt := &delivery.CloudEvent{}
+
+		if err := json.Unmarshal(body, evt); err != nil {
+			return err
+		}
+
+		events = append(events, evt)
+		contentTypes = append(contentTypes, req.Header.Get("Content-Type"))
+
+		// the first attempt fails to check the id of the retry
+		if len(events) == 1 {
+			return errors.New("unavailable")
+		}
+
+		return nil
+	})
+
+	settings := delivery.NewDefaultSettings()
+	settings.Synchronous = true
+	settings.Format = delivery.FORMAT_CLOUDEVENTS
+	settings.EventSource = "urn:beagle:test"
+	settings.MaxAttempts = 2
+	settings.RetryBackoff = time.Second
+	settings.Clock = mockClock
+
+	sender := delivery.NewWithSettings(zap.NewNop(), transport, settings)
+	defer sender.Close()
+
+	assert.NoError(t, sender.Send(notification.NewMessage(
+		notification.FOUND,
+		"entrance",
+		createPeripheral(),
+		[]*notification.Subscriber{createSubscriber()},
+	)), "send error")
+
+	mockClock.Add(time.Second)
+
+	if !assert.Len(t, events, 2, "attempts") {
+		return
+	}
+
+	evt := events[0]
+
+	assert.Equal(t, delivery.CONTENT_TYPE_CLOUDEVENTS, contentTypes[0], "content type")
+	assert.Equal(t, "1.0", evt.SpecVersion, "spec version")
+	assert.Equal(t, "com.beagle.peripheral."+notification.FOUND, evt.Type, "type")
+	assert.Equal(t, "urn:beagle:test", evt.Source, "source")
+	assert.True(t, now.Equal(evt.Time), "time")
+	assert.Equal(t, delivery.CONTENT_TYPE_JSON, evt.DataContentType, "data content type")
+	assert.NotEmpty(t, evt.Id, "id")
+
+	data, ok := evt.Data.(map[string]interface{})
+
+	if assert.True(t, ok, "data") {
+		assert.Equal(t, "entrance", data[delivery.FIELD_NAME], "data")
+	}
+
+	assert.Equal(t, evt.Id, events[1].Id, "id of the retry")
+}
@@ -0,0 +1,136 @@
+package delivery
+
+import (
+	"github.com/antonmedv/expr"
+	"github.com/antonmedv/expr/vm"
+	"github.com/blent/beagle/pkg/notification"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+	"math"
+	"strconv"
+)
+
+// Limit of compiled conditions kept by a sender, an arbitrary one is dropped for a new one once it is reached
+const MAX_CACHED_CONDITIONS = 1000
+
+// Compiles an expression over serialized fields, e.g. proximity == "immediate" && major == 1, by the expr language.
+// Fields are referred to by their names before renaming, entries of nested fields by dots, e.g. metadata.floor,
+// missing fields are null.
+func compileCondition(expression string) (*vm.Program, error) {
+	program, err := expr.Compile(expression, expr.AllowUndefinedVariables(), expr.AsBool())
+
+	if err != nil {
+		return nil, errors.Wrapf(ErrInvalidCondition, "%s: %s", expression, err)
+	}
+
+	return program, nil
+}
+
+// Returns the compiled condition of the sender cache, compiling it on a miss
+func (sender *Sender) condition(expression string) (*vm.Program, error) {
+	sender.conditionsMu.Lock()
+	program, found := sender.conditions[expression]
+	sender.conditionsMu.Unlock()
+
+	if found {
+		return program, nil
+	}
+
+	program, err := compileCondition(expression)
+
+	if err != nil {
+		return nil, err
+	}
+
+	sender.conditionsMu.Lock()
+	defer sender.conditionsMu.Unlock()
+
+	if len(sender.conditions) >= MAX_CACHED_CONDITIONS {
+		for cached := range sender.conditions {
+			delete(sender.conditions, cached)
+			break
+		}
+	}
+
+	sender.conditions[expression] = program
+
+	return program, nil
+}
+
+// Whether the serialized fields of the peripheral meet the condition of the endpoint
+func (sender *Sender) matchesCondition(msg *notification.Message, endpoint *notification.Endpoint) (bool, error) {
+	if endpoint.Options.Condition == "" {
+		return true, nil
+	}
+
+	program, err := sender.condition(endpoint.Options.Condition)
+
+	if err != nil {
+		return false, err
+	}
+
+	fields, err := sender.serializePeripheral(msg)
+
+	// nothing to match against, the delivery fails on serialization instead
+	if err != nil {
+		return true, nil
+	}
+
+	result, err := expr.Run(program, conditionEnv(fields))
+
+	// values of different types have no order, e.g. a missing field compared with a number
+	if err != nil {
+		sender.logger.Debug(
+			"Condition is not met for lack of comparable values",
+			zap.String("endpoint name", endpoint.Name),
+			zap.String("condition", endpoint.Options.Condition),
+			zap.Error(err),
+		)
+
+		return false, nil
+	}
+
+	return result == true, nil
+}
+
+// Serialized values are strings, so the ones spelling numbers or booleans become numbers or booleans
+// and can be compared with literals, e.g. accuracy < 2.5 or registered == true
+func conditionEnv(fields map[string]interface{}) map[string]interface{} {
+	env := make(map[string]interface{}, len(fields)+2)
+
+	for key, value := range fields {
+		env[key] = conditionValue(value)
+	}
+
+	// entries of missing metadata are null as well
+	if _, found := env[FIELD_METADATA]; !found {
+		env[FIELD_METADATA] = map[string]interface{}{}
+	}
+
+	env["null"] = nil
+
+	return env
+}
+
+func conditionValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case string:
+		if number, err := strconv.ParseFloat(v, 64); err == nil && !math.IsInf(number, 0) && !math.IsNaN(number) {
+			return number
+		}
+
+		if v == "true" || v == "false" {
+			return v == "true"
+		}
+	case map[string]string:
+		entries := make(map[string]interface{}, len(v))
+
+		for key, entry := range v {
+			entries[key] = conditionValue(entry)
+		}
+
+		return entries
+	}
+
+	return value
+}
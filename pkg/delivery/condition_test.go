@@ -0,0 +1,130 @@
+package delivery_test
+
+import (
+	"encoding/hex"
+	"github.com/blent/beagle/pkg/delivery"
+	"github.com/blent/beagle/pkg/discovery/peripherals"
+	"github.com/blent/beagle/pkg/notification"
+	"github.com/brianvoe/gofakeit"
+	"github.com/go-errors/errors"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"testing"
+)
+
+func TestSenderConditionRouting(t *testing.T) {
+	conditioned := func(condition string) *notification.Subscriber {
+		sub := createSubscriber()
+		sub.Endpoint.Options.Condition = condition
+
+		return sub
+	}
+
+	nearby := conditioned(`proximity == "near" && accuracy < 2.5`)
+	elsewhere := conditioned(`proximity in ["far", 'immediate']`)
+	upstairs := conditioned(`metadata.floor >= 3 || !(name != "test")`)
+	known := conditioned(`registered == true && missing == null && kind != null`)
+
+	for _, sub := range []*notification.Subscriber{nearby, elsewhere, upstairs, known} {
+		assert.NoError(t, delivery.ValidateRouting(sub.Endpoint), "valid condition")
+	}
+
+	for _, condition := range []string{`proximity ==`, `(name == "test"`, `name = "test"`, `name == "test`, `name in "test"`, `name == "a" "b"`} {
+		err := delivery.ValidateRouting(conditioned(condition).Endpoint)
+
+		assert.True(t, errors.Is(err, delivery.ErrInvalidCondition), "invalid condition "+condition)
+	}
+
+	transport := delivery.NewRecordingTransport()
+	sender := delivery.New(zap.NewNop(), transport, delivery.WithSynchronous())
+	defer sender.Close()
+
+	events := make(map[string]delivery.Event)
+
+	sender.AddEventListener(func(evt delivery.Event) {
+		events[evt.Subscriber.Name] = evt
+	})
+
+	invalid := conditioned(`proximity ==`)
+	peripheral := peripherals.NewMockPeripheral(gofakeit.UUID(), "mock", "test", nil, -59, -59, gofakeit.IPv4Address())
+
+	assert.NoError(t, sender.Send(notification.NewMessage(
+		notification.FOUND,
+		"test",
+		peripheral,
+		[]*notification.Subscriber{nearby, elsewhere, upstairs, known, invalid},
+	).SetRegistered(true).SetMetadata(map[string]string{"floor": "3"})), "send error")
+
+	assert.Len(t, transport.Requests(), 3, "requests")
+	assert.True(t, events[nearby.Name].Delivered, "proximity and distance")
+	assert.Equal(t, delivery.SKIP_REASON_CONDITION, events[elsewhere.Name].SkipReason, "other bands")
+	assert.True(t, events[upstairs.Name].Delivered, "metadata")
+	assert.True(t, events[known.Name].Delivered, "booleans and nulls")
+	assert.True(t, errors.Is(events[invalid.Name].Error, delivery.ErrInvalidCondition), "invalid condition")
+	assert.Equal(t, delivery.ERROR_CATEGORY_CONFIG, events[invalid.Name].Category, "invalid condition category")
+	assert.Equal(t, uint64(1), sender.Stats().Skipped[delivery.SKIP_REASON_CONDITION], "skipped count")
+}
+
+func TestSenderConditionValues(t *testing.T) {
+	conditioned := func(condition string) *notification.Subscriber {
+		sub := createSubscriber()
+		sub.Endpoint.Options.Condition = condition
+
+		return sub
+	}
+
+	exponent := conditioned(`accuracy > 1e-5 && accuracy < 2.5E+1`)
+	spelled := conditioned(`major == 1 && minor == 2`)
+	mismatched := conditioned(`name < 3`)
+	missing := conditioned(`metadata.floor == null && proximity != null`)
+	lost := conditioned(`accuracy < 2.5`)
+
+	for _, sub := range []*notification.Subscriber{exponent, spelled, mismatched, missing, lost} {
+		assert.NoError(t, delivery.ValidateRouting(sub.Endpoint), "valid condition "+sub.Endpoint.Options.Condition)
+	}
+
+	for _, condition := range []string{`name`, `1 + 2`, `name in "test"`} {
+		err := delivery.ValidateRouting(conditioned(condition).Endpoint)
+
+		assert.True(t, errors.Is(err, delivery.ErrInvalidCondition), "not a boolean condition "+condition)
+	}
+
+	transport := delivery.NewRecordingTransport()
+	sender := delivery.New(zap.NewNop(), transport, delivery.WithSynchronous(), delivery.WithDuplicates())
+	defer sender.Close()
+
+	events := make(map[string]delivery.Event)
+
+	sender.AddEventListener(func(evt delivery.Event) {
+		events[evt.Subscriber.Name] = evt
+	})
+
+	data, err := hex.DecodeString("4c000215" + "e2c56db5dffb48d2b060d0f5a71096e0" + "0001" + "0002" + "c5")
+
+	assert.NoError(t, err, "manufacturer data")
+
+	beacon, err := peripherals.NewIBeaconPeripheral("test", data, -59, -59, gofakeit.IPv4Address())
+
+	assert.NoError(t, err, "beacon")
+
+	assert.NoError(t, sender.Send(notification.NewMessage(
+		notification.FOUND,
+		"test",
+		beacon,
+		[]*notification.Subscriber{exponent, spelled, mismatched, missing},
+	)), "send error")
+
+	assert.True(t, events[exponent.Name].Delivered, "numbers with exponents")
+	assert.True(t, events[spelled.Name].Delivered, "numbers spelled by strings")
+	assert.Equal(t, delivery.SKIP_REASON_CONDITION, events[mismatched.Name].SkipReason, "values of different types have no order")
+	assert.True(t, events[missing.Name].Delivered, "entries of missing metadata")
+
+	assert.NoError(t, sender.Send(notification.NewMessage(
+		notification.LOST,
+		"test",
+		beacon,
+		[]*notification.Subscriber{lost},
+	)), "send error")
+
+	assert.Equal(t, delivery.SKIP_REASON_CONDITION, events[lost.Name].SkipReason, "missing accuracy of lost events")
+}
@@ -0,0 +1,91 @@
+package delivery
+
+import (
+	"github.com/blent/beagle/pkg/notification"
+	"time"
+)
+
+// Cooldown of a peripheral, guarded by the lock of the cooldowns
+type cooldownState struct {
+	// Start of the window, the time of the last message delivered outside of it
+	started time.Time
+	// Correlation id of the last message decided on and the decision, so other subscribers of the message get the same one
+	decided string
+	cooled  bool
+	// The last found event was cooled down, so the lost event ending its presence is cooled down as well
+	foundCooled bool
+}
+
+// Reports whether the peripheral of the message notified within the cooldown, otherwise the message starts a new one.
+// It is called once a delivery of the message is about to be attempted, so messages skipped by the filters of all
+// their endpoints do not start the cooldown. Lost events never start it either and are cooled down only along with
+// the found event whose presence they end, so subscribers never see a peripheral found twice or lost twice in a row.
+// Messages without a peripheral are never cooled down.
+func (sender *Sender) isCoolingDown(msg *notification.Message) bool {
+	cooldown := sender.settings.Cooldown
+	peripheral := msg.Peripheral()
+
+	if cooldown <= 0 || peripheral == nil {
+		return false
+	}
+
+	key := peripheral.UniqueKey()
+	now := sender.clock.Now()
+
+	sender.cooldownMu.Lock()
+	defer sender.cooldownMu.Unlock()
+
+	state, found := sender.cooldowns[key]
+
+	if found && state.decided == msg.CorrelationId() {
+		return state.cooled
+	}
+
+	if msg.EventName() == notification.LOST {
+		if !found {
+			return false
+		}
+
+		state.decided = msg.CorrelationId()
+		state.cooled = state.foundCooled
+		state.foundCooled = false
+
+		return state.cooled
+	}
+
+	if !found {
+		state = &cooldownState{}
+		sender.cooldowns[key] = state
+	}
+
+	state.decided = msg.CorrelationId()
+	state.cooled = !state.started.IsZero() && now.Sub(state.started) < cooldown
+
+	if !state.cooled {
+		state.started = now
+	}
+
+	if msg.EventName() == notification.FOUND {
+		state.foundCooled = state.cooled
+	}
+
+	sender.sweepCooldowns(now, cooldown)
+
+	return state.cooled
+}
+
+// Forgets peripherals whose cooldown is over, at most once per cooldown. Called with the lock held.
+// Peripherals whose found event was cooled down are kept until their lost event.
+func (sender *Sender) sweepCooldowns(now time.Time, cooldown time.Duration) {
+	if now.Sub(sender.cooldownSweep) < cooldown {
+		return
+	}
+
+	sender.cooldownSweep = now
+
+	for key, state := range sender.cooldowns {
+		if now.Sub(state.started) >= cooldown && !state.foundCooled {
+			delete(sender.cooldowns, key)
+		}
+	}
+}
@@ -0,0 +1,48 @@
+package delivery
+
+import (
+	"github.com/blent/beagle/pkg/notification"
+)
+
+// Key of default endpoints of peripherals of any kind without endpoints of their own
+const DEFAULT_KIND_ANY = "*"
+
+// Prefix of names of subscribers made up for default endpoints
+const DEFAULT_SUBSCRIBER_PREFIX = "default:"
+
+// Addresses a message without subscribers to the default endpoints of its peripheral kind,
+// messages with subscribers are returned as is
+func (sender *Sender) withDefaults(msg *notification.Message) *notification.Message {
+	if len(msg.Subscribers()) > 0 || len(sender.settings.DefaultEndpoints) == 0 || msg.Peripheral() == nil {
+		return msg
+	}
+
+	endpoints, found := sender.settings.DefaultEndpoints[msg.Peripheral().Kind()]
+
+	if !found {
+		endpoints = sender.settings.DefaultEndpoints[DEFAULT_KIND_ANY]
+	}
+
+	if len(endpoints) == 0 {
+		return msg
+	}
+
+	subscribers := make([]*notification.Subscriber, 0, len(endpoints))
+
+	for _, endpoint := range endpoints {
+		subscribers = append(subscribers, &notification.Subscriber{
+			Name:     DEFAULT_SUBSCRIBER_PREFIX + endpoint.Name,
+			Event:    msg.EventName(),
+			Endpoint: endpoint,
+			Enabled:  true,
+		})
+	}
+
+	return notification.NewMessage(msg.EventName(), msg.TargetName(), msg.Peripheral(), subscribers).
+		SetPreviousProximity(msg.PreviousProximity()).
+		SetCorrelationId(msg.CorrelationId()).
+		SetDetectedAt(msg.DetectedAt()).
+		SetRegistered(msg.Registered()).
+		SetMetadata(msg.Metadata()).
+		SetContext(msg.Context())
+}
@@ -2,21 +2,28 @@ package delivery
 
 import (
 	"bytes"
-	"encoding/json"
+	"container/list"
+	"context"
 	"fmt"
+	"github.com/antonmedv/expr/vm"
+	"github.com/blent/beagle/pkg/clock"
 	"github.com/blent/beagle/pkg/discovery/peripherals"
 	"github.com/blent/beagle/pkg/notification"
 	"github.com/pkg/errors"
 	"go.uber.org/zap"
-	"io/ioutil"
 	"net/http"
 	"net/url"
 	"reflect"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
+// Header of requests carrying the correlation id of the message
+const CORRELATION_ID_HEADER = "X-Correlation-Id"
+
 type (
 	Event struct {
 		Name       string
@@ -25,36 +32,303 @@ type (
 		Subscriber *notification.Subscriber
 		Delivered  bool
 		Error      error
+		// Category of the error, one of ERROR_CATEGORY_* constants
+		Category string
+		// Reason of a suppressed delivery, one of SKIP_REASON_* constants
+		SkipReason string
+		// The request was built but not sent
+		DryRun bool
+		// Time spent on the attempt
+		Duration time.Duration
+		// The message was refused by Send and delivered to nobody, Error tells why
+		Rejected bool
+		// Id tracing the event from its discovery, sent in the CORRELATION_ID_HEADER
+		CorrelationId string
+		// Time from the detection of the event to the successful delivery, 0 if either is unknown
+		Latency time.Duration
+		// Number of the delivery attempt, 0 for events of skipped or rejected deliveries and connection changes
+		Attempt int
+		// Another attempt of the failed delivery is scheduled, so the failure is not final yet.
+		// The next attempt emits an event of its own with Attempt increased by 1, the final failure has it false.
+		WillRetry bool
+		// Connection state change of a stateful transport, set only for EVENT_CONNECTION events
+		Connection *ConnectionChange
+		// The attempt re-submitted a request of an audit record, see Sender.Replay
+		Replayed bool
+		// Request of the attempt, written to audit records of failed attempts
+		request *Pending
 	}
 
 	EventListener func(evt Event)
 
+	// BatchEventListener gets all events of a message at once, in the order of its subscribers.
+	// A retry or a rejected message make a batch of a single event. The slice is shared by all batch listeners and must not be modified.
+	BatchEventListener func(events []Event)
+
+	scheduledRetry struct {
+		timer   clock.Timer
+		pending *Pending
+	}
+
 	Sender struct {
-		logger    *zap.Logger
-		transport Transport
-		listeners []EventListener
+		mu          sync.RWMutex
+		wg          sync.WaitGroup
+		logger      *zap.Logger
+		transport   Transport
+		settings    *Settings
+		clock       clock.Clock
+		listeners   []EventListener
+		batches     []BatchEventListener
+		serializers map[string]Serializer
+		queue       chan *notification.Message
+		closed      bool
+		dropped     uint64
+		delivered   uint64
+		failed      uint64
+		rejected    uint64
+		// queued messages being delivered by the workers and first attempts being made
+		activeMessages   int64
+		activeDeliveries int64
+		// delivered attempts seen by the audit, every AuditSampleRate-th keeps its request
+		audited uint64
+
+		retryMu        sync.Mutex
+		retryWg        sync.WaitGroup
+		retries        map[string]*scheduledRetry
+		retriesStopped bool
+		// slots of retry attempts made at once, nil if unlimited
+		retrySlots   chan struct{}
+		retryWaiting int64
+		retryActive  int64
+
+		busyMu sync.Mutex
+		busy   int
+		idle   []chan struct{}
+
+		healthMu sync.RWMutex
+		health   map[string]EndpointStatus
+
+		inFlightMu sync.Mutex
+		inFlight   map[string]*semaphore
+
+		// balanced transports of endpoints with a balance group, keyed like the semaphores
+		balancedMu sync.Mutex
+		balanced   map[string]*balancedEndpoint
+
+		// compiled conditions of endpoints by their expressions, bounded by MAX_CACHED_CONDITIONS
+		conditionsMu sync.Mutex
+		conditions   map[string]*vm.Program
+
+		// endpoints already warned about projections without identity fields
+		projectionWarnings sync.Map
+
+		// keys of peripherals found and not lost yet
+		presenceMu sync.Mutex
+		present    map[string]struct{}
+
+		// last notification of peripherals by their keys, kept for the cooldown
+		cooldownMu    sync.Mutex
+		cooldowns     map[string]*cooldownState
+		cooldownSweep time.Time
+
+		// messages collected by endpoints with batching
+		batchMu        sync.Mutex
+		batchWg        sync.WaitGroup
+		pendingBatches map[string]*endpointBatch
+
+		// last use of endpoints with per-endpoint state by url, the most recently used first
+		trackedMu    sync.Mutex
+		tracked      map[string]*list.Element
+		trackedOrder *list.List
+
+		statsMu      sync.Mutex
+		skipped      map[string]uint64
+		sent         map[string]*uint64
+		statuses     map[int]uint64
+		latencies    uint64
+		latencyTotal time.Duration
+		latencyMax   time.Duration
 	}
 )
 
-func New(logger *zap.Logger, transport Transport) *Sender {
-	return &Sender{
-		logger,
-		transport,
-		make([]EventListener, 0, 5),
+// New creates a sender with the default settings changed by the options, without options it equals NewWithSettings with NewDefaultSettings
+func New(logger *zap.Logger, transport Transport, options ...Option) *Sender {
+	settings := NewDefaultSettings()
+
+	for _, option := range options {
+		option(settings)
 	}
+
+	return NewWithSettings(logger, transport, settings)
+}
+
+// NewWithSettings substitutes a nil transport with one failing every delivery with ErrMissedTransport
+func NewWithSettings(logger *zap.Logger, transport Transport, settings *Settings) *Sender {
+	if settings == nil {
+		settings = NewDefaultSettings()
+	}
+
+	if transport == nil {
+		logger.Warn("Sender has no transport, every delivery will fail")
+
+		transport = NewFailingTransport(ErrMissedTransport)
+	}
+
+	queueSize := settings.QueueSize
+	timeSource := settings.Clock
+
+	if queueSize < 0 {
+		queueSize = 0
+	}
+
+	if timeSource == nil {
+		timeSource = clock.New()
+	}
+
+	sender := &Sender{
+		logger:         logger,
+		transport:      transport,
+		settings:       settings,
+		clock:          timeSource,
+		listeners:      make([]EventListener, 0, 5),
+		batches:        make([]BatchEventListener, 0, 5),
+		queue:          make(chan *notification.Message, queueSize),
+		retries:        make(map[string]*scheduledRetry),
+		health:         make(map[string]EndpointStatus),
+		present:        make(map[string]struct{}),
+		cooldowns:      make(map[string]*cooldownState),
+		inFlight:       make(map[string]*semaphore),
+		balanced:       make(map[string]*balancedEndpoint),
+		conditions:     make(map[string]*vm.Program),
+		skipped:        make(map[string]uint64),
+		sent:           make(map[string]*uint64),
+		statuses:       make(map[int]uint64),
+		tracked:        make(map[string]*list.Element),
+		trackedOrder:   list.New(),
+		pendingBatches: make(map[string]*endpointBatch),
+	}
+
+	sender.serializers = sender.createSerializers()
+
+	if settings.RetryWorkers > 0 {
+		sender.retrySlots = make(chan struct{}, settings.RetryWorkers)
+	}
+
+	sender.startWorkers()
+	sender.restorePending()
+
+	return sender
+}
+
+// SendContext sends the message like Send, with its deliveries bound by the context.
+// The first attempts are made with the context, and retries are given up with its error once it is done
+// or when the backoff of the next retry ends past its deadline, instead of going through the whole retry schedule.
+// A deadline is kept with persisted retries, a cancellation is not.
+func (sender *Sender) SendContext(ctx context.Context, msg *notification.Message) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	return sender.Send(msg.SetContext(ctx))
 }
 
 func (sender *Sender) Send(msg *notification.Message) error {
+	msg = sender.withDefaults(msg)
+
+	// presence changes even if nobody is notified about it
+	transition := sender.isTransition(msg)
+
+	// the peripheral is read when the message is delivered, possibly by another goroutine
+	msg = msg.Snapshot()
+
+	if msg.CorrelationId() == "" {
+		id, err := notification.GenerateId()
+
+		if err != nil {
+			return err
+		}
+
+		msg.SetCorrelationId(id)
+	}
+
 	if !sender.isSupportedEventName(msg.EventName()) {
-		return fmt.Errorf("%s %s", ErrUnsupportedEventName, msg.EventName())
+		err := errors.Wrap(ErrUnsupportedEventName, msg.EventName())
+
+		sender.emit([]*Event{{
+			Name:          msg.EventName(),
+			Timestamp:     sender.clock.Now(),
+			TargetName:    msg.TargetName(),
+			Error:         err,
+			Rejected:      true,
+			CorrelationId: msg.CorrelationId(),
+		}})
+
+		return err
+	}
+
+	// the broker sends messages without subscribers for the default endpoints
+	if len(msg.Subscribers()) == 0 {
+		return nil
+	}
+
+	if !transition {
+		sender.suppress(msg, SKIP_REASON_DUPLICATE)
+
+		return nil
+	}
+
+	if !msg.Registered() && !sender.settings.Unregistered {
+		sender.suppress(msg, SKIP_REASON_UNREGISTERED)
+
+		return nil
+	}
+
+	if sender.settings.Synchronous || sender.settings.SynchronousFirstAttempt {
+		return sender.sendNow(msg)
+	}
+
+	// Queued messages are delivered in batch by the workers
+	return sender.enqueue(msg)
+}
+
+// Close stops accepting new messages and waits until the queued ones are delivered.
+// Scheduled retries are cancelled, persisted ones are resumed by the next sender using the same store.
+func (sender *Sender) Close() error {
+	sender.mu.Lock()
+
+	if sender.closed {
+		sender.mu.Unlock()
+		return nil
 	}
 
-	// Call endpoints in batch inside a separate goroutine
-	go sender.sendBatch(msg)
+	sender.closed = true
+	close(sender.queue)
+	sender.mu.Unlock()
+
+	sender.wg.Wait()
+	sender.flushBatches()
+	sender.stopRetries()
 
 	return nil
 }
 
+func (sender *Sender) sendNow(msg *notification.Message) error {
+	sender.mu.RLock()
+	defer sender.mu.RUnlock()
+
+	if sender.closed {
+		return ErrSenderClosed
+	}
+
+	err := sender.sendBatch(msg)
+
+	if !sender.settings.SynchronousFirstAttempt {
+		return nil
+	}
+
+	return err
+}
+
 func (sender *Sender) AddEventListener(listener EventListener) {
 	if listener == nil {
 		return
@@ -88,59 +362,194 @@ func (sender *Sender) RemoveEventListener(listener EventListener) bool {
 	return true
 }
 
+func (sender *Sender) AddBatchEventListener(listener BatchEventListener) {
+	if listener == nil {
+		return
+	}
+
+	sender.batches = append(sender.batches, listener)
+}
+
+func (sender *Sender) RemoveBatchEventListener(listener BatchEventListener) bool {
+	if listener == nil {
+		return false
+	}
+
+	idx := -1
+	handlerPointer := reflect.ValueOf(listener).Pointer()
+
+	for i, element := range sender.batches {
+		currentPointer := reflect.ValueOf(element).Pointer()
+
+		if currentPointer == handlerPointer {
+			idx = i
+		}
+	}
+
+	if idx < 0 {
+		return false
+	}
+
+	sender.batches = append(sender.batches[:idx], sender.batches[idx+1:]...)
+
+	return true
+}
+
 func (sender *Sender) isSupportedEventName(name string) bool {
 	if name == "" {
 		return false
 	}
 
-	return name == "found" || name == "lost"
+	supported := sender.settings.EventNames
+
+	if len(supported) == 0 {
+		supported = []string{notification.FOUND, notification.LOST, notification.PROXIMITY_CHANGED}
+	}
+
+	for _, current := range supported {
+		if current == name {
+			return true
+		}
+	}
+
+	return false
 }
 
-func (sender *Sender) sendBatch(msg *notification.Message) {
+// Delivers the message to all its subscribers and emits their events, returns the first delivery error
+func (sender *Sender) sendBatch(msg *notification.Message) error {
 	subscribers := msg.Subscribers()
-	events := make([]*Event, 0, len(subscribers))
-
-	for _, subscriber := range subscribers {
-		err := sender.sendSingle(msg.TargetName(), msg.Peripheral(), subscriber)
-
-		evt := &Event{
-			Name:       msg.EventName(),
-			Timestamp:  time.Now(),
-			TargetName: msg.TargetName(),
-			Subscriber: subscriber,
-			Delivered:  err == nil,
-			Error:      err,
+
+	// most messages have a single subscriber, which needs neither the slots nor the goroutines
+	if len(subscribers) == 1 {
+		evt := sender.deliver(msg, subscribers[0])
+
+		// events of batched messages are emitted when their batch is sent
+		if evt == nil {
+			return nil
 		}
 
-		events = append(events, evt)
+		// the slice does not escape emit, as go build -gcflags=-m reports, so it saves the allocation of the slots
+		sender.emit([]*Event{evt})
 
-		if err == nil {
-			sender.logger.Info(
-				"Succeeded to notify a subscriber for peripheral",
-				zap.String("subscriber", subscriber.Name),
-				zap.String("peripheral", msg.TargetName()),
-			)
-		} else {
-			sender.logger.Info(
-				"Failed to notify a subscriber '%s' for peripheral '%s'",
-				zap.String("subscriber", subscriber.Name),
-				zap.String("peripheral", msg.TargetName()),
-				zap.Error(err),
-			)
+		return evt.Error
+	}
+
+	// every subscriber has its own slot, so events keep the order of subscribers
+	events := make([]*Event, len(subscribers))
+
+	if sender.settings.Synchronous {
+		for idx, subscriber := range subscribers {
+			events[idx] = sender.deliver(msg, subscriber)
+		}
+	} else {
+		var wg sync.WaitGroup
+
+		wg.Add(len(subscribers))
+
+		for idx, subscriber := range subscribers {
+			go func(idx int, subscriber *notification.Subscriber) {
+				defer wg.Done()
+
+				events[idx] = sender.deliver(msg, subscriber)
+			}(idx, subscriber)
+		}
+
+		wg.Wait()
+	}
+
+	delivered := events[:0]
+
+	for _, evt := range events {
+		if evt != nil {
+			delivered = append(delivered, evt)
+		}
+	}
+
+	sender.emit(delivered)
+
+	for _, evt := range delivered {
+		if evt.Error != nil {
+			return evt.Error
 		}
 	}
 
-	sender.emit(events)
+	return nil
 }
 
-func (sender *Sender) sendSingle(name string, peripheral peripherals.Peripheral, subscriber *notification.Subscriber) error {
-	serialized, err := sender.serializePeripheral(name, peripheral)
+func (sender *Sender) deliver(msg *notification.Message, subscriber *notification.Subscriber) *Event {
+	atomic.AddInt64(&sender.activeDeliveries, 1)
+	defer atomic.AddInt64(&sender.activeDeliveries, -1)
 
-	if err != nil {
-		sender.logger.Error(err.Error())
-		return err
+	start := sender.clock.Now()
+	pending, retrying, err := sender.sendSingle(msg, subscriber)
+	duration := sender.clock.Now().Sub(start)
+
+	if err == errBatched {
+		return nil
+	}
+
+	if reason := skipReason(err); reason != "" {
+		sender.countSkipped(reason)
+
+		sender.logger.Info(
+			"Skipped to notify a subscriber for peripheral",
+			zap.String("subscriber", subscriber.Name),
+			zap.String("peripheral", msg.TargetName()),
+			zap.String("correlation id", msg.CorrelationId()),
+			zap.String("reason", reason),
+		)
+
+		return &Event{
+			Name:          msg.EventName(),
+			Timestamp:     sender.clock.Now(),
+			TargetName:    msg.TargetName(),
+			Subscriber:    subscriber,
+			SkipReason:    reason,
+			DryRun:        sender.settings.DryRun,
+			CorrelationId: msg.CorrelationId(),
+		}
+	}
+
+	evt := &Event{
+		Name:          msg.EventName(),
+		Timestamp:     sender.clock.Now(),
+		TargetName:    msg.TargetName(),
+		Subscriber:    subscriber,
+		Delivered:     err == nil,
+		Error:         err,
+		Category:      categorizeError(err),
+		DryRun:        sender.settings.DryRun,
+		Duration:      duration,
+		Latency:       sender.latency(msg.DetectedAt(), err),
+		Attempt:       1,
+		WillRetry:     retrying,
+		CorrelationId: msg.CorrelationId(),
+		request:       pending,
+	}
+
+	if err == nil {
+		sender.logger.Info(
+			"Succeeded to notify a subscriber for peripheral",
+			zap.String("subscriber", subscriber.Name),
+			zap.String("peripheral", msg.TargetName()),
+			zap.String("correlation id", msg.CorrelationId()),
+		)
+	} else {
+		sender.logger.Info(
+			"Failed to notify a subscriber '%s' for peripheral '%s'",
+			zap.String("subscriber", subscriber.Name),
+			zap.String("peripheral", msg.TargetName()),
+			zap.String("correlation id", msg.CorrelationId()),
+			zap.String("reason", evt.Category),
+			zap.Error(err),
+		)
 	}
 
+	return evt
+}
+
+// Makes the first attempt of a delivery, retrying tells whether another attempt is scheduled
+func (sender *Sender) sendSingle(msg *notification.Message, subscriber *notification.Subscriber) (*Pending, bool, error) {
 	endpoint := subscriber.Endpoint
 
 	if endpoint == nil {
@@ -148,7 +557,126 @@ func (sender *Sender) sendSingle(name string, peripheral peripherals.Peripheral,
 			"subscriber has no endpoints",
 			zap.String("subscriber", subscriber.Name),
 		)
-		return nil
+		return nil, false, skip(SKIP_REASON_NO_ENDPOINT)
+	}
+
+	if !endpoint.IsEnabled() {
+		return nil, false, skip(SKIP_REASON_DISABLED)
+	}
+
+	if !matchesProximity(msg, endpoint) {
+		return nil, false, skip(SKIP_REASON_PROXIMITY)
+	}
+
+	if matched, err := sender.matchesCondition(msg, endpoint); err != nil {
+		return nil, false, newDeliveryError(subscriber, 1, err)
+	} else if !matched {
+		return nil, false, skip(SKIP_REASON_CONDITION)
+	}
+
+	if !subscriber.Priority && sender.settings.QuietHours.Contains(sender.clock.Now()) {
+		return nil, false, skip(SKIP_REASON_QUIET_HOURS)
+	}
+
+	if sender.isStale(msg.DetectedAt()) {
+		return nil, false, skip(SKIP_REASON_STALE)
+	}
+
+	// checked last, as only a delivery which is attempted starts the cooldown
+	if sender.isCoolingDown(msg) {
+		return nil, false, skip(SKIP_REASON_COOLDOWN)
+	}
+
+	if isBatched(endpoint) {
+		return nil, false, sender.addToBatch(msg, subscriber)
+	}
+
+	ctx := msg.Context()
+
+	if err := ctx.Err(); err != nil {
+		return nil, false, newDeliveryError(subscriber, 1, err)
+	}
+
+	if peripheral := msg.Peripheral(); peripheral != nil {
+		ctx = withPeripheral(ctx, peripheral.UniqueKey())
+	}
+
+	pending, err := sender.prepareRequest(msg, subscriber, nil)
+
+	if err != nil {
+		return nil, false, newDeliveryError(subscriber, 1, err)
+	}
+
+	// the request is rebuilt from the pending delivery for every attempt, so every attempt is signed separately
+	pending.EventName = msg.EventName()
+	pending.TargetName = msg.TargetName()
+	pending.Subscriber = subscriber
+	pending.Attempt = 1
+	pending.CorrelationId = msg.CorrelationId()
+	pending.DetectedAt = msg.DetectedAt()
+	pending.ctx = ctx
+
+	if deadline, found := ctx.Deadline(); found {
+		pending.Deadline = deadline
+	}
+
+	req, err := pending.request()
+
+	if err != nil {
+		return nil, false, newDeliveryError(subscriber, 1, err)
+	}
+
+	err = sender.do(req, endpoint)
+	retrying := false
+
+	if err != nil && sender.settings.MaxAttempts > 1 {
+		id, idErr := notification.GenerateId()
+
+		if idErr != nil {
+			return pending, false, newDeliveryError(subscriber, 1, idErr)
+		}
+
+		pending.Id = id
+		retrying = sender.retry(pending, err)
+	}
+
+	return pending, retrying, newDeliveryError(subscriber, 1, err)
+}
+
+// Serializes the message for the subscriber endpoint and creates the pending delivery its requests are built from.
+// Extra fields are added to the payload regardless of the endpoint fields.
+func (sender *Sender) prepareRequest(
+	msg *notification.Message,
+	subscriber *notification.Subscriber,
+	extra map[string]interface{},
+) (*Pending, error) {
+	endpoint := subscriber.Endpoint
+	serialized, err := sender.serializePeripheral(msg)
+
+	if err != nil {
+		sender.logger.Error(
+			"Failed to serialize peripheral",
+			zap.String("subscriber", subscriber.Name),
+			zap.String("peripheral", msg.TargetName()),
+			zap.Error(err),
+		)
+
+		return nil, err
+	}
+
+	// placeholders refer to the fields by their canonical names
+	fields := serialized
+	projected := sender.projectFields(serialized, endpoint)
+
+	for key, value := range extra {
+		projected[key] = value
+	}
+
+	serialized, err = sender.renameFields(projected, endpoint)
+
+	if err != nil {
+		sender.logger.Error(err.Error())
+		return nil, err
 	}
 
 	if endpoint.Url == "" {
@@ -160,74 +688,234 @@ func (sender *Sender) sendSingle(name string, peripheral peripherals.Peripheral,
 			zap.Error(err),
 		)
 
-		return err
+		return nil, err
 	}
 
-	method := strings.ToUpper(endpoint.Method)
-	req, err := http.NewRequest(method, subscriber.Endpoint.Url, nil)
+	pending, err := sender.createRequest(msg, serialized, fields, endpoint)
 
 	if err != nil {
+		return nil, err
+	}
+
+	body := pending.Body
+
+	if err := sender.validatePayload(endpoint, body); err != nil {
 		sender.logger.Error(
-			"failed to create a new request",
+			"Payload does not conform to the endpoint schema",
+			zap.String("endpoint name", endpoint.Name),
+			zap.String("schema", endpoint.Options.Schema),
 			zap.Error(err),
-			zap.String("endpoint", endpoint.Name),
 		)
 
-		return errors.Wrap(err, "failed to create a new request")
+		return nil, err
 	}
 
-	if method == http.MethodPost {
-		req.Header.Set("Content-Type", "application/json")
+	return pending, nil
+}
 
-		body, err := json.Marshal(serialized)
+// Request bodies of endpoints with a schema must conform to it
+func (sender *Sender) validatePayload(endpoint *notification.Endpoint, body []byte) error {
+	name := endpoint.Options.Schema
+
+	if name == "" {
+		return nil
+	}
+
+	schema, ok := sender.settings.Schemas[name]
+
+	if !ok || schema == nil {
+		return errors.Wrapf(ErrUnknownSchema, "'%s'", name)
+	}
+
+	return schema.Validate(body)
+}
+
+// Creates the url and headers of the request as templates, which keep references to environment variables,
+// so that a pending delivery persisted or audited with them keeps no secrets. Pending.request resolves them.
+func (sender *Sender) createRequest(
+	msg *notification.Message,
+	serialized map[string]interface{},
+	fields map[string]interface{},
+	endpoint *notification.Endpoint,
+) (*Pending, error) {
+	reqUrl := endpoint.Url
+
+	// a host without a scheme would be parsed as a path
+	if !strings.Contains(reqUrl, "://") {
+		reqUrl = DEFAULT_SCHEME + "://" + reqUrl
+	}
+
+	pending := &Pending{
+		Method: strings.ToUpper(endpoint.Method),
+		Url:    reqUrl,
+		Header: make(http.Header),
+	}
+
+	if pending.Method == http.MethodPost {
+		body, contentType, err := sender.serialize(msg, serialized, endpoint)
 
 		if err != nil {
-			return err
+			return nil, err
 		}
 
-		req.Body = ioutil.NopCloser(bytes.NewReader(body))
+		pending.Header.Set("Content-Type", escapeEnv(contentType))
+		pending.Body = body
 	} else {
 		query, err := sender.encode(serialized)
 
 		if err != nil {
-			return err
+			return nil, err
 		}
 
-		req.URL.RawQuery = query
+		pending.Url = withQuery(reqUrl, escapeEnv(query))
 	}
 
-	if req == nil {
-		err = fmt.Errorf(
-			"%s: %s for endpoint %s",
-			ErrUnsupportedHttpMethod,
-			endpoint.Method,
-			endpoint.Name,
-		)
+	if err := setHeaderTemplates(pending.Header, endpoint.Headers, fields); err != nil {
+		return nil, err
+	}
+
+	// headers of the event override the endpoint headers of the same name
+	if err := setHeaderTemplates(pending.Header, endpoint.Options.EventHeaders[msg.EventName()], fields); err != nil {
+		return nil, err
+	}
 
+	if id := msg.CorrelationId(); id != "" {
+		pending.Header.Set(CORRELATION_ID_HEADER, escapeEnv(id))
+	}
+
+	// fails right away on a url which cannot be parsed or a missing variable
+	if _, err := pending.request(); err != nil {
 		sender.logger.Error(
-			"Failed to create a request",
-			zap.String("endpoint", endpoint.Name),
+			"failed to create a new request",
 			zap.Error(err),
+			zap.String("endpoint", endpoint.Name),
 		)
 
+		return nil, err
+	}
+
+	return pending, nil
+}
+
+// Replaces the query of the url template, the query of a GET request carries the fields
+func withQuery(template, query string) string {
+	if idx := strings.IndexAny(template, "?#"); idx >= 0 {
+		template = template[:idx]
+	}
+
+	if query == "" {
+		return template
+	}
+
+	return template + "?" + query
+}
+
+// Sets the headers as templates: placeholders are expanded and references to environment variables are kept
+func setHeaderTemplates(header http.Header, headers notification.Headers, fields map[string]interface{}) error {
+	for key, value := range headers {
+		template := expandPlaceholders(value, fields)
+		resolved, err := expandEnv(template)
+
+		if err != nil {
+			return errors.Wrapf(err, "header %s", key)
+		}
+
+		name, err := validateHeader(key, resolved)
+
+		if err != nil {
+			return err
+		}
+
+		header.Set(name, template)
+	}
+
+	return nil
+}
+
+func setHeaders(req *http.Request, headers notification.Headers, fields map[string]interface{}) error {
+	templates := make(http.Header)
+
+	if err := setHeaderTemplates(templates, headers, fields); err != nil {
+		return err
+	}
+
+	resolved, err := resolveHeader(templates)
+
+	if err != nil {
+		return err
+	}
+
+	for name, values := range resolved {
+		req.Header[name] = values
+	}
+
+	return nil
+}
+
+func (sender *Sender) do(req *http.Request, endpoint *notification.Endpoint) error {
+	return sender.roundTrip(req, endpoint, true)
+}
+
+// Probes are not deliveries, so only deliveries count in the health and the stats of the endpoint
+func (sender *Sender) roundTrip(req *http.Request, endpoint *notification.Endpoint, delivery bool) error {
+	sender.trackEndpoint(endpoint)
+
+	if timeout := sender.timeout(endpoint); timeout > 0 {
+		ctx, cancel := context.WithTimeout(req.Context(), timeout)
+		defer cancel()
+
+		req = req.WithContext(ctx)
+	}
+
+	ctx, recorder := withStatusRecorder(withConnectTimeout(req.Context(), sender.connectTimeout(endpoint)))
+	req = req.WithContext(ctx)
+
+	if sender.settings.RequestHook != nil {
+		if err := sender.settings.RequestHook(req); err != nil {
+			sender.logger.Error(
+				"Request hook failed",
+				zap.String("endpoint name", endpoint.Name),
+				zap.String("endpoint url", endpoint.Url),
+				zap.Error(err),
+			)
+
+			return err
+		}
+	}
+
+	if sender.settings.DryRun {
+		return sender.logDryRun(req, endpoint)
+	}
+
+	transport, err := sender.endpointTransport(endpoint)
+
+	if err != nil {
 		return err
 	}
 
-	headers := endpoint.Headers
+	release, err := sender.acquire(req.Context(), endpoint)
+
+	if err == nil {
+		err = transport.Do(req)
+		release()
 
-	if headers != nil && len(headers) > 0 {
-		for key, value := range headers {
-			req.Header.Set(key, value)
+		if delivery {
+			sender.countSent(endpoint, req)
+			sender.countStatus(recorder.status(err))
 		}
 	}
 
-	err = sender.transport.Do(req)
+	if delivery {
+		sender.updateHealth(endpoint, err)
+	}
 
 	if err != nil {
 		sender.logger.Error(
 			"Failed to reach out the endpoint",
 			zap.String("endpoint name", endpoint.Name),
 			zap.String("endpoint url", endpoint.Url),
+			zap.String("correlation id", req.Header.Get(CORRELATION_ID_HEADER)),
+			zap.String("reason", categorizeError(err)),
 			zap.Error(err),
 		)
 
@@ -237,38 +925,124 @@ func (sender *Sender) sendSingle(name string, peripheral peripherals.Peripheral,
 	return nil
 }
 
-func (sender *Sender) serializePeripheral(name string, peripheral peripherals.Peripheral) (map[string]interface{}, error) {
-	if peripheral == nil {
-		return nil, errors.New("missed peripheral")
+// Endpoint options take precedence over the sender settings
+func (sender *Sender) timeout(endpoint *notification.Endpoint) time.Duration {
+	if endpoint.Options.Timeout > 0 {
+		return time.Duration(endpoint.Options.Timeout) * time.Millisecond
 	}
 
-	serialized := make(map[string]interface{})
+	return sender.settings.Timeout
+}
+
+// Endpoint options take precedence over the sender settings
+func (sender *Sender) connectTimeout(endpoint *notification.Endpoint) time.Duration {
+	if endpoint.Options.ConnectTimeout > 0 {
+		return time.Duration(endpoint.Options.ConnectTimeout) * time.Millisecond
+	}
+
+	return sender.settings.ConnectTimeout
+}
+
+func (sender *Sender) serializePeripheral(msg *notification.Message) (map[string]interface{}, error) {
+	peripheral, err := sender.addressPeripheral(msg.Peripheral())
+
+	if err != nil {
+		return nil, err
+	}
 
-	serialized["name"] = name
-	serialized["kind"] = peripheral.Kind()
-	serialized["proximity"] = peripheral.Proximity()
-	serialized["accuracy"] = strconv.FormatFloat(peripheral.Accuracy(), 'f', 6, 64)
+	serialized, complete, err := serializeFields(msg.TargetName(), peripheral, msg.EventName())
 
-	switch peripheral.Kind() {
-	case peripherals.PERIPHERAL_IBEACON:
-		ibeacon, ok := peripheral.(*peripherals.IBeaconPeripheral)
+	if err != nil {
+		return nil, err
+	}
+
+	if !complete && sender.settings.Strict {
+		peripheral := msg.Peripheral()
+
+		return nil, errors.Wrapf(ErrUnableToSerializePeripheral, "%s of kind %s", peripheral.UniqueKey(), peripheral.Kind())
+	}
+
+	if msg.EventName() == notification.PROXIMITY_CHANGED {
+		serialized[FIELD_PREVIOUS_PROXIMITY] = msg.PreviousProximity()
+	}
+
+	// lost events carry no proximity to label
+	if proximity, ok := serialized[FIELD_PROXIMITY].(string); ok && len(sender.settings.ProximityLabels) > 0 {
+		label, found := sender.settings.ProximityLabels[proximity]
 
-		if !ok {
-			return nil, fmt.Errorf("%s %s", ErrUnableToSerializePeripheral, peripheral.UniqueKey())
+		if !found {
+			label = proximity
 		}
 
-		serialized["uuid"] = ibeacon.Uuid()
-		serialized["major"] = strconv.Itoa(int(ibeacon.Major()))
-		serialized["minor"] = strconv.Itoa(int(ibeacon.Minor()))
+		serialized[FIELD_PROXIMITY_LABEL] = label
+	}
+
+	serialized[FIELD_REGISTERED] = strconv.FormatBool(msg.Registered())
+
+	// unregistered peripherals have nothing but their identifiers
+	if metadata := msg.Metadata(); msg.Registered() && len(metadata) > 0 {
+		copied := make(map[string]string, len(metadata))
+
+		for key, value := range metadata {
+			copied[key] = value
+		}
+
+		serialized[FIELD_METADATA] = copied
 	}
 
 	return serialized, nil
 }
 
+// Serializes fields common for all events and the identity fields of the kind, complete is false for kinds without a PeripheralSerializer
+func serializeFields(name string, peripheral peripherals.Peripheral, event string) (map[string]interface{}, bool, error) {
+	if peripheral == nil {
+		return nil, false, ErrMissedPeripheral
+	}
+
+	serialized := make(map[string]interface{})
+
+	serialized[FIELD_NAME] = name
+	serialized[FIELD_KIND] = peripheral.Kind()
+
+	if address := peripheral.Address(); address != "" {
+		serialized[FIELD_ADDRESS] = address
+	}
+
+	// a lost peripheral carries only its identity, the last signal readings are stale
+	if event != notification.LOST {
+		serialized[FIELD_PROXIMITY] = peripheral.Proximity()
+		serialized[FIELD_ACCURACY] = strconv.FormatFloat(peripheral.Accuracy(), 'f', 6, 64)
+	}
+
+	serializer, found := getPeripheralSerializer(peripheral.Kind())
+
+	if !found {
+		return serialized, false, nil
+	}
+
+	if err := serializer(unwrapPeripheral(peripheral), serialized); err != nil {
+		return nil, false, err
+	}
+
+	return serialized, true, nil
+}
+
 func (sender *Sender) encode(data map[string]interface{}) (string, error) {
 	var buf bytes.Buffer
 
 	for k, v := range data {
+		// nested values, e.g. metadata, are flattened into keys of their entries
+		if nested, ok := v.(map[string]string); ok {
+			for key, value := range nested {
+				buf.WriteString(url.QueryEscape(k + "[" + key + "]"))
+				buf.WriteByte('=')
+				buf.WriteString(url.QueryEscape(value))
+				buf.WriteByte('&')
+			}
+
+			continue
+		}
+
 		buf.WriteString(url.QueryEscape(k))
 		buf.WriteByte('=')
 		buf.WriteString(fmt.Sprintf("%s", v))
@@ -277,18 +1051,44 @@ func (sender *Sender) encode(data map[string]interface{}) (string, error) {
 
 	str := buf.String()
 
+	// no fields are left after the projection, or only empty nested ones
+	if str == "" {
+		return "", nil
+	}
+
 	// remove last ampersand
 	return str[0 : len(str)-1], nil
 }
 
+// Every per-event listener gets all events of the batch in turn, then every batch listener gets the whole batch.
+// Both kinds are called in the order of their registration.
 func (sender *Sender) emit(events []*Event) {
 	if events == nil || len(events) == 0 {
 		return
 	}
 
+	for _, evt := range events {
+		sender.countOutcome(evt)
+		sender.audit(evt)
+	}
+
 	for _, listener := range sender.listeners {
 		for _, evt := range events {
 			listener(*evt)
 		}
 	}
+
+	if len(sender.batches) == 0 {
+		return
+	}
+
+	batch := make([]Event, len(events))
+
+	for idx, evt := range events {
+		batch[idx] = *evt
+	}
+
+	for _, listener := range sender.batches {
+		listener(batch)
+	}
 }
@@ -0,0 +1,60 @@
+package delivery
+
+import (
+	"github.com/blent/beagle/pkg/notification"
+	"go.uber.org/zap"
+	"io/ioutil"
+	"net/http"
+	"strings"
+)
+
+const redactedValue = "[REDACTED]"
+
+var sensitiveHeaders = []string{"authorization", "cookie", "token", "secret", "key", "signature", "password"}
+
+// Logs the request instead of sending it
+func (sender *Sender) logDryRun(req *http.Request, endpoint *notification.Endpoint) error {
+	var body []byte
+
+	if req.Body != nil {
+		var err error
+
+		body, err = ioutil.ReadAll(req.Body)
+		req.Body.Close()
+
+		if err != nil {
+			return err
+		}
+	}
+
+	sender.logger.Info(
+		"Dry run, skipped to send a request",
+		zap.String("endpoint name", endpoint.Name),
+		zap.String("method", req.Method),
+		zap.String("url", req.URL.String()),
+		zap.Any("headers", redactHeaders(req.Header)),
+		zap.ByteString("body", body),
+	)
+
+	return nil
+}
+
+func redactHeaders(header http.Header) map[string]string {
+	result := make(map[string]string, len(header))
+
+	for key := range header {
+		value := header.Get(key)
+		name := strings.ToLower(key)
+
+		for _, sensitive := range sensitiveHeaders {
+			if strings.Contains(name, sensitive) {
+				value = redactedValue
+				break
+			}
+		}
+
+		result[key] = value
+	}
+
+	return result
+}
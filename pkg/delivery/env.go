@@ -0,0 +1,134 @@
+package delivery
+
+import (
+	"github.com/blent/beagle/pkg/notification"
+	"github.com/pkg/errors"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// Only variables of this prefix can be referred, so a stored endpoint cannot read other variables of the process
+const ENV_PREFIX = "BEAGLE_"
+
+// References like ${BEAGLE_API_TOKEN} or ${BEAGLE_API_TOKEN:-default} are resolved from the process environment
+var envPattern = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)(:-([^}]*))?\}`)
+
+// $${ stands for a literal ${, values which are not templates are escaped with it
+var envEscapePattern = regexp.MustCompile(`\$\$\{|` + envPattern.String())
+
+var (
+	envMu sync.RWMutex
+	// Variables of the prefix as of the start of the process or the last LoadEnv
+	environment = readEnv()
+)
+
+// LoadEnv reloads the variables of ENV_PREFIX referred by endpoints, they are read once at start otherwise
+func LoadEnv() {
+	variables := readEnv()
+
+	envMu.Lock()
+	environment = variables
+	envMu.Unlock()
+}
+
+func readEnv() map[string]string {
+	variables := make(map[string]string)
+
+	for _, pair := range os.Environ() {
+		if !strings.HasPrefix(pair, ENV_PREFIX) {
+			continue
+		}
+
+		if idx := strings.Index(pair, "="); idx > 0 {
+			variables[pair[:idx]] = pair[idx+1:]
+		}
+	}
+
+	return variables
+}
+
+func lookupEnv(name string) (string, bool) {
+	envMu.RLock()
+	defer envMu.RUnlock()
+
+	value, found := environment[name]
+
+	return value, found
+}
+
+// ValidateEnv checks that environment variables referred by the endpoint url, headers and event headers are set
+func ValidateEnv(endpoint *notification.Endpoint) error {
+	if _, err := expandEnv(endpoint.Url); err != nil {
+		return errors.Wrap(err, "url")
+	}
+
+	if err := validateHeadersEnv(endpoint.Headers); err != nil {
+		return err
+	}
+
+	for _, headers := range endpoint.Options.EventHeaders {
+		if err := validateHeadersEnv(headers); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func validateHeadersEnv(headers notification.Headers) error {
+	for key, value := range headers {
+		if _, err := expandEnv(value); err != nil {
+			return errors.Wrapf(err, "header %s", key)
+		}
+	}
+
+	return nil
+}
+
+// Replaces environment variable references with their values.
+// A variable which is unset or empty takes the default, if there is no default an unset variable is an error.
+// A reference to a variable without ENV_PREFIX is an error regardless of its default.
+func expandEnv(value string) (string, error) {
+	var err error
+
+	result := envEscapePattern.ReplaceAllStringFunc(value, func(reference string) string {
+		if reference == "$${" {
+			return "${"
+		}
+
+		match := envPattern.FindStringSubmatch(reference)
+
+		if !strings.HasPrefix(match[1], ENV_PREFIX) {
+			if err == nil {
+				err = errors.Wrap(ErrForbiddenEnvVariable, match[1])
+			}
+
+			return ""
+		}
+
+		variable, found := lookupEnv(match[1])
+
+		if match[2] != "" {
+			if variable == "" {
+				return match[3]
+			}
+
+			return variable
+		}
+
+		if !found && err == nil {
+			err = errors.Wrap(ErrMissingEnvVariable, match[1])
+		}
+
+		return variable
+	})
+
+	return result, err
+}
+
+// Escapes a value so references to environment variables it may contain are kept as they are
+func escapeEnv(value string) string {
+	return strings.ReplaceAll(value, "${", "$${")
+}
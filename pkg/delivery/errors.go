@@ -1,9 +1,106 @@
 package delivery
 
-import "github.com/pkg/errors"
+import (
+	"fmt"
+	"github.com/blent/beagle/pkg/notification"
+	"github.com/pkg/errors"
+)
 
 var (
 	ErrUnsupportedEventName        = errors.New("unsupported event name")
 	ErrUnsupportedHttpMethod       = errors.New("unsupported http method")
 	ErrUnableToSerializePeripheral = errors.New("unable to serialize peripheral")
+	ErrMissedPeripheral            = errors.New("missed peripheral")
+	ErrMissedEndpoint              = errors.New("missed endpoint")
+	ErrMissedTransport             = errors.New("missed transport")
+	ErrUnsupportedScheme           = errors.New("no transport registered for scheme")
+	ErrInvalidUnixSocketUrl        = errors.New("invalid unix socket url")
+	ErrInvalidFileUrl              = errors.New("invalid file url")
+	ErrInvalidBalanceTarget        = errors.New("invalid balance target")
+	ErrUnsupportedFieldNaming      = errors.New("unsupported field naming")
+	ErrUnsupportedAddressMode      = errors.New("unsupported address mode")
+	ErrMissingAddressSalt          = errors.New("hashed addresses require a salt")
+	ErrUnsupportedFormat           = errors.New("unsupported payload format")
+	ErrUnsupportedValue            = errors.New("value not supported by the payload format")
+	ErrQueueFull                   = errors.New("delivery queue is full")
+	ErrSenderClosed                = errors.New("sender is closed")
+	ErrStaleDelivery               = errors.New("delivery is stale")
+	ErrResponseTooLarge            = errors.New("response body is too large")
+	ErrUnknownPlaceholder          = errors.New("unknown placeholder")
+	ErrUnknownField                = errors.New("unknown field")
+	ErrFieldCollision              = errors.New("field collides with another serialized field")
+	ErrUnexpectedStatus            = errors.New("unexpected response status")
+	ErrMissingEnvVariable          = errors.New("missing environment variable")
+	ErrForbiddenEnvVariable        = errors.New("environment variable without the " + ENV_PREFIX + " prefix")
+	ErrUnsupportedProximity        = errors.New("unsupported proximity band")
+	ErrInvalidDistanceRange        = errors.New("invalid distance range")
+	ErrInvalidCondition            = errors.New("invalid condition expression")
+	ErrInvalidQuietHours           = errors.New("invalid quiet hours window")
+	ErrInvalidHeaderName           = errors.New("invalid header name")
+	ErrInvalidHeaderValue          = errors.New("invalid header value")
+	ErrInvalidSchema               = errors.New("invalid payload schema")
+	ErrUnknownSchema               = errors.New("unknown payload schema")
+	ErrSchemaViolation             = errors.New("payload does not conform to schema")
+	ErrUnsupportedSchema           = errors.New("schemas require a POST endpoint with a JSON serializer")
+	ErrUnsupportedBatch            = errors.New("batching requires a POST endpoint with a JSON serializer")
+	ErrInvalidBatch                = errors.New("invalid batch")
+	ErrUnsupportedProbe            = errors.New("probes of websocket endpoints are not supported")
+	ErrUnsupportedSnapshot         = errors.New("snapshots require a POST endpoint with a JSON serializer")
+	ErrPayloadTooLarge             = errors.New("payload too large")
+	ErrWebSocketUnavailable        = errors.New("websocket is not connected")
+	ErrWebSocketConnectionLost     = errors.New("websocket connection lost")
+	ErrWebSocketBackpressure       = errors.New("websocket message was not written in time")
+	ErrUnreadablePending           = errors.New("unreadable pending deliveries")
 )
+
+// DeliveryError describes a failed delivery to a subscriber.
+// It wraps the original error, so errors.Is and errors.As still see it.
+type DeliveryError struct {
+	Subscriber string
+	Endpoint   string
+	Url        string
+	// 0 unless the endpoint responded with an error status
+	StatusCode int
+	Category   string
+	Attempt    int
+	Err        error
+}
+
+func (err *DeliveryError) Error() string {
+	return fmt.Sprintf(
+		"failed to notify subscriber %s (attempt %d): %s",
+		err.Subscriber,
+		err.Attempt,
+		err.Err,
+	)
+}
+
+func (err *DeliveryError) Unwrap() error {
+	return err.Err
+}
+
+func newDeliveryError(subscriber *notification.Subscriber, attempt int, err error) error {
+	if err == nil {
+		return nil
+	}
+
+	result := &DeliveryError{
+		Subscriber: subscriber.Name,
+		Category:   categorizeError(err),
+		Attempt:    attempt,
+		Err:        err,
+	}
+
+	if subscriber.Endpoint != nil {
+		result.Endpoint = subscriber.Endpoint.Name
+		result.Url = subscriber.Endpoint.Url
+	}
+
+	var statusErr *StatusError
+
+	if errors.As(err, &statusErr) {
+		result.StatusCode = statusErr.StatusCode
+	}
+
+	return result
+}
@@ -0,0 +1,197 @@
+package delivery
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"github.com/blent/beagle/pkg/discovery/peripherals"
+	"github.com/blent/beagle/pkg/notification"
+	"github.com/pkg/errors"
+	"strings"
+)
+
+const (
+	FIELD_NAMING_SNAKE_CASE = "snake_case"
+	FIELD_NAMING_CAMEL_CASE = "camelCase"
+)
+
+// Ways to serialize the Bluetooth address of a peripheral
+const (
+	ADDRESS_MODE_PLAIN = "plain"
+	// HMAC-SHA256 of the address keyed by AddressSalt of the settings, which is required
+	ADDRESS_MODE_HASH = "hash"
+	ADDRESS_MODE_OMIT = "omit"
+)
+
+// Keys of a serialized peripheral before any renaming
+const (
+	FIELD_NAME      = "name"
+	FIELD_KIND      = "kind"
+	FIELD_PROXIMITY = "proximity"
+	FIELD_ACCURACY  = "accuracy"
+	FIELD_UUID      = "uuid"
+	FIELD_MAJOR     = "major"
+	FIELD_MINOR     = "minor"
+	FIELD_ADDRESS   = "address"
+
+	FIELD_PREVIOUS_PROXIMITY = "previous_proximity"
+	// Custom label of the proximity band, sent along with the raw proximity if labels are configured
+	FIELD_PROXIMITY_LABEL = "proximity_label"
+	// "true" for registered peripherals and "false" for others
+	FIELD_REGISTERED = "registered"
+	// Metadata of a registered peripheral carried by its message, omitted if there is none
+	FIELD_METADATA = "metadata"
+)
+
+// Every key a serialized peripheral may have
+var fieldKeys = []string{
+	FIELD_NAME,
+	FIELD_KIND,
+	FIELD_PROXIMITY,
+	FIELD_ACCURACY,
+	FIELD_UUID,
+	FIELD_MAJOR,
+	FIELD_MINOR,
+	FIELD_ADDRESS,
+	FIELD_PREVIOUS_PROXIMITY,
+	FIELD_PROXIMITY_LABEL,
+	FIELD_REGISTERED,
+	FIELD_METADATA,
+}
+
+// Renames the serialized keys by the sender settings, the peripheral name goes under the name key of the endpoint if it has one
+func (sender *Sender) renameFields(serialized map[string]interface{}, endpoint *notification.Endpoint) (map[string]interface{}, error) {
+	renamed := make(map[string]interface{}, len(serialized))
+
+	for key, value := range serialized {
+		if key == FIELD_NAME && endpoint.Options.NameKey != "" {
+			renamed[endpoint.Options.NameKey] = value
+			continue
+		}
+
+		name, err := sender.formatFieldName(key)
+
+		if err != nil {
+			return nil, err
+		}
+
+		renamed[name] = value
+	}
+
+	return renamed, nil
+}
+
+// ValidateNameKey checks that options.nameKey of the endpoint does not collide with another serialized key,
+// otherwise the order of map iteration would decide which value is sent
+func (sender *Sender) ValidateNameKey(endpoint *notification.Endpoint) error {
+	nameKey := endpoint.Options.NameKey
+
+	if nameKey == "" {
+		return nil
+	}
+
+	for _, keys := range [][]string{fieldKeys, {FIELD_TEST}} {
+		for _, key := range keys {
+			if key == FIELD_NAME {
+				continue
+			}
+
+			name, err := sender.formatFieldName(key)
+
+			if err != nil {
+				return err
+			}
+
+			if name == nameKey {
+				return errors.Wrapf(ErrFieldCollision, "name key '%s' of %s", nameKey, endpoint.Name)
+			}
+		}
+	}
+
+	return nil
+}
+
+func (sender *Sender) formatFieldName(key string) (string, error) {
+	if custom, ok := sender.settings.FieldNames[key]; ok && custom != "" {
+		return custom, nil
+	}
+
+	switch sender.settings.FieldNaming {
+	case "", FIELD_NAMING_SNAKE_CASE:
+		return key, nil
+	case FIELD_NAMING_CAMEL_CASE:
+		return toCamelCase(key), nil
+	default:
+		return "", errors.Wrap(ErrUnsupportedFieldNaming, sender.settings.FieldNaming)
+	}
+}
+
+func toCamelCase(key string) string {
+	words := strings.Split(key, "_")
+
+	for idx := 1; idx < len(words); idx++ {
+		if words[idx] != "" {
+			words[idx] = strings.ToUpper(words[idx][:1]) + words[idx][1:]
+		}
+	}
+
+	return strings.Join(words, "")
+}
+
+// A peripheral whose address is serialized by the address mode of the sender.
+// Serializers see it instead of the peripheral, so none of them gets the plain address.
+type addressedPeripheral struct {
+	peripherals.Peripheral
+	address string
+}
+
+func (peripheral *addressedPeripheral) Address() string {
+	return peripheral.address
+}
+
+// Returns the peripheral itself for the serializers of its kind, which may depend on its type
+func unwrapPeripheral(peripheral peripherals.Peripheral) peripherals.Peripheral {
+	if addressed, ok := peripheral.(*addressedPeripheral); ok {
+		return addressed.Peripheral
+	}
+
+	return peripheral
+}
+
+func (sender *Sender) addressPeripheral(peripheral peripherals.Peripheral) (peripherals.Peripheral, error) {
+	if peripheral == nil {
+		return nil, nil
+	}
+
+	address, err := sender.formatAddress(peripheral.Address())
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &addressedPeripheral{unwrapPeripheral(peripheral), address}, nil
+}
+
+func (sender *Sender) formatAddress(address string) (string, error) {
+	switch sender.settings.AddressMode {
+	case "", ADDRESS_MODE_PLAIN:
+		return address, nil
+	case ADDRESS_MODE_HASH:
+		if sender.settings.AddressSalt == "" {
+			return "", ErrMissingAddressSalt
+		}
+
+		if address == "" {
+			return "", nil
+		}
+
+		mac := hmac.New(sha256.New, []byte(sender.settings.AddressSalt))
+		mac.Write([]byte(address))
+
+		return hex.EncodeToString(mac.Sum(nil)), nil
+	case ADDRESS_MODE_OMIT:
+		return "", nil
+	default:
+		return "", errors.Wrap(ErrUnsupportedAddressMode, sender.settings.AddressMode)
+	}
+}
@@ -0,0 +1,93 @@
+package delivery_test
+
+import (
+	"encoding/json"
+	"github.com/blent/beagle/pkg/delivery"
+	"github.com/blent/beagle/pkg/discovery/peripherals"
+	"github.com/blent/beagle/pkg/notification"
+	"github.com/brianvoe/gofakeit"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"testing"
+)
+
+func TestSenderFieldNaming(t *testing.T) {
+	send := func(settings *delivery.Settings) (map[string]interface{}, error) {
+		transport := delivery.NewRecordingTransport()
+
+		settings.Synchronous = true
+		settings.ProximityLabels = map[string]string{peripherals.PROXIMITY_NEAR: "lobby"}
+
+		sender := delivery.NewWithSettings(zap.NewNop(), transport, settings)
+		defer sender.Close()
+
+		var failure error
+
+		sender.AddEventListener(func(evt delivery.Event) {
+			if evt.Error != nil {
+				failure = evt.Error
+			}
+		})
+
+		near := peripherals.NewMockPeripheral(gofakeit.UUID(), "mock", "near", nil, -59, -59, gofakeit.IPv4Address())
+
+		assert.NoError(t, sender.Send(notification.NewMessage(
+			notification.FOUND,
+			"entrance",
+			near,
+			[]*notification.Subscriber{createSubscriber()},
+		)), "send error")
+
+		if failure != nil {
+			return nil, failure
+		}
+
+		requests := transport.Requests()
+
+		if !assert.Len(t, requests, 1, "requests") {
+			return nil, nil
+		}
+
+		var payload map[string]interface{}
+
+		assert.NoError(t, json.Unmarshal(requests[0].Body, &payload), "payload")
+
+		return payload, nil
+	}
+
+	payload, err := send(delivery.NewDefaultSettings())
+
+	assert.NoError(t, err, "snake case")
+	assert.Equal(t, "entrance", payload[delivery.FIELD_NAME], "snake case")
+	assert.Equal(t, "lobby", payload[delivery.FIELD_PROXIMITY_LABEL], "snake case")
+
+	settings := delivery.NewDefaultSettings()
+	settings.FieldNaming = delivery.FIELD_NAMING_CAMEL_CASE
+
+	payload, err = send(settings)
+
+	assert.NoError(t, err, "camel case")
+	assert.Equal(t, "lobby", payload["proximityLabel"], "camel case")
+	assert.Equal(t, peripherals.PROXIMITY_NEAR, payload[delivery.FIELD_PROXIMITY], "single word")
+	assert.NotContains(t, payload, delivery.FIELD_PROXIMITY_LABEL, "camel case")
+
+	settings = delivery.NewDefaultSettings()
+	settings.FieldNaming = delivery.FIELD_NAMING_CAMEL_CASE
+	settings.FieldNames = map[string]string{delivery.FIELD_PROXIMITY_LABEL: "zone", delivery.FIELD_NAME: "title"}
+
+	payload, err = send(settings)
+
+	assert.NoError(t, err, "explicit names")
+	assert.Equal(t, "lobby", payload["zone"], "explicit name over the strategy")
+	assert.Equal(t, "entrance", payload["title"], "explicit name")
+	assert.NotContains(t, payload, "proximityLabel", "explicit name over the strategy")
+
+	settings = delivery.NewDefaultSettings()
+	settings.FieldNaming = "kebab-case"
+
+	_, err = send(settings)
+
+	if assert.Error(t, err, "unsupported naming") {
+		assert.Contains(t, err.Error(), delivery.ErrUnsupportedFieldNaming.Error(), "unsupported naming")
+	}
+}
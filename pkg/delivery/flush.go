@@ -0,0 +1,109 @@
+package delivery
+
+import (
+	"context"
+	"sync"
+)
+
+// Flush waits until the queued messages are delivered, sends the collected batches and attempts the scheduled retries right away,
+// ignoring their backoff. Endpoint concurrency limits still apply.
+// Retries failed again are scheduled as usual and not waited for.
+// Unlike Close it keeps the sender accepting new messages.
+func (sender *Sender) Flush(ctx context.Context) error {
+	if err := sender.waitIdle(ctx); err != nil {
+		return err
+	}
+
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+
+		sender.flushBatches()
+		sender.flushRetries()
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Counts messages waiting in the queue or being delivered by the workers
+func (sender *Sender) track(delta int) {
+	sender.busyMu.Lock()
+	defer sender.busyMu.Unlock()
+
+	sender.busy += delta
+
+	if sender.busy > 0 {
+		return
+	}
+
+	for _, idle := range sender.idle {
+		close(idle)
+	}
+
+	sender.idle = nil
+}
+
+func (sender *Sender) waitIdle(ctx context.Context) error {
+	sender.busyMu.Lock()
+
+	if sender.busy == 0 {
+		sender.busyMu.Unlock()
+		return nil
+	}
+
+	idle := make(chan struct{})
+	sender.idle = append(sender.idle, idle)
+	sender.busyMu.Unlock()
+
+	select {
+	case <-idle:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (sender *Sender) flushRetries() {
+	sender.retryMu.Lock()
+
+	if sender.retriesStopped {
+		sender.retryMu.Unlock()
+		return
+	}
+
+	due := make([]*Pending, 0, len(sender.retries))
+
+	for id, retry := range sender.retries {
+		// a timer which already fired delivers by itself
+		if !retry.timer.Stop() {
+			continue
+		}
+
+		delete(sender.retries, id)
+		due = append(due, retry.pending)
+	}
+
+	sender.retryWg.Add(len(due))
+	sender.retryMu.Unlock()
+
+	var wg sync.WaitGroup
+
+	wg.Add(len(due))
+
+	for _, pending := range due {
+		go func(pending *Pending) {
+			defer wg.Done()
+			defer sender.retryWg.Done()
+
+			sender.sendPending(pending)
+		}(pending)
+	}
+
+	wg.Wait()
+}
@@ -0,0 +1,33 @@
+package delivery
+
+// Names of built-in serializers
+const (
+	FORMAT_JSON        = "json"
+	FORMAT_FORM        = "form"
+	FORMAT_SLACK       = "slack"
+	FORMAT_CLOUDEVENTS = "cloudevents"
+	FORMAT_MSGPACK     = "msgpack"
+)
+
+const (
+	CONTENT_TYPE_JSON        = "application/json"
+	CONTENT_TYPE_FORM        = "application/x-www-form-urlencoded"
+	CONTENT_TYPE_CLOUDEVENTS = "application/cloudevents+json"
+	CONTENT_TYPE_MSGPACK     = "application/msgpack"
+)
+
+func (sender *Sender) createSerializers() map[string]Serializer {
+	serializers := map[string]Serializer{
+		FORMAT_JSON:        NewJsonSerializer(),
+		FORMAT_FORM:        NewFormSerializer(),
+		FORMAT_SLACK:       NewSlackSerializer(),
+		FORMAT_CLOUDEVENTS: NewCloudEventsSerializer(sender.settings.EventSource, sender.clock),
+		FORMAT_MSGPACK:     NewMsgpackSerializer(),
+	}
+
+	for name, serializer := range sender.settings.Serializers {
+		serializers[name] = serializer
+	}
+
+	return serializers
+}
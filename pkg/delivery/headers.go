@@ -0,0 +1,72 @@
+package delivery
+
+import (
+	"github.com/pkg/errors"
+	"strings"
+)
+
+// Characters allowed in header names besides letters and digits, see tchar of RFC 7230
+const headerNameSymbols = "!#$%&'*+-.^_`|~"
+
+func validHeaderName(name string) bool {
+	if name == "" {
+		return false
+	}
+
+	for _, c := range name {
+		if c > 127 {
+			return false
+		}
+
+		if ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z') || ('0' <= c && c <= '9') {
+			continue
+		}
+
+		if !strings.ContainsRune(headerNameSymbols, c) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Control characters but horizontal tabs would end the header line or corrupt it
+func validHeaderValue(value string) bool {
+	for _, c := range value {
+		if (c < ' ' && c != '\t') || c == 0x7f {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Drops control characters from values substituted into headers, since they come from peripherals
+func sanitizeHeaderValue(value string) string {
+	if validHeaderValue(value) {
+		return value
+	}
+
+	return strings.Map(func(c rune) rune {
+		if (c < ' ' && c != '\t') || c == 0x7f {
+			return -1
+		}
+
+		return c
+	}, value)
+}
+
+// Header names are trimmed and then must be RFC 7230 tokens, values must not contain line breaks or other control characters
+func validateHeader(name, value string) (string, error) {
+	name = strings.TrimSpace(name)
+
+	if !validHeaderName(name) {
+		return "", errors.Wrapf(ErrInvalidHeaderName, "%q", name)
+	}
+
+	if !validHeaderValue(value) {
+		return "", errors.Wrapf(ErrInvalidHeaderValue, "header %s", name)
+	}
+
+	return name, nil
+}
@@ -0,0 +1,70 @@
+package delivery
+
+import (
+	"github.com/blent/beagle/pkg/notification"
+	"net/url"
+	"time"
+)
+
+// EndpointStatus describes the most recent failed delivery to an endpoint
+type EndpointStatus struct {
+	Name      string    `json:"name"`
+	Url       string    `json:"url"`
+	Error     string    `json:"error"`
+	Category  string    `json:"category"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// EndpointHealth returns currently failing endpoints keyed by url without its credentials and query.
+// An endpoint is removed from the list by the next successful delivery.
+func (sender *Sender) EndpointHealth() map[string]EndpointStatus {
+	sender.healthMu.RLock()
+	defer sender.healthMu.RUnlock()
+
+	result := make(map[string]EndpointStatus, len(sender.health))
+
+	for key, status := range sender.health {
+		result[key] = status
+	}
+
+	return result
+}
+
+func (sender *Sender) updateHealth(endpoint *notification.Endpoint, err error) {
+	sender.healthMu.Lock()
+	defer sender.healthMu.Unlock()
+
+	key := redactUrl(endpoint.Url)
+
+	if err == nil {
+		delete(sender.health, key)
+
+		return
+	}
+
+	sender.health[key] = EndpointStatus{
+		Name:      endpoint.Name,
+		Url:       key,
+		Error:     err.Error(),
+		Category:  categorizeError(err),
+		Timestamp: sender.clock.Now(),
+	}
+}
+
+// Drops the user info, query and fragment of an endpoint url, which may carry tokens, so the url can be exposed in stats
+func redactUrl(raw string) string {
+	parsed, err := url.Parse(raw)
+
+	if err != nil {
+		// the url is not exposed at all rather than exposed with its secrets
+		return "invalid url"
+	}
+
+	parsed.User = nil
+	parsed.RawQuery = ""
+	parsed.ForceQuery = false
+	parsed.Fragment = ""
+	parsed.RawFragment = ""
+
+	return parsed.String()
+}
@@ -0,0 +1,306 @@
+package delivery
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/blent/beagle/pkg/clock"
+	"github.com/blent/beagle/pkg/notification"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Name of snapshot deliveries, they carry all present peripherals instead of a single one
+const EVENT_SNAPSHOT = "snapshot"
+
+type (
+	// Snapshot is the body of snapshot deliveries
+	Snapshot struct {
+		Event       string                   `json:"event"`
+		Timestamp   time.Time                `json:"timestamp"`
+		Peripherals []map[string]interface{} `json:"peripherals"`
+	}
+
+	HeartbeatSettings struct {
+		// Interval of snapshots, 0 disables them
+		Interval time.Duration
+		// Endpoints receiving the snapshots
+		Endpoints []*notification.Endpoint
+		Clock     clock.Clock
+	}
+
+	// Heartbeat periodically delivers a snapshot of all present peripherals to its endpoints through the sender,
+	// so a receiver can resynchronize its state regardless of missed events.
+	Heartbeat struct {
+		mu       sync.Mutex
+		logger   *zap.Logger
+		settings *HeartbeatSettings
+		presence notification.PresenceSource
+		registry notification.Registry
+		sender   *Sender
+		// endpoints of the settings which take snapshots
+		endpoints []*notification.Endpoint
+		timer     clock.Timer
+		running   bool
+	}
+)
+
+func NewDefaultHeartbeatSettings() *HeartbeatSettings {
+	return &HeartbeatSettings{
+		Endpoints: make([]*notification.Endpoint, 0),
+		Clock:     clock.New(),
+	}
+}
+
+// NewHeartbeat creates a heartbeat of the present peripherals, the registry is optional and provides their registered names
+func NewHeartbeat(logger *zap.Logger, settings *HeartbeatSettings, presence notification.PresenceSource, registry notification.Registry, sender *Sender) *Heartbeat {
+	if settings == nil {
+		settings = NewDefaultHeartbeatSettings()
+	}
+
+	endpoints := make([]*notification.Endpoint, 0, len(settings.Endpoints))
+
+	for _, endpoint := range settings.Endpoints {
+		if endpoint == nil {
+			continue
+		}
+
+		if err := sender.ValidateSnapshot(endpoint); err != nil {
+			logger.Error(
+				"Snapshot endpoint is skipped",
+				zap.String("endpoint name", endpoint.Name),
+				zap.Error(err),
+			)
+
+			continue
+		}
+
+		endpoints = append(endpoints, endpoint)
+	}
+
+	return &Heartbeat{
+		logger:    logger,
+		settings:  settings,
+		presence:  presence,
+		registry:  registry,
+		sender:    sender,
+		endpoints: endpoints,
+	}
+}
+
+func (h *Heartbeat) Start() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.running || h.settings.Interval <= 0 || len(h.endpoints) == 0 {
+		return
+	}
+
+	h.running = true
+	h.schedule()
+}
+
+// Stop cancels the next snapshot, a snapshot being delivered is finished
+func (h *Heartbeat) Stop() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.running = false
+
+	if h.timer != nil {
+		h.timer.Stop()
+		h.timer = nil
+	}
+}
+
+// Called with the lock held
+func (h *Heartbeat) schedule() {
+	h.timer = h.settings.Clock.AfterFunc(h.settings.Interval, func() {
+		h.sender.SendSnapshot(h.snapshot(), h.endpoints)
+
+		h.mu.Lock()
+		defer h.mu.Unlock()
+
+		if h.running {
+			h.schedule()
+		}
+	})
+}
+
+// Present peripherals as messages, named by the registry if they are registered
+func (h *Heartbeat) snapshot() []*notification.Message {
+	present := h.presence.Present()
+	result := make([]*notification.Message, 0, len(present))
+
+	for _, peripheral := range present {
+		name := ""
+		// a peripheral is registered unless the registry does not find it
+		registered := true
+
+		if h.registry != nil {
+			target, err := h.registry.FindTarget(peripheral.UniqueKey())
+
+			if err != nil {
+				h.logger.Error(
+					"Failed to retrieve a peripheral",
+					zap.String("key", peripheral.UniqueKey()),
+					zap.Error(err),
+				)
+			} else if target != nil {
+				name = target.Name
+			} else {
+				registered = false
+			}
+		}
+
+		result = append(result, notification.NewMessage(EVENT_SNAPSHOT, name, peripheral, nil).SetRegistered(registered))
+	}
+
+	return result
+}
+
+// SendSnapshot delivers the peripherals of the messages as a single Snapshot to every endpoint.
+// Peripherals are serialized as in other deliveries, with the endpoint fields and the sender naming applied.
+// Snapshots are not retried, the next one supersedes a failed one. Every endpoint gets an EVENT_SNAPSHOT event.
+// Snapshots are JSON posts validated against the endpoint schema, endpoints of other methods or serializers
+// fail with ErrUnsupportedSnapshot.
+func (sender *Sender) SendSnapshot(messages []*notification.Message, endpoints []*notification.Endpoint) []Event {
+	serialized := make([]map[string]interface{}, 0, len(messages))
+
+	for _, msg := range messages {
+		fields, err := sender.serializePeripheral(msg)
+
+		if err != nil {
+			sender.logger.Warn(
+				"Failed to serialize a peripheral of a snapshot",
+				zap.String("peripheral", msg.Peripheral().UniqueKey()),
+				zap.Error(err),
+			)
+
+			continue
+		}
+
+		serialized = append(serialized, fields)
+	}
+
+	events := make([]*Event, 0, len(endpoints))
+
+	for _, endpoint := range endpoints {
+		if endpoint == nil || !endpoint.IsEnabled() {
+			continue
+		}
+
+		subscriber := &notification.Subscriber{Name: endpoint.Name, Event: EVENT_SNAPSHOT, Endpoint: endpoint, Enabled: true}
+
+		start := sender.clock.Now()
+		err := sender.sendSnapshot(serialized, endpoint)
+		duration := sender.clock.Now().Sub(start)
+
+		if err != nil {
+			sender.logger.Error(
+				"Failed to deliver a snapshot",
+				zap.String("endpoint name", endpoint.Name),
+				zap.Int("peripherals", len(serialized)),
+				zap.Error(err),
+			)
+		}
+
+		err = newDeliveryError(subscriber, 1, err)
+
+		events = append(events, &Event{
+			Name:       EVENT_SNAPSHOT,
+			Timestamp:  sender.clock.Now(),
+			TargetName: endpoint.Name,
+			Subscriber: subscriber,
+			Delivered:  err == nil,
+			Error:      err,
+			Category:   categorizeError(err),
+			DryRun:     sender.settings.DryRun,
+			Duration:   duration,
+			Attempt:    1,
+		})
+	}
+
+	sender.emit(events)
+
+	result := make([]Event, len(events))
+
+	for idx, evt := range events {
+		result[idx] = *evt
+	}
+
+	return result
+}
+
+// ValidateSnapshot checks that the endpoint takes snapshots, which are posted as JSON regardless of its serializer
+func (sender *Sender) ValidateSnapshot(endpoint *notification.Endpoint) error {
+	if strings.ToUpper(endpoint.Method) != http.MethodPost || sender.serializerName(endpoint) != FORMAT_JSON {
+		return errors.Wrapf(ErrUnsupportedSnapshot, "%s", endpoint.Name)
+	}
+
+	return nil
+}
+
+func (sender *Sender) sendSnapshot(serialized []map[string]interface{}, endpoint *notification.Endpoint) error {
+	if err := sender.ValidateSnapshot(endpoint); err != nil {
+		return err
+	}
+
+	snapshot := &Snapshot{
+		Event:       EVENT_SNAPSHOT,
+		Timestamp:   sender.clock.Now().UTC(),
+		Peripherals: make([]map[string]interface{}, 0, len(serialized)),
+	}
+
+	for _, fields := range serialized {
+		renamed, err := sender.renameFields(sender.projectFields(fields, endpoint), endpoint)
+
+		if err != nil {
+			return err
+		}
+
+		snapshot.Peripherals = append(snapshot.Peripherals, renamed)
+	}
+
+	body, err := json.Marshal(snapshot)
+
+	if err != nil {
+		return err
+	}
+
+	if err := sender.validatePayload(endpoint, body); err != nil {
+		return err
+	}
+
+	reqUrl, err := expandEnv(endpoint.Url)
+
+	if err != nil {
+		return err
+	}
+
+	// a host without a scheme would be parsed as a path
+	if !strings.Contains(reqUrl, "://") {
+		reqUrl = DEFAULT_SCHEME + "://" + reqUrl
+	}
+
+	req, err := http.NewRequest(http.MethodPost, reqUrl, nil)
+
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", CONTENT_TYPE_JSON)
+	req.Body = ioutil.NopCloser(bytes.NewReader(body))
+	req.ContentLength = int64(len(body))
+
+	// placeholders of peripheral fields have no single peripheral to refer to
+	if err := setHeaders(req, endpoint.Headers, map[string]interface{}{}); err != nil {
+		return err
+	}
+
+	return sender.do(req, endpoint)
+}
@@ -0,0 +1,88 @@
+package delivery
+
+import (
+	"context"
+	"github.com/blent/beagle/pkg/notification"
+	"strconv"
+)
+
+// Limits concurrent requests to an endpoint by the capacity of its channel
+type semaphore struct {
+	url   string
+	size  int
+	slots chan struct{}
+	// Requests holding or waiting for a slot, guarded by the lock of the semaphores
+	holders int
+}
+
+func (sender *Sender) maxInFlight(endpoint *notification.Endpoint) int {
+	if endpoint.Options.MaxInFlight > 0 {
+		return endpoint.Options.MaxInFlight
+	}
+
+	return sender.settings.MaxInFlight
+}
+
+// Endpoints sharing a url keep state of their own, like limits, endpoints without an id are told by their name
+func endpointKey(endpoint *notification.Endpoint) string {
+	if endpoint.Id > 0 {
+		return strconv.FormatUint(endpoint.Id, 10)
+	}
+
+	return endpoint.Name + "|" + endpoint.Url
+}
+
+// Waits for a free slot of the endpoint, the returned function releases it.
+// Waiting counts towards the timeout of the attempt.
+func (sender *Sender) acquire(ctx context.Context, endpoint *notification.Endpoint) (func(), error) {
+	size := sender.maxInFlight(endpoint)
+
+	if size <= 0 {
+		return func() {}, nil
+	}
+
+	key := endpointKey(endpoint)
+
+	sender.inFlightMu.Lock()
+	sem, found := sender.inFlight[key]
+
+	// the limit of the endpoint was changed, requests in flight release slots of the replaced semaphore,
+	// so the new limit applies to new requests only
+	if !found || sem.size != size {
+		sem = &semaphore{url: endpoint.Url, size: size, slots: make(chan struct{}, size)}
+		sender.inFlight[key] = sem
+	}
+
+	sem.holders++
+	sender.inFlightMu.Unlock()
+
+	leave := func() {
+		sender.inFlightMu.Lock()
+		sem.holders--
+		sender.inFlightMu.Unlock()
+	}
+
+	select {
+	case sem.slots <- struct{}{}:
+		return func() {
+			<-sem.slots
+			leave()
+		}, nil
+	case <-ctx.Done():
+		leave()
+
+		return nil, ctx.Err()
+	}
+}
+
+// Drops the semaphores of the endpoint url, those with holders are kept, so forgetting never lifts a limit being enforced
+func (sender *Sender) forgetSemaphores(url string) {
+	sender.inFlightMu.Lock()
+	defer sender.inFlightMu.Unlock()
+
+	for key, sem := range sender.inFlight {
+		if sem.url == url && sem.holders == 0 {
+			delete(sender.inFlight, key)
+		}
+	}
+}
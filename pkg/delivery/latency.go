@@ -0,0 +1,87 @@
+package delivery
+
+import (
+	"go.uber.org/zap"
+	"time"
+)
+
+// LatencyStats summarize the time from detections to successful deliveries
+type LatencyStats struct {
+	// Number of successful deliveries of events with a known detection time
+	Count   uint64  `json:"count"`
+	Average float64 `json:"averageMs"`
+	Max     float64 `json:"maxMs"`
+}
+
+// Tells whether an event detected at the time is too old to be delivered
+func (sender *Sender) isStale(detectedAt time.Time) bool {
+	if sender.settings.MaxStaleness <= 0 || detectedAt.IsZero() {
+		return false
+	}
+
+	return sender.clock.Now().Sub(detectedAt) > sender.settings.MaxStaleness
+}
+
+// Returns the end-to-end latency of a successful delivery and counts it, 0 for failed ones
+func (sender *Sender) latency(detectedAt time.Time, err error) time.Duration {
+	if err != nil || detectedAt.IsZero() {
+		return 0
+	}
+
+	latency := sender.clock.Now().Sub(detectedAt)
+
+	sender.statsMu.Lock()
+	defer sender.statsMu.Unlock()
+
+	sender.latencies++
+	sender.latencyTotal += latency
+
+	if latency > sender.latencyMax {
+		sender.latencyMax = latency
+	}
+
+	return latency
+}
+
+// Called with the stats locked
+func (sender *Sender) latencyStats() LatencyStats {
+	stats := LatencyStats{
+		Count: sender.latencies,
+		Max:   float64(sender.latencyMax) / float64(time.Millisecond),
+	}
+
+	if sender.latencies > 0 {
+		stats.Average = float64(sender.latencyTotal) / float64(sender.latencies) / float64(time.Millisecond)
+	}
+
+	return stats
+}
+
+// Gives up a scheduled retry of an event which became stale while waiting for it.
+// The previous attempt announced the retry by WillRetry, so the event carries the final failure along with the skip reason.
+func (sender *Sender) dropStale(pending *Pending) {
+	sender.removePending(pending)
+	sender.countSkipped(SKIP_REASON_STALE)
+
+	err := newDeliveryError(pending.Subscriber, pending.Attempt, ErrStaleDelivery)
+
+	sender.logger.Info(
+		"Skipped to notify a subscriber for peripheral",
+		zap.String("subscriber", pending.Subscriber.Name),
+		zap.String("peripheral", pending.TargetName),
+		zap.String("correlation id", pending.CorrelationId),
+		zap.String("reason", SKIP_REASON_STALE),
+	)
+
+	sender.emit([]*Event{{
+		Name:          pending.EventName,
+		Timestamp:     sender.clock.Now(),
+		TargetName:    pending.TargetName,
+		Subscriber:    pending.Subscriber,
+		Error:         err,
+		Category:      categorizeError(err),
+		SkipReason:    SKIP_REASON_STALE,
+		DryRun:        sender.settings.DryRun,
+		CorrelationId: pending.CorrelationId,
+	}})
+}
@@ -0,0 +1,56 @@
+package delivery
+
+import (
+	"reflect"
+	"runtime"
+)
+
+// Kinds of registered listeners
+const (
+	// Added by AddEventListener, gets events one by one
+	LISTENER_KIND_EVENT = "event"
+	// Added by AddBatchEventListener, gets all events of a message at once
+	LISTENER_KIND_BATCH = "batch"
+)
+
+// ListenerInfo describes a listener registered to a sender
+type ListenerInfo struct {
+	// One of LISTENER_KIND_* constants
+	Kind string `json:"kind"`
+	// Position among the listeners of the kind, they are called in this order
+	Position int `json:"position"`
+	// Name of the listener function, e.g. main.main.func1 for a closure, empty if it cannot be resolved
+	Function string `json:"function"`
+}
+
+// Listeners describes the registered listeners, event listeners first, in the order they are called.
+// The result is a snapshot, it does not change with listeners added or removed later.
+func (sender *Sender) Listeners() []ListenerInfo {
+	infos := make([]ListenerInfo, 0, len(sender.listeners)+len(sender.batches))
+
+	for idx, listener := range sender.listeners {
+		infos = append(infos, ListenerInfo{
+			Kind:     LISTENER_KIND_EVENT,
+			Position: idx,
+			Function: functionName(listener),
+		})
+	}
+
+	for idx, listener := range sender.batches {
+		infos = append(infos, ListenerInfo{
+			Kind:     LISTENER_KIND_BATCH,
+			Position: idx,
+			Function: functionName(listener),
+		})
+	}
+
+	return infos
+}
+
+func functionName(fn interface{}) string {
+	if function := runtime.FuncForPC(reflect.ValueOf(fn).Pointer()); function != nil {
+		return function.Name()
+	}
+
+	return ""
+}
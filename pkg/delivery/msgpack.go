@@ -0,0 +1,41 @@
+package delivery
+
+import (
+	"github.com/blent/beagle/pkg/discovery/peripherals"
+	"github.com/pkg/errors"
+	"github.com/ugorji/go/codec"
+)
+
+type MsgpackSerializer struct {
+	handle *codec.MsgpackHandle
+}
+
+// NewMsgpackSerializer builds the fields as a MessagePack map, a compact binary equivalent of the JSON object.
+// Keys of maps are written in sorted order, so the same fields always make the same body.
+func NewMsgpackSerializer() *MsgpackSerializer {
+	// strings and binaries of the current spec instead of raw bytes
+	handle := &codec.MsgpackHandle{WriteExt: true}
+	handle.Canonical = true
+
+	return &MsgpackSerializer{handle}
+}
+
+func (s *MsgpackSerializer) Serialize(name string, peripheral peripherals.Peripheral, event string) ([]byte, string, error) {
+	fields, err := peripheralFields(name, peripheral, event)
+
+	if err != nil {
+		return nil, "", err
+	}
+
+	return s.SerializeFields(event, fields)
+}
+
+func (s *MsgpackSerializer) SerializeFields(event string, fields map[string]interface{}) ([]byte, string, error) {
+	var out []byte
+
+	if err := codec.NewEncoderBytes(&out, s.handle).Encode(fields); err != nil {
+		return nil, "", errors.Wrap(ErrUnsupportedValue, err.Error())
+	}
+
+	return out, CONTENT_TYPE_MSGPACK, nil
+}
@@ -0,0 +1,203 @@
+package delivery
+
+import (
+	"github.com/blent/beagle/pkg/clock"
+	"time"
+)
+
+// Option changes the default settings of a sender created by New
+type Option func(settings *Settings)
+
+// WithTimeout sets the default timeout of a single delivery attempt, 0 disables it
+func WithTimeout(timeout time.Duration) Option {
+	return func(settings *Settings) {
+		settings.Timeout = timeout
+	}
+}
+
+// WithConnectTimeout sets the default timeout of establishing a connection, 0 disables it
+func WithConnectTimeout(timeout time.Duration) Option {
+	return func(settings *Settings) {
+		settings.ConnectTimeout = timeout
+	}
+}
+
+// WithRetry sets the maximum number of attempts per subscriber and the bounds of the exponential backoff between them
+func WithRetry(maxAttempts int, backoff, maxBackoff time.Duration) Option {
+	return func(settings *Settings) {
+		settings.MaxAttempts = maxAttempts
+		settings.RetryBackoff = backoff
+		settings.RetryMaxBackoff = maxBackoff
+	}
+}
+
+// WithPendingStore persists pending retries across restarts
+func WithPendingStore(store PendingStore) Option {
+	return func(settings *Settings) {
+		settings.PendingStore = store
+	}
+}
+
+// WithDuplicates delivers every found event, not only the ones changing the presence of a peripheral
+func WithDuplicates() Option {
+	return func(settings *Settings) {
+		settings.Duplicates = true
+	}
+}
+
+// WithUnregistered delivers messages of peripherals which are not registered
+func WithUnregistered() Option {
+	return func(settings *Settings) {
+		settings.Unregistered = true
+	}
+}
+
+// WithMaxInFlight limits concurrent requests to a single endpoint, 0 disables the limit
+func WithMaxInFlight(maxInFlight int) Option {
+	return func(settings *Settings) {
+		settings.MaxInFlight = maxInFlight
+	}
+}
+
+// WithWorkers sets the number of goroutines delivering queued messages
+func WithWorkers(workers int) Option {
+	return func(settings *Settings) {
+		settings.Workers = workers
+	}
+}
+
+// WithRetryWorkers limits retry attempts made at once, so due retries do not compete with first attempts
+func WithRetryWorkers(workers int) Option {
+	return func(settings *Settings) {
+		settings.RetryWorkers = workers
+	}
+}
+
+// WithQueue sets the capacity of the delivery queue and the behavior of Send when it is full
+func WithQueue(size int, policy string) Option {
+	return func(settings *Settings) {
+		settings.QueueSize = size
+		settings.QueuePolicy = policy
+	}
+}
+
+// WithDryRun makes the sender log requests instead of sending them
+func WithDryRun() Option {
+	return func(settings *Settings) {
+		settings.DryRun = true
+	}
+}
+
+// WithFormat sets the default serializer of request bodies, one of FORMAT_* constants or a name of a custom serializer
+func WithFormat(format string) Option {
+	return func(settings *Settings) {
+		settings.Format = format
+	}
+}
+
+// WithSerializer registers a custom serializer, endpoints select it by options.serializer
+func WithSerializer(name string, serializer Serializer) Option {
+	return func(settings *Settings) {
+		if settings.Serializers == nil {
+			settings.Serializers = make(map[string]Serializer)
+		}
+
+		settings.Serializers[name] = serializer
+	}
+}
+
+// WithSchema registers a compiled schema endpoints can validate their request bodies against
+func WithSchema(name string, schema *Schema) Option {
+	return func(settings *Settings) {
+		if settings.Schemas == nil {
+			settings.Schemas = make(map[string]*Schema)
+		}
+
+		settings.Schemas[name] = schema
+	}
+}
+
+// WithProximityLabels sends a custom label of every proximity band along with the raw proximity
+func WithProximityLabels(labels map[string]string) Option {
+	return func(settings *Settings) {
+		settings.ProximityLabels = labels
+	}
+}
+
+// WithEventNames sets the names of events accepted by Send
+func WithEventNames(names ...string) Option {
+	return func(settings *Settings) {
+		settings.EventNames = names
+	}
+}
+
+// WithRequestHook sets the hook invoked for every request before it is sent
+func WithRequestHook(hook RequestHook) Option {
+	return func(settings *Settings) {
+		settings.RequestHook = hook
+	}
+}
+
+// WithAuditSink writes a record of every delivery attempt to the sink
+func WithAuditSink(sink AuditSink) Option {
+	return func(settings *Settings) {
+		settings.AuditSink = sink
+	}
+}
+
+// WithAuditSampling keeps the request of every Nth delivered attempt in the audit records, besides the failed ones
+func WithAuditSampling(rate int) Option {
+	return func(settings *Settings) {
+		settings.AuditSampleRate = rate
+	}
+}
+
+// WithEndpointState bounds per-endpoint state by the time endpoints are unused and by their number, 0 disables either bound
+func WithEndpointState(ttl time.Duration, maxEndpoints int) Option {
+	return func(settings *Settings) {
+		settings.EndpointStateTTL = ttl
+		settings.MaxTrackedEndpoints = maxEndpoints
+	}
+}
+
+// WithMaxStaleness drops deliveries of events detected longer ago than the staleness, 0 disables it
+func WithMaxStaleness(staleness time.Duration) Option {
+	return func(settings *Settings) {
+		settings.MaxStaleness = staleness
+	}
+}
+
+// WithCooldown drops notifications about a peripheral which notified within the cooldown, 0 disables it
+func WithCooldown(cooldown time.Duration) Option {
+	return func(settings *Settings) {
+		settings.Cooldown = cooldown
+	}
+}
+
+// WithQuietHours suppresses deliveries to subscribers without priority during the schedule
+func WithQuietHours(quietHours *QuietHours) Option {
+	return func(settings *Settings) {
+		settings.QuietHours = quietHours
+	}
+}
+
+// WithStrict fails deliveries of peripheral kinds without a full serialization
+func WithStrict() Option {
+	return func(settings *Settings) {
+		settings.Strict = true
+	}
+}
+
+// WithSynchronous delivers messages in the goroutine calling Send, intended for tests
+func WithSynchronous() Option {
+	return func(settings *Settings) {
+		settings.Synchronous = true
+	}
+}
+
+// WithClock replaces the time source, e.g. by clock.MockClock in tests
+func WithClock(timeSource clock.Clock) Option {
+	return func(settings *Settings) {
+		settings.Clock = timeSource
+	}
+}
@@ -0,0 +1,235 @@
+package delivery
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/blent/beagle/pkg/notification"
+	"github.com/pkg/errors"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+type (
+	// Pending is a failed delivery waiting for the next attempt.
+	// It keeps everything needed to rebuild the request, the url and header values as templates
+	// whose references to environment variables are resolved for every attempt, so persisted deliveries keep no secrets.
+	Pending struct {
+		Id          string                   `json:"id"`
+		EventName   string                   `json:"eventName"`
+		TargetName  string                   `json:"targetName"`
+		Subscriber  *notification.Subscriber `json:"subscriber"`
+		Method      string                   `json:"method"`
+		Url         string                   `json:"url"`
+		Header      http.Header              `json:"header"`
+		Body        []byte                   `json:"body"`
+		Attempt     int                      `json:"attempt"`
+		NextAttempt time.Time                `json:"nextAttempt"`
+		// Correlation id of the message, the header keeps it for the requests
+		CorrelationId string `json:"correlationId,omitempty"`
+		// Detection time of the message, zero if unknown
+		DetectedAt time.Time `json:"detectedAt"`
+		// Deadline of the context of the message, retries are given up once it passes, zero if none
+		Deadline time.Time `json:"deadline,omitempty"`
+		// Context of the message, nil for restored and replayed deliveries
+		ctx context.Context
+	}
+
+	PendingStore interface {
+		Save(pending *Pending) error
+		Remove(id string) error
+		// Load returns the deliveries it could read, along with an error describing the ones it could not
+		Load() ([]*Pending, error)
+	}
+
+	// FilePendingStore keeps every pending delivery in a separate json file
+	FilePendingStore struct {
+		mu  sync.Mutex
+		dir string
+	}
+)
+
+func NewFilePendingStore(dir string) (*FilePendingStore, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+
+	return &FilePendingStore{dir: dir}, nil
+}
+
+// Builds the request of the next attempt, references to environment variables of the url and headers are resolved now
+func (pending *Pending) request() (*http.Request, error) {
+	ctx := pending.ctx
+
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	if pending.Subscriber != nil {
+		if err := checkBodySize(pending.Subscriber.Endpoint, pending.Body); err != nil {
+			return nil, err
+		}
+	}
+
+	reqUrl, err := expandEnv(pending.Url)
+
+	if err != nil {
+		return nil, errors.Wrap(err, "url")
+	}
+
+	req, err := http.NewRequestWithContext(ctx, pending.Method, reqUrl, bytes.NewReader(pending.Body))
+
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create a new request")
+	}
+
+	header, err := resolveHeader(pending.Header)
+
+	if err != nil {
+		return nil, err
+	}
+
+	for key, values := range header {
+		req.Header[key] = values
+	}
+
+	return req, nil
+}
+
+// Bodies over options.maxBodySize of the endpoint fail before they are sent, batches of the endpoint included
+func checkBodySize(endpoint *notification.Endpoint, body []byte) error {
+	if endpoint == nil {
+		return nil
+	}
+
+	if limit := endpoint.Options.MaxBodySize; limit > 0 && uint64(len(body)) > limit {
+		return errors.Wrapf(ErrPayloadTooLarge, "%d bytes over the limit of %d bytes of endpoint %s", len(body), limit, endpoint.Name)
+	}
+
+	return nil
+}
+
+func resolveHeader(templates http.Header) (http.Header, error) {
+	header := make(http.Header, len(templates))
+
+	for key, values := range templates {
+		resolved := make([]string, len(values))
+
+		for idx, value := range values {
+			value, err := expandEnv(value)
+
+			if err != nil {
+				return nil, errors.Wrapf(err, "header %s", key)
+			}
+
+			if _, err := validateHeader(key, value); err != nil {
+				return nil, err
+			}
+
+			resolved[idx] = value
+		}
+
+		header[key] = resolved
+	}
+
+	return header, nil
+}
+
+func (store *FilePendingStore) Save(pending *Pending) error {
+	data, err := json.Marshal(pending)
+
+	if err != nil {
+		return err
+	}
+
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	// write to a temporary file first, so a crash never leaves a truncated entry
+	tmp := store.path(pending.Id) + ".tmp"
+
+	if err := ioutil.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, store.path(pending.Id))
+}
+
+func (store *FilePendingStore) Remove(id string) error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	err := os.Remove(store.path(id))
+
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	return nil
+}
+
+func (store *FilePendingStore) Load() ([]*Pending, error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	files, err := ioutil.ReadDir(store.dir)
+
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]*Pending, 0, len(files))
+	skipped := make([]string, 0)
+
+	for _, file := range files {
+		if file.IsDir() || !strings.HasSuffix(file.Name(), ".json") {
+			continue
+		}
+
+		pending, err := store.read(file.Name())
+
+		// a corrupt or truncated file is left for inspection, the others are still resumed
+		if err != nil {
+			skipped = append(skipped, fmt.Sprintf("%s: %s", file.Name(), err))
+			continue
+		}
+
+		result = append(result, pending)
+	}
+
+	if len(skipped) > 0 {
+		return result, errors.Wrap(ErrUnreadablePending, strings.Join(skipped, "; "))
+	}
+
+	return result, nil
+}
+
+func (store *FilePendingStore) read(name string) (*Pending, error) {
+	data, err := ioutil.ReadFile(filepath.Join(store.dir, name))
+
+	if err != nil {
+		return nil, err
+	}
+
+	pending := &Pending{}
+
+	if err := json.Unmarshal(data, pending); err != nil {
+		return nil, err
+	}
+
+	if pending.Id == "" || pending.Subscriber == nil || pending.Subscriber.Endpoint == nil {
+		return nil, errors.New("incomplete pending delivery")
+	}
+
+	return pending, nil
+}
+
+func (store *FilePendingStore) path(id string) string {
+	return filepath.Join(store.dir, id+".json")
+}
@@ -0,0 +1,77 @@
+package delivery
+
+import (
+	"github.com/blent/beagle/pkg/discovery/peripherals"
+	"github.com/pkg/errors"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// PeripheralSerializer adds the identity fields of a peripheral kind to the fields common for all kinds
+type PeripheralSerializer func(peripheral peripherals.Peripheral, fields map[string]interface{}) error
+
+var (
+	peripheralSerializersMu sync.RWMutex
+	peripheralSerializers   = map[string]PeripheralSerializer{
+		peripherals.PERIPHERAL_IBEACON: serializeIBeacon,
+	}
+	// Keys added by the registered serializers besides the common ones, endpoints may select them by options.fields
+	peripheralFieldKeys = map[string]bool{}
+)
+
+// RegisterPeripheralSerializer registers the identity fields of a peripheral kind, replacing a previous registration.
+// The keys the serializer adds are listed by fields, so endpoints can select them.
+// Peripherals of kinds without a registration are serialized with the common fields only,
+// and fail the delivery with -delivery-strict.
+func RegisterPeripheralSerializer(kind string, serializer PeripheralSerializer, fields ...string) {
+	if kind == "" || serializer == nil {
+		return
+	}
+
+	peripheralSerializersMu.Lock()
+	defer peripheralSerializersMu.Unlock()
+
+	peripheralSerializers[strings.ToLower(kind)] = serializer
+
+	for _, field := range fields {
+		peripheralFieldKeys[field] = true
+	}
+}
+
+// Tells whether a serialized peripheral may have the key
+func isFieldKey(key string) bool {
+	for _, field := range fieldKeys {
+		if field == key {
+			return true
+		}
+	}
+
+	peripheralSerializersMu.RLock()
+	defer peripheralSerializersMu.RUnlock()
+
+	return peripheralFieldKeys[key]
+}
+
+func getPeripheralSerializer(kind string) (PeripheralSerializer, bool) {
+	peripheralSerializersMu.RLock()
+	defer peripheralSerializersMu.RUnlock()
+
+	serializer, found := peripheralSerializers[strings.ToLower(kind)]
+
+	return serializer, found
+}
+
+func serializeIBeacon(peripheral peripherals.Peripheral, fields map[string]interface{}) error {
+	ibeacon, ok := peripheral.(*peripherals.IBeaconPeripheral)
+
+	if !ok {
+		return errors.Wrap(ErrUnableToSerializePeripheral, peripheral.UniqueKey())
+	}
+
+	fields[FIELD_UUID] = ibeacon.Uuid()
+	fields[FIELD_MAJOR] = strconv.Itoa(int(ibeacon.Major()))
+	fields[FIELD_MINOR] = strconv.Itoa(int(ibeacon.Minor()))
+
+	return nil
+}
@@ -0,0 +1,96 @@
+package delivery
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/blent/beagle/pkg/notification"
+	"github.com/pkg/errors"
+	"regexp"
+	"strings"
+)
+
+// Placeholders like {uuid} refer to the serialized fields by their canonical (snake case) names
+var placeholderPattern = regexp.MustCompile(`\{([a-z_]+)\}`)
+
+// Every field of a serialized peripheral may be referred by a placeholder
+var placeholderFields = createPlaceholderFields()
+
+func createPlaceholderFields() map[string]bool {
+	fields := make(map[string]bool, len(fieldKeys))
+
+	for _, key := range fieldKeys {
+		fields[key] = true
+	}
+
+	return fields
+}
+
+// ValidateHeaders checks header names and values, the values may refer to known fields only
+func ValidateHeaders(headers notification.Headers) error {
+	for key, value := range headers {
+		if _, err := validateHeader(key, value); err != nil {
+			return err
+		}
+
+		// environment variable references are not placeholders
+		value = envPattern.ReplaceAllString(value, "")
+
+		for _, match := range placeholderPattern.FindAllStringSubmatch(value, -1) {
+			if !placeholderFields[match[1]] {
+				return errors.Wrapf(ErrUnknownPlaceholder, "%s in header %s", match[0], key)
+			}
+		}
+	}
+
+	return nil
+}
+
+// Replaces placeholders with field values, fields missing for the event are replaced with an empty string.
+// Control characters of the values are dropped. References to environment variables are kept as they are,
+// while the values are escaped, so a field never injects a reference resolved later.
+func expandPlaceholders(value string, fields map[string]interface{}) string {
+	var result strings.Builder
+
+	last := 0
+
+	for _, loc := range envEscapePattern.FindAllStringIndex(value, -1) {
+		result.WriteString(replacePlaceholders(value[last:loc[0]], fields))
+		result.WriteString(value[loc[0]:loc[1]])
+		last = loc[1]
+	}
+
+	result.WriteString(replacePlaceholders(value[last:], fields))
+
+	return result.String()
+}
+
+func replacePlaceholders(value string, fields map[string]interface{}) string {
+	return placeholderPattern.ReplaceAllStringFunc(value, func(placeholder string) string {
+		name := placeholder[1 : len(placeholder)-1]
+
+		if !placeholderFields[name] {
+			return placeholder
+		}
+
+		field, found := fields[name]
+
+		if !found {
+			return ""
+		}
+
+		return escapeEnv(sanitizeHeaderValue(formatPlaceholder(field)))
+	})
+}
+
+// Metadata is put as a JSON object, other values as they are
+func formatPlaceholder(field interface{}) string {
+	if metadata, ok := field.(map[string]string); ok {
+		data, err := json.Marshal(metadata)
+
+		if err == nil {
+			return string(data)
+		}
+	}
+
+	return fmt.Sprintf("%v", field)
+}
@@ -0,0 +1,99 @@
+package delivery
+
+import (
+	"context"
+	"github.com/blent/beagle/pkg/notification"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+	"net/http"
+	"strings"
+)
+
+// Probe checks the endpoint is reachable without delivering a notification, e.g. before a burst of deliveries.
+// It sends a HEAD request to the endpoint url, or a GET request to options.healthPath of the endpoint host, with the endpoint headers.
+// A response with an error status fails the probe, except for a HEAD request rejected by 405 or 501, as the endpoint did answer.
+// WebSocket endpoints fail with ErrUnsupportedProbe without a request, since any frame sent to them is a message.
+// Like deliveries the probe is bounded by the endpoint timeouts and goes through the request hook,
+// but it is not retried, emits no events and leaves EndpointHealth and the stats untouched. Unlike SendTest it sends no payload.
+// The error is the one of the request, e.g. a *StatusError for an error status.
+func (sender *Sender) Probe(ctx context.Context, endpoint *notification.Endpoint) error {
+	if endpoint == nil {
+		return ErrMissedEndpoint
+	}
+
+	req, err := sender.createProbe(ctx, endpoint)
+
+	if err == nil {
+		err = sender.roundTrip(req, endpoint, false)
+	}
+
+	if err != nil && req != nil && req.Method == http.MethodHead && answered(err) {
+		err = nil
+	}
+
+	sender.logger.Info(
+		"Probed an endpoint",
+		zap.String("endpoint name", endpoint.Name),
+		zap.Bool("reachable", err == nil),
+		zap.String("reason", categorizeError(err)),
+		zap.Error(err),
+	)
+
+	return err
+}
+
+// Tells whether the endpoint is reachable by probes
+func isProbed(endpoint *notification.Endpoint) bool {
+	scheme := DEFAULT_SCHEME
+
+	if idx := strings.Index(endpoint.Url, "://"); idx > 0 {
+		scheme = strings.ToLower(endpoint.Url[:idx])
+	}
+
+	return scheme != WS_SCHEME && scheme != WSS_SCHEME
+}
+
+// Endpoints accepting POST only often reject HEAD, which still proves them reachable
+func answered(err error) bool {
+	var statusErr *StatusError
+
+	return errors.As(err, &statusErr) &&
+		(statusErr.StatusCode == http.StatusMethodNotAllowed || statusErr.StatusCode == http.StatusNotImplemented)
+}
+
+func (sender *Sender) createProbe(ctx context.Context, endpoint *notification.Endpoint) (*http.Request, error) {
+	if !isProbed(endpoint) {
+		return nil, errors.Wrapf(ErrUnsupportedProbe, "%s", endpoint.Name)
+	}
+
+	reqUrl, err := expandEnv(endpoint.Url)
+
+	if err != nil {
+		return nil, err
+	}
+
+	// a host without a scheme would be parsed as a path
+	if !strings.Contains(reqUrl, "://") {
+		reqUrl = DEFAULT_SCHEME + "://" + reqUrl
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, reqUrl, nil)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if path := endpoint.Options.HealthPath; path != "" {
+		req.Method = http.MethodGet
+		req.URL.Path = "/" + strings.TrimPrefix(path, "/")
+		req.URL.RawPath = ""
+		req.URL.RawQuery = ""
+	}
+
+	// placeholders of peripheral fields have no peripheral to refer to
+	if err := setHeaders(req, endpoint.Headers, map[string]interface{}{}); err != nil {
+		return nil, err
+	}
+
+	return req, nil
+}
@@ -0,0 +1,83 @@
+package delivery
+
+import (
+	"github.com/blent/beagle/pkg/notification"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+)
+
+// Fields identifying a peripheral, receivers usually cannot do without them
+var identityFields = []string{FIELD_KIND, FIELD_UUID, FIELD_MAJOR, FIELD_MINOR, FIELD_ADDRESS}
+
+// ValidateFields checks that options.fields and options.excludeFields of the endpoint name known fields
+func ValidateFields(endpoint *notification.Endpoint) error {
+	for _, keys := range [][]string{endpoint.Options.Fields, endpoint.Options.ExcludeFields} {
+		for _, key := range keys {
+			if !isFieldKey(key) {
+				return errors.Wrap(ErrUnknownField, key)
+			}
+		}
+	}
+
+	return nil
+}
+
+// Keeps only the fields selected by the endpoint options, the serialized map is not modified
+func (sender *Sender) projectFields(serialized map[string]interface{}, endpoint *notification.Endpoint) map[string]interface{} {
+	include := endpoint.Options.Fields
+	exclude := endpoint.Options.ExcludeFields
+
+	if len(include) == 0 && len(exclude) == 0 {
+		return serialized
+	}
+
+	projected := make(map[string]interface{}, len(serialized))
+
+	if len(include) == 0 {
+		for key, value := range serialized {
+			projected[key] = value
+		}
+	} else {
+		for _, key := range include {
+			if value, ok := serialized[key]; ok {
+				projected[key] = value
+			}
+		}
+	}
+
+	for _, key := range exclude {
+		delete(projected, key)
+	}
+
+	sender.warnMissingIdentity(serialized, projected, endpoint)
+
+	return projected
+}
+
+// Warns once per endpoint when the projection drops identity fields of the peripheral
+func (sender *Sender) warnMissingIdentity(serialized, projected map[string]interface{}, endpoint *notification.Endpoint) {
+	missing := make([]string, 0, len(identityFields))
+
+	for _, key := range identityFields {
+		_, present := serialized[key]
+		_, kept := projected[key]
+
+		if present && !kept {
+			missing = append(missing, key)
+		}
+	}
+
+	if len(missing) == 0 {
+		return
+	}
+
+	if _, warned := sender.projectionWarnings.LoadOrStore(endpoint.Name+"|"+endpoint.Url, true); warned {
+		return
+	}
+
+	sender.logger.Warn(
+		"Endpoint fields exclude identity fields of peripherals",
+		zap.String("endpoint name", endpoint.Name),
+		zap.Strings("fields", missing),
+	)
+}
@@ -0,0 +1,97 @@
+package delivery
+
+import (
+	"github.com/blent/beagle/pkg/notification"
+	"go.uber.org/zap"
+	"sync/atomic"
+)
+
+const (
+	// Send waits until the queue has a free slot
+	QUEUE_POLICY_BLOCK = "block"
+	// Send rejects a message when the queue is full
+	QUEUE_POLICY_DROP = "drop"
+	// Send evicts the oldest queued message when the queue is full
+	QUEUE_POLICY_DROP_OLDEST = "drop-oldest"
+)
+
+func (sender *Sender) startWorkers() {
+	workers := sender.settings.Workers
+
+	if workers <= 0 {
+		workers = 1
+	}
+
+	sender.wg.Add(workers)
+
+	for i := 0; i < workers; i++ {
+		go func() {
+			defer sender.wg.Done()
+
+			for msg := range sender.queue {
+				atomic.AddInt64(&sender.activeMessages, 1)
+				sender.sendBatch(msg)
+				atomic.AddInt64(&sender.activeMessages, -1)
+				sender.track(-1)
+			}
+		}()
+	}
+}
+
+func (sender *Sender) enqueue(msg *notification.Message) error {
+	sender.mu.RLock()
+	defer sender.mu.RUnlock()
+
+	if sender.closed {
+		return ErrSenderClosed
+	}
+
+	// counted before it is queued, so a worker never finishes it before it is counted
+	sender.track(1)
+
+	switch sender.settings.QueuePolicy {
+	case QUEUE_POLICY_DROP:
+		select {
+		case sender.queue <- msg:
+			return nil
+		default:
+			sender.drop(msg)
+
+			return ErrQueueFull
+		}
+	case QUEUE_POLICY_DROP_OLDEST:
+		for {
+			select {
+			case sender.queue <- msg:
+				return nil
+			default:
+			}
+
+			select {
+			case oldest := <-sender.queue:
+				sender.drop(oldest)
+			default:
+			}
+		}
+	default:
+		select {
+		case sender.queue <- msg:
+			return nil
+		case <-msg.Context().Done():
+			sender.track(-1)
+
+			return msg.Context().Err()
+		}
+	}
+}
+
+func (sender *Sender) drop(msg *notification.Message) {
+	atomic.AddUint64(&sender.dropped, 1)
+	sender.track(-1)
+
+	sender.logger.Warn(
+		"Delivery queue is full, dropped a message",
+		zap.String("event", msg.EventName()),
+		zap.String("peripheral", msg.TargetName()),
+	)
+}
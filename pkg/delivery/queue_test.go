@@ -0,0 +1,105 @@
+package delivery_test
+
+import (
+	"context"
+	"errors"
+	"github.com/blent/beagle/pkg/delivery"
+	"github.com/blent/beagle/pkg/notification"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Starts a sender of a single worker and a single queue slot, whose transport holds requests until released
+func createBlockedSender(policy string) (*delivery.Sender, *sync.Mutex, *[]string, chan struct{}, chan struct{}) {
+	var mu sync.Mutex
+
+	delivered := make([]string, 0, 3)
+	started := make(chan struct{}, 3)
+	release := make(chan struct{})
+
+	transport := delivery.NewMockTransport(func(req *http.Request) error {
+		started <- struct{}{}
+		<-release
+
+		mu.Lock()
+		delivered = append(delivered, req.URL.Path)
+		mu.Unlock()
+
+		return nil
+	})
+
+	sender := delivery.New(
+		zap.NewNop(),
+		transport,
+		delivery.WithWorkers(1),
+		delivery.WithQueue(1, policy),
+	)
+
+	return sender, &mu, &delivered, started, release
+}
+
+func createQueuedMessage(path string) *notification.Message {
+	subscriber := createSubscriber()
+	subscriber.Endpoint.Url = "http://localhost" + path
+
+	return notification.NewMessage(notification.FOUND, path, createPeripheral(), []*notification.Subscriber{subscriber})
+}
+
+func TestSenderQueuePolicies(t *testing.T) {
+	sender, mu, delivered, started, release := createBlockedSender(delivery.QUEUE_POLICY_DROP)
+
+	// the worker holds the first message and the second one takes the only slot
+	assert.NoError(t, sender.Send(createQueuedMessage("/first")), "send error")
+	<-started
+	assert.NoError(t, sender.Send(createQueuedMessage("/second")), "send error")
+
+	err := sender.Send(createQueuedMessage("/third"))
+
+	assert.True(t, errors.Is(err, delivery.ErrQueueFull), "full queue")
+	assert.Equal(t, uint64(1), sender.Stats().Dropped, "dropped")
+	assert.Equal(t, 1, sender.Stats().QueueDepth, "queue depth")
+
+	close(release)
+	sender.Close()
+
+	mu.Lock()
+	assert.Equal(t, []string{"/first", "/second"}, *delivered, "delivered")
+	mu.Unlock()
+
+	sender, mu, delivered, started, release = createBlockedSender(delivery.QUEUE_POLICY_DROP_OLDEST)
+
+	assert.NoError(t, sender.Send(createQueuedMessage("/first")), "send error")
+	<-started
+	assert.NoError(t, sender.Send(createQueuedMessage("/second")), "send error")
+	assert.NoError(t, sender.Send(createQueuedMessage("/third")), "evicts the oldest")
+	assert.Equal(t, uint64(1), sender.Stats().Dropped, "dropped")
+
+	close(release)
+	sender.Close()
+
+	mu.Lock()
+	assert.Equal(t, []string{"/first", "/third"}, *delivered, "delivered")
+	mu.Unlock()
+
+	sender, _, _, started, release = createBlockedSender(delivery.QUEUE_POLICY_BLOCK)
+
+	assert.NoError(t, sender.Send(createQueuedMessage("/first")), "send error")
+	<-started
+	assert.NoError(t, sender.Send(createQueuedMessage("/second")), "send error")
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*50)
+	defer cancel()
+
+	// a blocked send gives up with its context
+	err = sender.SendContext(ctx, createQueuedMessage("/third"))
+
+	assert.True(t, errors.Is(err, context.DeadlineExceeded), "blocked send")
+	assert.Equal(t, uint64(0), sender.Stats().Dropped, "dropped")
+
+	close(release)
+	sender.Close()
+}
@@ -0,0 +1,87 @@
+package delivery
+
+import (
+	"github.com/pkg/errors"
+	"strings"
+	"time"
+)
+
+type (
+	// QuietWindow is a daily time range given by offsets from midnight.
+	// A window ending before its start spans midnight.
+	QuietWindow struct {
+		Start time.Duration
+		End   time.Duration
+	}
+
+	QuietHours struct {
+		Windows []QuietWindow
+		// Timezone of the windows, UTC if nil
+		Location *time.Location
+	}
+)
+
+// ParseQuietWindow parses a range like "22:00-07:00"
+func ParseQuietWindow(value string) (QuietWindow, error) {
+	bounds := strings.Split(strings.TrimSpace(value), "-")
+
+	if len(bounds) != 2 {
+		return QuietWindow{}, errors.Wrap(ErrInvalidQuietHours, value)
+	}
+
+	start, err := parseTimeOfDay(bounds[0])
+
+	if err != nil {
+		return QuietWindow{}, errors.Wrap(ErrInvalidQuietHours, value)
+	}
+
+	end, err := parseTimeOfDay(bounds[1])
+
+	if err != nil {
+		return QuietWindow{}, errors.Wrap(ErrInvalidQuietHours, value)
+	}
+
+	return QuietWindow{start, end}, nil
+}
+
+func parseTimeOfDay(value string) (time.Duration, error) {
+	t, err := time.Parse("15:04", strings.TrimSpace(value))
+
+	if err != nil {
+		return 0, err
+	}
+
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+func (window QuietWindow) contains(offset time.Duration) bool {
+	if window.Start <= window.End {
+		return offset >= window.Start && offset < window.End
+	}
+
+	return offset >= window.Start || offset < window.End
+}
+
+// Contains tells whether the time falls into any of the windows, a nil schedule never does
+func (quiet *QuietHours) Contains(t time.Time) bool {
+	if quiet == nil {
+		return false
+	}
+
+	location := quiet.Location
+
+	if location == nil {
+		location = time.UTC
+	}
+
+	t = t.In(location)
+	offset := time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute + time.Duration(t.Second())*time.Second
+
+	for _, window := range quiet.Windows {
+		if window.contains(offset) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,90 @@
+package delivery
+
+import (
+	"math"
+	"time"
+)
+
+// States of persistent connections reported by stateful transports
+const (
+	// Connection was established
+	CONNECTION_STATE_CONNECTED = "connected"
+	// Established connection was closed by the peer or broken by a failed write
+	CONNECTION_STATE_LOST = "lost"
+	// Connection attempt failed, the next one is made after the reconnection delay
+	CONNECTION_STATE_FAILED = "failed"
+)
+
+// Name of synthetic events reporting connection state changes of stateful transports
+const EVENT_CONNECTION = "connection"
+
+type (
+	// ReconnectPolicy governs how stateful transports reestablish their connections.
+	// It is independent of the retries of deliveries, which are governed by the sender settings.
+	ReconnectPolicy struct {
+		// Delay after the first failed connection attempt, doubled for every next one
+		Initial time.Duration
+		// Upper bound of the delay, 0 disables it, though the delay still stops doubling before it overflows
+		Max time.Duration
+		// Fraction of the delay by which it is randomly moved earlier or later, so instances do not reconnect at once.
+		// 0 disables it.
+		Jitter float64
+	}
+
+	// ConnectionChange describes a connection state change of a stateful transport
+	ConnectionChange struct {
+		Url       string
+		State     string
+		Timestamp time.Time
+		// Number of consecutive failed connection attempts
+		Failures int
+		// Delay before the next connection attempt of a failed connection
+		RetryIn time.Duration
+		Error   error
+	}
+
+	ConnectionListener func(change ConnectionChange)
+)
+
+func NewDefaultReconnectPolicy() *ReconnectPolicy {
+	return &ReconnectPolicy{
+		Initial: time.Second,
+		Max:     time.Minute,
+	}
+}
+
+// Returns the delay before the next attempt after the number of consecutive failures.
+// random returns numbers in [0, 1).
+func (policy *ReconnectPolicy) delay(failures int, random func() float64) time.Duration {
+	delay := policy.Initial
+
+	// stops short of overflowing, even when moved later by the largest jitter
+	for i := 1; i < failures && (policy.Max <= 0 || delay < policy.Max) && delay <= math.MaxInt64/4; i++ {
+		delay *= 2
+	}
+
+	if policy.Max > 0 && delay > policy.Max {
+		delay = policy.Max
+	}
+
+	if policy.Jitter <= 0 {
+		return delay
+	}
+
+	spread := float64(delay) * policy.Jitter
+
+	return delay + time.Duration(spread*(2*random()-1))
+}
+
+// EmitConnectionChange surfaces a connection state change as a synthetic EVENT_CONNECTION event to the listeners of the sender.
+// It is a ConnectionListener, so it can be registered by OnConnectionChange of stateful transports.
+// Such events are neither deliveries nor failures of deliveries and are not counted in the stats.
+func (sender *Sender) EmitConnectionChange(change ConnectionChange) {
+	sender.emit([]*Event{{
+		Name:       EVENT_CONNECTION,
+		Timestamp:  change.Timestamp,
+		TargetName: change.Url,
+		Category:   categorizeError(change.Error),
+		Connection: &change,
+	}})
+}
@@ -0,0 +1,203 @@
+package delivery
+
+import (
+	"bufio"
+	"encoding/json"
+	"github.com/blent/beagle/pkg/notification"
+	"go.uber.org/zap"
+	"io"
+	"net/http"
+	"os"
+	"time"
+)
+
+// AuditFilter selects audit records to replay, zero fields match any record
+type AuditFilter struct {
+	// Records written before From or after To are skipped
+	From time.Time
+	To   time.Time
+	// Name of the endpoint of the records
+	Endpoint string
+	// One of AUDIT_OUTCOME_* constants
+	Outcome string
+}
+
+func (filter *AuditFilter) Matches(record *AuditRecord) bool {
+	if filter == nil {
+		return true
+	}
+
+	if !filter.From.IsZero() && record.Timestamp.Before(filter.From) {
+		return false
+	}
+
+	if !filter.To.IsZero() && record.Timestamp.After(filter.To) {
+		return false
+	}
+
+	if filter.Endpoint != "" && record.Endpoint != filter.Endpoint {
+		return false
+	}
+
+	return filter.Outcome == "" || record.Outcome == filter.Outcome
+}
+
+// ReadAuditRecords reads JSON lines written by FileAuditSink and returns the records matching the filter.
+// An incomplete last line, left by a crash, is ignored.
+func ReadAuditRecords(reader io.Reader, filter *AuditFilter) ([]*AuditRecord, error) {
+	buffered := bufio.NewReader(reader)
+	result := make([]*AuditRecord, 0)
+
+	for {
+		line, err := buffered.ReadBytes('\n')
+
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+
+		complete := err == nil
+
+		if len(line) > 0 {
+			record := &AuditRecord{}
+
+			if jsonErr := json.Unmarshal(line, record); jsonErr != nil {
+				if !complete {
+					break
+				}
+
+				return nil, jsonErr
+			}
+
+			if filter.Matches(record) {
+				result = append(result, record)
+			}
+		}
+
+		if !complete {
+			break
+		}
+	}
+
+	return result, nil
+}
+
+func ReadAuditFile(path string, filter *AuditFilter) ([]*AuditRecord, error) {
+	file, err := os.Open(path)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer file.Close()
+
+	return ReadAuditRecords(file, filter)
+}
+
+// Replay sends the requests of the records again, e.g. the failed ones after their endpoint is fixed.
+// The request is rebuilt from record.Request as it was sent: the same method, url, headers and body, the request hook is applied again.
+// Redacted credential headers are dropped, so the request hook has to set them for endpoints requiring them.
+// The endpoint is made up of the record endpoint name and url, so endpoint options do not apply.
+// Every record gets a single attempt without retries and an event flagged as Replayed,
+// a failed replay is audited with its request again. Records without a request, e.g. delivered ones, are skipped.
+func (sender *Sender) Replay(records []*AuditRecord) []Event {
+	events := make([]*Event, 0, len(records))
+
+	for _, record := range records {
+		if record.Request == nil {
+			sender.logger.Warn(
+				"Audit record has no request to replay",
+				zap.String("subscriber", record.Subscriber),
+				zap.String("correlation id", record.CorrelationId),
+			)
+
+			continue
+		}
+
+		pending := &Pending{
+			EventName:  record.Event,
+			TargetName: record.Target,
+			Subscriber: &notification.Subscriber{
+				Name:     record.Subscriber,
+				Event:    record.Event,
+				Endpoint: &notification.Endpoint{Name: record.Endpoint, Url: record.Url},
+				Enabled:  true,
+			},
+			Method:        record.Request.Method,
+			Url:           record.Request.Url,
+			Header:        replayHeader(record.Request.Header),
+			Body:          record.Request.Body,
+			Attempt:       1,
+			CorrelationId: record.CorrelationId,
+		}
+
+		start := sender.clock.Now()
+		req, err := pending.request()
+
+		if err == nil {
+			err = sender.do(req, pending.Subscriber.Endpoint)
+		}
+
+		duration := sender.clock.Now().Sub(start)
+
+		if err == nil {
+			sender.logger.Info(
+				"Succeeded to replay a delivery",
+				zap.String("subscriber", record.Subscriber),
+				zap.String("peripheral", record.Target),
+				zap.String("correlation id", record.CorrelationId),
+			)
+		} else {
+			sender.logger.Info(
+				"Failed to replay a delivery",
+				zap.String("subscriber", record.Subscriber),
+				zap.String("peripheral", record.Target),
+				zap.String("correlation id", record.CorrelationId),
+				zap.String("reason", categorizeError(err)),
+				zap.Error(err),
+			)
+		}
+
+		err = newDeliveryError(pending.Subscriber, 1, err)
+
+		events = append(events, &Event{
+			Name:          record.Event,
+			Timestamp:     sender.clock.Now(),
+			TargetName:    record.Target,
+			Subscriber:    pending.Subscriber,
+			Delivered:     err == nil,
+			Error:         err,
+			Category:      categorizeError(err),
+			DryRun:        sender.settings.DryRun,
+			Duration:      duration,
+			Attempt:       1,
+			CorrelationId: record.CorrelationId,
+			Replayed:      true,
+			request:       pending,
+		})
+	}
+
+	sender.emit(events)
+
+	result := make([]Event, len(events))
+
+	for idx, evt := range events {
+		result[idx] = *evt
+	}
+
+	return result
+}
+
+// Headers of an audited request without the redacted ones
+func replayHeader(header http.Header) http.Header {
+	replayed := make(http.Header, len(header))
+
+	for name, values := range header {
+		if len(values) == 1 && values[0] == AUDIT_REDACTED {
+			continue
+		}
+
+		replayed[name] = values
+	}
+
+	return replayed
+}
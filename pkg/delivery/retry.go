@@ -0,0 +1,328 @@
+package delivery
+
+import (
+	"context"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// Schedules another attempt of a failed delivery, returns false if there are no attempts left
+func (sender *Sender) retry(pending *Pending, err error) bool {
+	if pending.Attempt >= sender.settings.MaxAttempts {
+		sender.removePending(pending)
+
+		return false
+	}
+
+	delay, found := sender.retryAfter(err)
+
+	if !found {
+		delay = sender.backoff(pending.Attempt)
+	}
+
+	pending.NextAttempt = sender.clock.Now().Add(delay)
+
+	// no point in waiting for an attempt past the deadline
+	if ctxErr := sender.contextErr(pending, pending.NextAttempt); ctxErr != nil {
+		sender.giveUp(pending, ctxErr, false)
+
+		return false
+	}
+
+	if sender.settings.PendingStore != nil {
+		if err := sender.settings.PendingStore.Save(pending); err != nil {
+			sender.logger.Error(
+				"Failed to persist a pending delivery",
+				zap.String("subscriber", pending.Subscriber.Name),
+				zap.String("peripheral", pending.TargetName),
+				zap.Error(err),
+			)
+		}
+	}
+
+	// a retry of a closed sender is resumed after restart only from the store
+	return sender.schedule(pending) || sender.settings.PendingStore != nil
+}
+
+// Returns false if retries are stopped, the delivery is then left to the store
+func (sender *Sender) schedule(pending *Pending) bool {
+	sender.retryMu.Lock()
+	defer sender.retryMu.Unlock()
+
+	// pending deliveries stay in the store and are resumed after restart
+	if sender.retriesStopped {
+		return false
+	}
+
+	timer := sender.clock.AfterFunc(pending.NextAttempt.Sub(sender.clock.Now()), func() {
+		sender.retryMu.Lock()
+
+		if sender.retriesStopped {
+			sender.retryMu.Unlock()
+			return
+		}
+
+		delete(sender.retries, pending.Id)
+		sender.retryWg.Add(1)
+		sender.retryMu.Unlock()
+
+		defer sender.retryWg.Done()
+
+		sender.sendPending(pending)
+	})
+
+	sender.retries[pending.Id] = &scheduledRetry{timer, pending}
+
+	return true
+}
+
+// Waits for a free slot of retry attempts, the returned function releases it
+func (sender *Sender) acquireRetry() func() {
+	if sender.retrySlots == nil {
+		atomic.AddInt64(&sender.retryActive, 1)
+
+		return func() { atomic.AddInt64(&sender.retryActive, -1) }
+	}
+
+	atomic.AddInt64(&sender.retryWaiting, 1)
+	sender.retrySlots <- struct{}{}
+	atomic.AddInt64(&sender.retryWaiting, -1)
+	atomic.AddInt64(&sender.retryActive, 1)
+
+	return func() {
+		atomic.AddInt64(&sender.retryActive, -1)
+		<-sender.retrySlots
+	}
+}
+
+func (sender *Sender) sendPending(pending *Pending) {
+	release := sender.acquireRetry()
+	defer release()
+
+	// waiting for a slot may have made it stale
+	if sender.isStale(pending.DetectedAt) {
+		sender.dropStale(pending)
+		return
+	}
+
+	if err := sender.contextErr(pending, sender.clock.Now()); err != nil {
+		sender.giveUp(pending, err, true)
+		return
+	}
+
+	pending.Attempt++
+
+	start := sender.clock.Now()
+	req, err := pending.request()
+
+	if err == nil {
+		err = sender.do(req, pending.Subscriber.Endpoint)
+	}
+
+	duration := sender.clock.Now().Sub(start)
+	retrying := false
+
+	if err == nil {
+		sender.removePending(pending)
+
+		sender.logger.Info(
+			"Succeeded to notify a subscriber for peripheral",
+			zap.String("subscriber", pending.Subscriber.Name),
+			zap.String("peripheral", pending.TargetName),
+			zap.String("correlation id", pending.CorrelationId),
+			zap.Int("attempt", pending.Attempt),
+		)
+	} else {
+		sender.logger.Info(
+			"Failed to notify a subscriber for peripheral",
+			zap.String("subscriber", pending.Subscriber.Name),
+			zap.String("peripheral", pending.TargetName),
+			zap.String("correlation id", pending.CorrelationId),
+			zap.Int("attempt", pending.Attempt),
+			zap.String("reason", categorizeError(err)),
+			zap.Error(err),
+		)
+
+		retrying = sender.retry(pending, err)
+	}
+
+	err = newDeliveryError(pending.Subscriber, pending.Attempt, err)
+
+	sender.emit([]*Event{{
+		Name:          pending.EventName,
+		Timestamp:     sender.clock.Now(),
+		TargetName:    pending.TargetName,
+		Subscriber:    pending.Subscriber,
+		Delivered:     err == nil,
+		Error:         err,
+		Category:      categorizeError(err),
+		DryRun:        sender.settings.DryRun,
+		Duration:      duration,
+		Latency:       sender.latency(pending.DetectedAt, err),
+		Attempt:       pending.Attempt,
+		WillRetry:     retrying,
+		CorrelationId: pending.CorrelationId,
+		request:       pending,
+	}})
+}
+
+// Error of the context of the pending delivery, also context.DeadlineExceeded once the time reaches its deadline.
+// The deadline is compared with the sender clock, so it applies to restored deliveries without a context as well.
+func (sender *Sender) contextErr(pending *Pending, at time.Time) error {
+	if pending.ctx != nil && pending.ctx.Err() != nil {
+		return pending.ctx.Err()
+	}
+
+	if !pending.Deadline.IsZero() && !at.Before(pending.Deadline) {
+		return context.DeadlineExceeded
+	}
+
+	return nil
+}
+
+// Gives up retries of a delivery whose context is done or whose sender is closed, a scheduled retry which is due gets a failed event
+func (sender *Sender) giveUp(pending *Pending, err error, due bool) {
+	sender.removePending(pending)
+
+	sender.logger.Info(
+		"Gave up retries to notify a subscriber for peripheral",
+		zap.String("subscriber", pending.Subscriber.Name),
+		zap.String("peripheral", pending.TargetName),
+		zap.String("correlation id", pending.CorrelationId),
+		zap.Int("attempt", pending.Attempt),
+		zap.Error(err),
+	)
+
+	if !due {
+		return
+	}
+
+	err = newDeliveryError(pending.Subscriber, pending.Attempt, err)
+
+	sender.emit([]*Event{{
+		Name:          pending.EventName,
+		Timestamp:     sender.clock.Now(),
+		TargetName:    pending.TargetName,
+		Subscriber:    pending.Subscriber,
+		Error:         err,
+		Category:      categorizeError(err),
+		DryRun:        sender.settings.DryRun,
+		CorrelationId: pending.CorrelationId,
+	}})
+}
+
+func (sender *Sender) removePending(pending *Pending) {
+	if sender.settings.PendingStore == nil {
+		return
+	}
+
+	if err := sender.settings.PendingStore.Remove(pending.Id); err != nil {
+		sender.logger.Error(
+			"Failed to remove a pending delivery",
+			zap.String("id", pending.Id),
+			zap.Error(err),
+		)
+	}
+}
+
+// Resumes pending deliveries left by the previous run
+func (sender *Sender) restorePending() {
+	if sender.settings.PendingStore == nil {
+		return
+	}
+
+	list, err := sender.settings.PendingStore.Load()
+
+	// the deliveries which were read are resumed anyway
+	if err != nil {
+		sender.logger.Error("Failed to load pending deliveries", zap.Error(err))
+	}
+
+	for _, pending := range list {
+		sender.schedule(pending)
+	}
+
+	if len(list) > 0 {
+		sender.logger.Info("Resumed pending deliveries", zap.Int("count", len(list)))
+	}
+}
+
+// Cancels scheduled retries, which are final failures unless the store keeps them for the next sender
+func (sender *Sender) stopRetries() {
+	sender.retryMu.Lock()
+	sender.retriesStopped = true
+
+	cancelled := make([]*Pending, 0, len(sender.retries))
+
+	for id, retry := range sender.retries {
+		retry.timer.Stop()
+		delete(sender.retries, id)
+		cancelled = append(cancelled, retry.pending)
+	}
+
+	sender.retryMu.Unlock()
+
+	sender.retryWg.Wait()
+
+	if sender.settings.PendingStore != nil {
+		return
+	}
+
+	for _, pending := range cancelled {
+		sender.giveUp(pending, ErrSenderClosed, true)
+	}
+}
+
+// Delay requested by the endpoint in the Retry-After header, capped by the max backoff
+func (sender *Sender) retryAfter(err error) (time.Duration, bool) {
+	var statusErr *StatusError
+
+	if !errors.As(err, &statusErr) || statusErr.Header == nil {
+		return 0, false
+	}
+
+	value := strings.TrimSpace(statusErr.Header.Get("Retry-After"))
+
+	if value == "" {
+		return 0, false
+	}
+
+	var delay time.Duration
+
+	if seconds, parseErr := strconv.Atoi(value); parseErr == nil {
+		delay = time.Duration(seconds) * time.Second
+	} else if date, parseErr := http.ParseTime(value); parseErr == nil {
+		delay = date.Sub(sender.clock.Now())
+	} else {
+		return 0, false
+	}
+
+	if delay < 0 {
+		delay = 0
+	}
+
+	if sender.settings.RetryMaxBackoff > 0 && delay > sender.settings.RetryMaxBackoff {
+		delay = sender.settings.RetryMaxBackoff
+	}
+
+	return delay, true
+}
+
+func (sender *Sender) backoff(attempt int) time.Duration {
+	delay := sender.settings.RetryBackoff
+
+	for i := 1; i < attempt; i++ {
+		delay *= 2
+
+		if sender.settings.RetryMaxBackoff > 0 && delay >= sender.settings.RetryMaxBackoff {
+			return sender.settings.RetryMaxBackoff
+		}
+	}
+
+	return delay
+}
@@ -0,0 +1,77 @@
+package delivery
+
+import (
+	"github.com/blent/beagle/pkg/discovery/peripherals"
+	"github.com/blent/beagle/pkg/notification"
+	"github.com/pkg/errors"
+)
+
+var proximityBands = map[string]bool{
+	peripherals.PROXIMITY_IMMEDIATE: true,
+	peripherals.PROXIMITY_NEAR:      true,
+	peripherals.PROXIMITY_FAR:       true,
+}
+
+// ValidateRouting checks proximity conditions and the condition expression of the endpoint
+func ValidateRouting(endpoint *notification.Endpoint) error {
+	for _, band := range endpoint.Options.Proximity {
+		if !proximityBands[band] {
+			return errors.Wrap(ErrUnsupportedProximity, band)
+		}
+	}
+
+	distance := endpoint.Options.Distance
+
+	if distance != nil && (distance.Min < 0 || distance.Max < 0 || (distance.Max > 0 && distance.Min > distance.Max)) {
+		return errors.Wrapf(ErrInvalidDistanceRange, "%v-%v", distance.Min, distance.Max)
+	}
+
+	if condition := endpoint.Options.Condition; condition != "" {
+		if _, err := compileCondition(condition); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Whether the current proximity of the peripheral meets the conditions of the endpoint
+func matchesProximity(msg *notification.Message, endpoint *notification.Endpoint) bool {
+	options := endpoint.Options
+
+	if len(options.Proximity) == 0 && options.Distance == nil {
+		return true
+	}
+
+	peripheral := msg.Peripheral()
+
+	// nothing to match against, the delivery fails on serialization instead
+	if peripheral == nil {
+		return true
+	}
+
+	if len(options.Proximity) > 0 {
+		matched := false
+
+		for _, band := range options.Proximity {
+			if band == peripheral.Proximity() {
+				matched = true
+				break
+			}
+		}
+
+		if !matched {
+			return false
+		}
+	}
+
+	if distance := options.Distance; distance != nil {
+		accuracy := peripheral.Accuracy()
+
+		if accuracy < distance.Min || (distance.Max > 0 && accuracy > distance.Max) {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,71 @@
+package delivery
+
+import (
+	"github.com/blent/beagle/pkg/notification"
+	"github.com/pkg/errors"
+	"github.com/xeipuuv/gojsonschema"
+	"net/http"
+	"strings"
+)
+
+// Schema is a compiled JSON Schema validating request bodies of endpoints which reference it by options.schema
+type Schema struct {
+	compiled *gojsonschema.Schema
+}
+
+// CompileSchema parses a JSON Schema once, so validating a payload does not parse it again
+func CompileSchema(data []byte) (*Schema, error) {
+	compiled, err := gojsonschema.NewSchema(gojsonschema.NewBytesLoader(data))
+
+	if err != nil {
+		return nil, errors.Wrapf(ErrInvalidSchema, "%s", err)
+	}
+
+	return &Schema{compiled}, nil
+}
+
+// Validate fails with ErrSchemaViolation listing the values not conforming to the schema
+func (schema *Schema) Validate(body []byte) error {
+	result, err := schema.compiled.Validate(gojsonschema.NewBytesLoader(body))
+
+	if err != nil {
+		return errors.Wrapf(ErrSchemaViolation, "payload is not json: %s", err)
+	}
+
+	if result.Valid() {
+		return nil
+	}
+
+	reasons := make([]string, 0, len(result.Errors()))
+
+	for _, violation := range result.Errors() {
+		reasons = append(reasons, violation.String())
+	}
+
+	return errors.Wrap(ErrSchemaViolation, strings.Join(reasons, "; "))
+}
+
+// ValidateSchema checks that the schema of the endpoint is registered and its request bodies are JSON.
+// Schemas do not apply to requests without a body or to form and msgpack bodies.
+func (sender *Sender) ValidateSchema(endpoint *notification.Endpoint) error {
+	name := endpoint.Options.Schema
+
+	if name == "" {
+		return nil
+	}
+
+	if schema, ok := sender.settings.Schemas[name]; !ok || schema == nil {
+		return errors.Wrapf(ErrUnknownSchema, "'%s'", name)
+	}
+
+	if strings.ToUpper(endpoint.Method) != http.MethodPost {
+		return errors.Wrapf(ErrUnsupportedSchema, "%s", endpoint.Name)
+	}
+
+	switch sender.serializerName(endpoint) {
+	case FORMAT_FORM, FORMAT_MSGPACK:
+		return errors.Wrapf(ErrUnsupportedSchema, "%s", endpoint.Name)
+	}
+
+	return nil
+}
@@ -0,0 +1,94 @@
+package delivery
+
+import (
+	"context"
+	"github.com/blent/beagle/pkg/discovery/peripherals"
+	"github.com/blent/beagle/pkg/notification"
+	"go.uber.org/zap"
+	"net/http"
+)
+
+const (
+	// Field marking payloads of test deliveries, always sent with true
+	FIELD_TEST = "test"
+
+	TEST_SUBSCRIBER_NAME = "test"
+	TEST_PERIPHERAL_NAME = "test"
+)
+
+// SendTest delivers a found event of a fake iBeacon to the endpoint and returns its outcome, e.g. to check connectivity.
+// The payload has FIELD_TEST set to true. Unlike Send it ignores the endpoint conditions, quiet hours and enabled flag,
+// makes a single attempt without retries and does not emit the event to listeners.
+func (sender *Sender) SendTest(ctx context.Context, endpoint *notification.Endpoint) (Event, error) {
+	if endpoint == nil {
+		return Event{}, ErrMissedEndpoint
+	}
+
+	subscriber := &notification.Subscriber{
+		Name:     TEST_SUBSCRIBER_NAME,
+		Event:    notification.FOUND,
+		Endpoint: endpoint,
+		Enabled:  true,
+	}
+
+	peripheral, err := createTestPeripheral()
+
+	if err != nil {
+		return Event{}, err
+	}
+
+	msg := notification.NewMessage(notification.FOUND, TEST_PERIPHERAL_NAME, peripheral, []*notification.Subscriber{subscriber})
+
+	if id, idErr := notification.GenerateId(); idErr == nil {
+		msg.SetCorrelationId(id)
+	}
+
+	start := sender.clock.Now()
+	pending, err := sender.prepareRequest(msg, subscriber, map[string]interface{}{FIELD_TEST: true})
+
+	if err == nil {
+		pending.ctx = ctx
+
+		var req *http.Request
+
+		if req, err = pending.request(); err == nil {
+			err = sender.do(req, endpoint)
+		}
+	}
+
+	if err != nil {
+		err = newDeliveryError(subscriber, 1, err)
+	}
+
+	sender.logger.Info(
+		"Sent a test notification",
+		zap.String("endpoint name", endpoint.Name),
+		zap.String("correlation id", msg.CorrelationId()),
+		zap.Error(err),
+	)
+
+	return Event{
+		Name:          msg.EventName(),
+		Timestamp:     sender.clock.Now(),
+		TargetName:    msg.TargetName(),
+		Subscriber:    subscriber,
+		Delivered:     err == nil,
+		Error:         err,
+		Category:      categorizeError(err),
+		DryRun:        sender.settings.DryRun,
+		Duration:      sender.clock.Now().Sub(start),
+		CorrelationId: msg.CorrelationId(),
+	}, err
+}
+
+// An iBeacon with a zero uuid, major and minor
+func createTestPeripheral() (peripherals.Peripheral, error) {
+	data := make([]byte, 25)
+	data[0] = 0x4c
+	data[2] = 0x02
+	data[3] = 0x15
+	// measured power at 1 meter
+	data[24] = 0xc5
+
+	return peripherals.NewIBeaconPeripheral(TEST_PERIPHERAL_NAME, data, -59, -59, "")
+}
@@ -0,0 +1,151 @@
+package delivery
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/blent/beagle/pkg/discovery/peripherals"
+	"github.com/blent/beagle/pkg/notification"
+	"github.com/pkg/errors"
+	"net/url"
+	"strings"
+)
+
+type (
+	// Serializer builds request bodies, endpoints select one by options.serializer
+	Serializer interface {
+		Serialize(name string, peripheral peripherals.Peripheral, event string) ([]byte, string, error)
+	}
+
+	// FieldsSerializer is implemented by serializers working on the serialized fields.
+	// The sender prefers it over Serialize, so that field naming settings apply to the body.
+	FieldsSerializer interface {
+		Serializer
+		SerializeFields(event string, fields map[string]interface{}) ([]byte, string, error)
+	}
+
+	JsonSerializer  struct{}
+	FormSerializer  struct{}
+	SlackSerializer struct{}
+)
+
+func NewJsonSerializer() *JsonSerializer {
+	return &JsonSerializer{}
+}
+
+func (s *JsonSerializer) Serialize(name string, peripheral peripherals.Peripheral, event string) ([]byte, string, error) {
+	fields, err := peripheralFields(name, peripheral, event)
+
+	if err != nil {
+		return nil, "", err
+	}
+
+	return s.SerializeFields(event, fields)
+}
+
+func (s *JsonSerializer) SerializeFields(event string, fields map[string]interface{}) ([]byte, string, error) {
+	body, err := json.Marshal(fields)
+
+	return body, CONTENT_TYPE_JSON, err
+}
+
+func NewFormSerializer() *FormSerializer {
+	return &FormSerializer{}
+}
+
+func (s *FormSerializer) Serialize(name string, peripheral peripherals.Peripheral, event string) ([]byte, string, error) {
+	fields, err := peripheralFields(name, peripheral, event)
+
+	if err != nil {
+		return nil, "", err
+	}
+
+	return s.SerializeFields(event, fields)
+}
+
+func (s *FormSerializer) SerializeFields(event string, fields map[string]interface{}) ([]byte, string, error) {
+	values := url.Values{}
+
+	for key, value := range fields {
+		values.Set(key, fmt.Sprintf("%v", value))
+	}
+
+	return []byte(values.Encode()), CONTENT_TYPE_FORM, nil
+}
+
+// SlackSerializer builds a message for Slack incoming webhooks
+func NewSlackSerializer() *SlackSerializer {
+	return &SlackSerializer{}
+}
+
+func (s *SlackSerializer) Serialize(name string, peripheral peripherals.Peripheral, event string) ([]byte, string, error) {
+	if peripheral == nil {
+		return nil, "", ErrMissedPeripheral
+	}
+
+	text := fmt.Sprintf("*%s* %s", name, strings.Replace(event, "_", " ", -1))
+
+	if event != notification.LOST {
+		text += fmt.Sprintf(" (%s)", peripheral.Proximity())
+	}
+
+	body, err := json.Marshal(map[string]string{"text": text})
+
+	return body, CONTENT_TYPE_JSON, err
+}
+
+// Fields of a peripheral common for all serializers
+func peripheralFields(name string, peripheral peripherals.Peripheral, event string) (map[string]interface{}, error) {
+	fields, _, err := serializeFields(name, peripheral, event)
+
+	return fields, err
+}
+
+// Name of the serializer of the endpoint, falling back to the default format
+func (sender *Sender) serializerName(endpoint *notification.Endpoint) string {
+	name := endpoint.Options.Serializer
+
+	if name == "" {
+		name = sender.settings.Format
+	}
+
+	if name == "" {
+		name = FORMAT_JSON
+	}
+
+	return name
+}
+
+func (sender *Sender) getSerializer(endpoint *notification.Endpoint) (Serializer, error) {
+	name := sender.serializerName(endpoint)
+	serializer, found := sender.serializers[name]
+
+	if !found {
+		return nil, errors.Wrap(ErrUnsupportedFormat, name)
+	}
+
+	return serializer, nil
+}
+
+func (sender *Sender) serialize(
+	msg *notification.Message,
+	serialized map[string]interface{},
+	endpoint *notification.Endpoint,
+) ([]byte, string, error) {
+	serializer, err := sender.getSerializer(endpoint)
+
+	if err != nil {
+		return nil, "", err
+	}
+
+	if fieldsSerializer, ok := serializer.(FieldsSerializer); ok {
+		return fieldsSerializer.SerializeFields(msg.EventName(), serialized)
+	}
+
+	peripheral, err := sender.addressPeripheral(msg.Peripheral())
+
+	if err != nil {
+		return nil, "", err
+	}
+
+	return serializer.Serialize(msg.TargetName(), peripheral, msg.EventName())
+}
@@ -0,0 +1,125 @@
+package delivery
+
+import (
+	"github.com/blent/beagle/pkg/clock"
+	"github.com/blent/beagle/pkg/notification"
+	"net/http"
+	"time"
+)
+
+// RequestHook can modify a request right before it is sent, e.g. to sign it.
+// The body is read by GetBody of the request, so the request keeps it. Returned error fails the delivery.
+type RequestHook func(req *http.Request) error
+
+type Settings struct {
+	// Naming strategy applied to the keys of serialized peripherals
+	FieldNaming string
+	// Explicit renames of serialized keys, takes precedence over the naming strategy
+	FieldNames map[string]string
+	// Serialization of peripheral addresses, one of ADDRESS_MODE_* constants
+	AddressMode string
+	// Salt of hashed addresses
+	AddressSalt string
+	// Labels of proximity bands sent as FIELD_PROXIMITY_LABEL, unmapped bands are sent as they are.
+	// The label is omitted if there are no labels.
+	ProximityLabels map[string]string
+	// Default serializer of POST request bodies, one of FORMAT_* constants or a name of a custom serializer
+	Format string
+	// Custom serializers by name, endpoints select them by options.serializer
+	Serializers map[string]Serializer
+	// Compiled schemas by name, endpoints select them by options.schema to validate their request bodies
+	Schemas map[string]*Schema
+	// Source attribute of CloudEvents payloads
+	EventSource string
+	// Capacity of the queue of messages waiting for delivery
+	QueueSize int
+	// Behavior of Send when the queue is full
+	QueuePolicy string
+	// Number of goroutines delivering queued messages
+	Workers int
+	// Optional hook invoked for every request before it is sent
+	RequestHook RequestHook
+	// Names of events accepted by Send, found, lost and proximity changed events if empty
+	EventNames []string
+	// Endpoints receiving messages without subscribers, keyed by peripheral kind or DEFAULT_KIND_ANY
+	DefaultEndpoints map[string][]*notification.Endpoint
+	// Maximum number of delivery attempts per subscriber, 1 disables retries
+	MaxAttempts int
+	// Delay before the first retry, doubled for every next one
+	RetryBackoff time.Duration
+	// Upper bound of the delay between retries
+	RetryMaxBackoff time.Duration
+	// Number of retry attempts made at once, apart from the Workers of first attempts, 0 disables the limit
+	RetryWorkers int
+	// Delays between connection attempts of stateful transports, independent of the retries of deliveries
+	Reconnect *ReconnectPolicy
+	// Surfaces connection state changes of stateful transports as EVENT_CONNECTION events of the sender
+	ConnectionEvents bool
+	// Optional sink of an audit trail of delivery attempts
+	AuditSink AuditSink
+	// Every Nth delivered attempt is audited with its request, the others with their outcome only.
+	// Failed attempts always keep their request, 0 keeps requests of failed attempts only.
+	AuditSampleRate int
+	// Optional store persisting pending retries across restarts
+	PendingStore PendingStore
+	// Maximum size of a response body read by transports, larger responses fail the delivery
+	MaxResponseSize int64
+	// Directory file:// endpoints write payloads under, file endpoints are not supported unless it is set
+	FileRoot string
+	// Default timeout of a single delivery attempt, endpoints may override it, 0 disables it
+	Timeout time.Duration
+	// Default limit of establishing a connection within a delivery attempt, endpoints may override it, 0 disables it.
+	// Timeout bounds the whole attempt including the connection, so the shorter of both applies to connecting.
+	ConnectTimeout time.Duration
+	// Default limit of concurrent requests to a single endpoint, endpoints may override it, 0 disables it
+	MaxInFlight int
+	// Per-endpoint state, like the health or the concurrency limit, of endpoints unused for longer is forgotten, 0 keeps it
+	EndpointStateTTL time.Duration
+	// Upper bound of endpoints with per-endpoint state, the least recently used ones are forgotten over it, 0 disables it
+	MaxTrackedEndpoints int
+	// Deliveries of events detected longer ago are dropped, retries included, 0 disables it
+	MaxStaleness time.Duration
+	// Minimum time between notifications about a single peripheral, whatever their event, 0 disables it
+	Cooldown time.Duration
+	// Optional daily schedule suppressing deliveries to subscribers without priority
+	QuietHours *QuietHours
+	// Delivers found events of peripherals already present, which are suppressed by default
+	Duplicates bool
+	// Delivers messages of peripherals which are not registered to the default endpoints, they are suppressed by default
+	Unregistered bool
+	// Goes through the whole send path but logs requests instead of sending them
+	DryRun bool
+	// Fails deliveries of peripheral kinds without a full serialization instead of sending only common fields
+	Strict bool
+	// Delivers messages in the goroutine calling Send and emits events before it returns.
+	// Intended for tests, where it removes the need to wait for the workers.
+	Synchronous bool
+	// Makes the first attempt in the goroutine calling Send, which then returns the error of the first failed subscriber.
+	// Retries are made in the background as usual.
+	SynchronousFirstAttempt bool
+	// Time source of timestamps and retry timers, replaceable by clock.MockClock in tests
+	Clock clock.Clock
+}
+
+func NewDefaultSettings() *Settings {
+	return &Settings{
+		FieldNaming:         FIELD_NAMING_SNAKE_CASE,
+		FieldNames:          make(map[string]string),
+		Format:              FORMAT_JSON,
+		EventSource:         "beagle",
+		QueueSize:           1000,
+		QueuePolicy:         QUEUE_POLICY_BLOCK,
+		Workers:             10,
+		RetryWorkers:        3,
+		EventNames:          []string{notification.FOUND, notification.LOST, notification.PROXIMITY_CHANGED},
+		MaxAttempts:         1,
+		RetryBackoff:        time.Second * 5,
+		RetryMaxBackoff:     time.Minute * 5,
+		Reconnect:           NewDefaultReconnectPolicy(),
+		MaxResponseSize:     DEFAULT_MAX_RESPONSE_SIZE,
+		Timeout:             time.Second * 30,
+		ConnectTimeout:      time.Second * 10,
+		MaxTrackedEndpoints: 10000,
+		Clock:               clock.New(),
+	}
+}
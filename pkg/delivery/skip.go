@@ -0,0 +1,57 @@
+package delivery
+
+import (
+	"github.com/pkg/errors"
+)
+
+// Reasons of deliveries intentionally not made, shared by all filters of the sender
+const (
+	// Subscriber has no endpoint to deliver to
+	SKIP_REASON_NO_ENDPOINT = "no_endpoint"
+	// Endpoint is temporarily disabled
+	SKIP_REASON_DISABLED = "disabled"
+	// Peripheral proximity does not meet the conditions of the endpoint
+	SKIP_REASON_PROXIMITY = "proximity"
+	// Serialized fields of the peripheral do not meet the condition expression of the endpoint
+	SKIP_REASON_CONDITION = "condition"
+	// Delivery falls into quiet hours and the subscriber has no priority
+	SKIP_REASON_QUIET_HOURS = "quiet_hours"
+	// Found event of a peripheral already present
+	SKIP_REASON_DUPLICATE = "duplicate"
+	// Event was detected longer than the maximum staleness ago
+	SKIP_REASON_STALE = "stale"
+	// Peripheral is not registered and the sender does not deliver such ones
+	SKIP_REASON_UNREGISTERED = "unregistered"
+	// Peripheral already notified within the cooldown
+	SKIP_REASON_COOLDOWN = "cooldown"
+)
+
+type skipped struct {
+	reason string
+}
+
+func (err *skipped) Error() string {
+	return "delivery skipped: " + err.reason
+}
+
+// Filters return it from sendSingle to suppress a delivery without failing it
+func skip(reason string) error {
+	return &skipped{reason}
+}
+
+func skipReason(err error) string {
+	var target *skipped
+
+	if errors.As(err, &target) {
+		return target.reason
+	}
+
+	return ""
+}
+
+func (sender *Sender) countSkipped(reason string) {
+	sender.statsMu.Lock()
+	defer sender.statsMu.Unlock()
+
+	sender.skipped[reason]++
+}
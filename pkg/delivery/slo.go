@@ -0,0 +1,167 @@
+package delivery
+
+import (
+	"math"
+	"sort"
+	"sync"
+	"time"
+)
+
+type (
+	SLOSettings struct {
+		// Percentile of response times compared with the threshold, e.g. 0.95 for p95
+		Percentile float64
+		// Response time the percentile must not exceed
+		Threshold time.Duration
+		// Number of the most recent attempts of an endpoint the percentile is computed over,
+		// an endpoint is not evaluated before it has that many samples
+		Window int
+	}
+
+	// SLOBreach describes an endpoint whose response time percentile exceeded the threshold
+	SLOBreach struct {
+		Endpoint   string        `json:"endpoint"`
+		Url        string        `json:"url"`
+		Percentile float64       `json:"percentile"`
+		Value      time.Duration `json:"value"`
+		Threshold  time.Duration `json:"threshold"`
+		Samples    int           `json:"samples"`
+		Timestamp  time.Time     `json:"timestamp"`
+	}
+
+	SLOListener func(breach SLOBreach)
+
+	// Response times of the most recent attempts of an endpoint, oldest overwritten first
+	latencyWindow struct {
+		samples  []time.Duration
+		next     int
+		breached bool
+	}
+
+	// SLOMonitor tracks a rolling response time percentile of every endpoint by the events of a sender
+	// and reports an endpoint once it breaches the threshold. The endpoint is reported again only
+	// after its percentile gets back within the threshold and breaches it anew.
+	SLOMonitor struct {
+		mu       sync.Mutex
+		settings *SLOSettings
+		listener SLOListener
+		windows  map[string]*latencyWindow
+	}
+)
+
+func NewDefaultSLOSettings() *SLOSettings {
+	return &SLOSettings{
+		Percentile: 0.95,
+		Threshold:  time.Second,
+		Window:     100,
+	}
+}
+
+func NewSLOMonitor(settings *SLOSettings, listener SLOListener) *SLOMonitor {
+	if settings == nil {
+		settings = NewDefaultSLOSettings()
+	}
+
+	return &SLOMonitor{
+		settings: settings,
+		listener: listener,
+		windows:  make(map[string]*latencyWindow),
+	}
+}
+
+// Listener returns an event listener feeding the monitor, to be added to a sender
+func (monitor *SLOMonitor) Listener() EventListener {
+	return monitor.Add
+}
+
+// Add records the duration of a delivery attempt, other events and attempts of dry runs are ignored.
+// Failed attempts count too, since a timeout is the slowest response of all.
+func (monitor *SLOMonitor) Add(evt Event) {
+	if evt.Attempt == 0 || evt.DryRun || evt.Subscriber == nil || evt.Subscriber.Endpoint == nil {
+		return
+	}
+
+	endpoint := evt.Subscriber.Endpoint
+	size := monitor.settings.Window
+
+	if size <= 0 {
+		size = 1
+	}
+
+	monitor.mu.Lock()
+
+	window, found := monitor.windows[endpoint.Url]
+
+	if !found {
+		window = &latencyWindow{samples: make([]time.Duration, 0, size)}
+		monitor.windows[endpoint.Url] = window
+	}
+
+	window.add(evt.Duration, size)
+
+	if len(window.samples) < size {
+		monitor.mu.Unlock()
+		return
+	}
+
+	value := window.percentile(monitor.settings.Percentile)
+	breached := value > monitor.settings.Threshold
+	report := breached && !window.breached
+	window.breached = breached
+
+	monitor.mu.Unlock()
+
+	// called outside of the lock, so the listener may be slow without blocking the senders
+	if report && monitor.listener != nil {
+		monitor.listener(SLOBreach{
+			Endpoint:   endpoint.Name,
+			Url:        endpoint.Url,
+			Percentile: monitor.settings.Percentile,
+			Value:      value,
+			Threshold:  monitor.settings.Threshold,
+			Samples:    size,
+			Timestamp:  evt.Timestamp,
+		})
+	}
+}
+
+// Forget drops the samples of an endpoint url, e.g. of a removed endpoint
+func (monitor *SLOMonitor) Forget(url string) {
+	monitor.mu.Lock()
+	defer monitor.mu.Unlock()
+
+	delete(monitor.windows, url)
+}
+
+func (window *latencyWindow) add(sample time.Duration, size int) {
+	if len(window.samples) < size {
+		window.samples = append(window.samples, sample)
+
+		return
+	}
+
+	window.samples[window.next] = sample
+	window.next = (window.next + 1) % size
+}
+
+// Nearest-rank percentile of the samples
+func (window *latencyWindow) percentile(percentile float64) time.Duration {
+	sorted := make([]time.Duration, len(window.samples))
+	copy(sorted, window.samples)
+
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i] < sorted[j]
+	})
+
+	rank := int(math.Ceil(percentile*float64(len(sorted)))) - 1
+
+	if rank < 0 {
+		rank = 0
+	}
+
+	if rank >= len(sorted) {
+		rank = len(sorted) - 1
+	}
+
+	return sorted[rank]
+}
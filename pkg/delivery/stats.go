@@ -0,0 +1,140 @@
+package delivery
+
+import (
+	"fmt"
+	"github.com/blent/beagle/pkg/notification"
+	"net/http"
+	"sync/atomic"
+)
+
+type Stats struct {
+	QueueDepth    int    `json:"queueDepth"`
+	QueueCapacity int    `json:"queueCapacity"`
+	Dropped       uint64 `json:"dropped"`
+	// Number of messages taken from the queue and being delivered by the workers
+	ActiveMessages int `json:"activeMessages"`
+	// Number of first attempts being made, a message with several subscribers makes them concurrently
+	ActiveDeliveries int `json:"activeDeliveries"`
+	// Number of retries waiting for their backoff to elapse
+	ScheduledRetries int `json:"scheduledRetries"`
+	// Number of due retries waiting for a free retry worker, the queue depth of retries
+	RetryDepth int `json:"retryDepth"`
+	// Number of retry attempts being made
+	ActiveRetries int `json:"activeRetries"`
+	// Number of delivery attempts by their outcome, retries included
+	Delivered uint64 `json:"delivered"`
+	Failed    uint64 `json:"failed"`
+	Rejected  uint64 `json:"rejected"`
+	// Number of suppressed deliveries by SKIP_REASON_* constants
+	Skipped map[string]uint64 `json:"skipped"`
+	// Number of responses by status class, e.g. 2xx, retries included
+	StatusClasses map[string]uint64 `json:"statusClasses"`
+	// Number of unsuccessful responses by status code, e.g. 401 or 404, retries included
+	StatusCodes map[int]uint64 `json:"statusCodes"`
+	// Bytes of request bodies and query strings sent to endpoints, keyed by endpoint url without its credentials and query
+	BytesSent map[string]uint64 `json:"bytesSent"`
+	// Time from detections to successful deliveries, retries included
+	Latency LatencyStats `json:"latency"`
+	// Number of endpoints with per-endpoint state, bounded by EndpointStateTTL and MaxTrackedEndpoints
+	TrackedEndpoints int `json:"trackedEndpoints"`
+}
+
+func (sender *Sender) Stats() *Stats {
+	sender.statsMu.Lock()
+	skipped := make(map[string]uint64, len(sender.skipped))
+
+	for reason, count := range sender.skipped {
+		skipped[reason] = count
+	}
+
+	sent := make(map[string]uint64, len(sender.sent))
+
+	for url, counter := range sender.sent {
+		sent[url] = atomic.LoadUint64(counter)
+	}
+
+	classes := make(map[string]uint64)
+	codes := make(map[int]uint64)
+
+	for code, count := range sender.statuses {
+		classes[fmt.Sprintf("%dxx", code/100)] += count
+
+		if code >= http.StatusBadRequest {
+			codes[code] = count
+		}
+	}
+
+	latency := sender.latencyStats()
+
+	sender.statsMu.Unlock()
+
+	sender.retryMu.Lock()
+	scheduled := len(sender.retries)
+	sender.retryMu.Unlock()
+
+	return &Stats{
+		QueueDepth:       len(sender.queue),
+		QueueCapacity:    cap(sender.queue),
+		Dropped:          atomic.LoadUint64(&sender.dropped),
+		ActiveMessages:   int(atomic.LoadInt64(&sender.activeMessages)),
+		ActiveDeliveries: int(atomic.LoadInt64(&sender.activeDeliveries)),
+		ScheduledRetries: scheduled,
+		RetryDepth:       int(atomic.LoadInt64(&sender.retryWaiting)),
+		ActiveRetries:    int(atomic.LoadInt64(&sender.retryActive)),
+		Delivered:        atomic.LoadUint64(&sender.delivered),
+		Failed:           atomic.LoadUint64(&sender.failed),
+		Rejected:         atomic.LoadUint64(&sender.rejected),
+		Skipped:          skipped,
+		StatusClasses:    classes,
+		StatusCodes:      codes,
+		BytesSent:        sent,
+		Latency:          latency,
+		TrackedEndpoints: sender.trackedEndpoints(),
+	}
+}
+
+// Counts every request which reached the transport, whether it succeeded or not
+func (sender *Sender) countSent(endpoint *notification.Endpoint, req *http.Request) {
+	size := uint64(len(req.URL.RawQuery))
+
+	if req.ContentLength > 0 {
+		size += uint64(req.ContentLength)
+	}
+
+	key := redactUrl(endpoint.Url)
+
+	sender.statsMu.Lock()
+	counter, found := sender.sent[key]
+
+	if !found {
+		counter = new(uint64)
+		sender.sent[key] = counter
+	}
+
+	sender.statsMu.Unlock()
+
+	atomic.AddUint64(counter, size)
+}
+
+// Counts responses of transports which surface their status, 0 means no response
+func (sender *Sender) countStatus(code int) {
+	if code <= 0 {
+		return
+	}
+
+	sender.statsMu.Lock()
+	defer sender.statsMu.Unlock()
+
+	sender.statuses[code]++
+}
+
+func (sender *Sender) countOutcome(evt *Event) {
+	switch {
+	case evt.Delivered:
+		atomic.AddUint64(&sender.delivered, 1)
+	case evt.Rejected:
+		atomic.AddUint64(&sender.rejected, 1)
+	case evt.Error != nil && evt.SkipReason == "":
+		atomic.AddUint64(&sender.failed, 1)
+	}
+}
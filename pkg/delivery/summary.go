@@ -0,0 +1,135 @@
+package delivery
+
+import (
+	"github.com/blent/beagle/pkg/clock"
+	"sync"
+	"time"
+)
+
+type (
+	// Summary rolls up delivery events of a single interval
+	Summary struct {
+		Start     time.Time `json:"start"`
+		End       time.Time `json:"end"`
+		Delivered uint64    `json:"delivered"`
+		Failed    uint64    `json:"failed"`
+		Skipped   uint64    `json:"skipped"`
+		Rejected  uint64    `json:"rejected"`
+		// Average duration of delivered and failed attempts
+		AverageLatency time.Duration `json:"averageLatency"`
+	}
+
+	SummaryListener func(summary Summary)
+
+	// Aggregator collects events of a sender and reports them as a summary once per interval
+	Aggregator struct {
+		mu       sync.Mutex
+		clock    clock.Clock
+		interval time.Duration
+		listener SummaryListener
+		current  Summary
+		latency  time.Duration
+		timer    clock.Timer
+		stopped  bool
+	}
+)
+
+func NewAggregator(clock clock.Clock, interval time.Duration, listener SummaryListener) *Aggregator {
+	return &Aggregator{
+		clock:    clock,
+		interval: interval,
+		listener: listener,
+		stopped:  true,
+	}
+}
+
+// Listener returns an event listener feeding the aggregator, to be added to a sender
+func (agg *Aggregator) Listener() EventListener {
+	return agg.Add
+}
+
+func (agg *Aggregator) Add(evt Event) {
+	// connection changes of stateful transports are neither deliveries nor failures
+	if evt.Connection != nil {
+		return
+	}
+
+	agg.mu.Lock()
+	defer agg.mu.Unlock()
+
+	switch {
+	case evt.Rejected:
+		agg.current.Rejected++
+		return
+	case evt.SkipReason != "":
+		agg.current.Skipped++
+		return
+	case evt.Delivered:
+		agg.current.Delivered++
+	default:
+		agg.current.Failed++
+	}
+
+	agg.latency += evt.Duration
+}
+
+// Start begins a new interval, the summary of which is reported when the interval ends
+func (agg *Aggregator) Start() {
+	agg.mu.Lock()
+	defer agg.mu.Unlock()
+
+	if !agg.stopped {
+		return
+	}
+
+	agg.stopped = false
+	agg.reset()
+	agg.schedule()
+}
+
+// Stop cancels the current interval without reporting it
+func (agg *Aggregator) Stop() {
+	agg.mu.Lock()
+	defer agg.mu.Unlock()
+
+	agg.stopped = true
+
+	if agg.timer != nil {
+		agg.timer.Stop()
+		agg.timer = nil
+	}
+}
+
+func (agg *Aggregator) schedule() {
+	agg.timer = agg.clock.AfterFunc(agg.interval, agg.flush)
+}
+
+func (agg *Aggregator) flush() {
+	agg.mu.Lock()
+
+	if agg.stopped {
+		agg.mu.Unlock()
+		return
+	}
+
+	summary := agg.current
+	summary.End = agg.clock.Now()
+
+	if attempts := summary.Delivered + summary.Failed; attempts > 0 {
+		summary.AverageLatency = agg.latency / time.Duration(attempts)
+	}
+
+	agg.reset()
+	agg.schedule()
+	agg.mu.Unlock()
+
+	// called outside of the lock, so the listener may be slow without blocking the senders
+	if agg.listener != nil {
+		agg.listener(summary)
+	}
+}
+
+func (agg *Aggregator) reset() {
+	agg.current = Summary{Start: agg.clock.Now()}
+	agg.latency = 0
+}
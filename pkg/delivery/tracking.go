@@ -0,0 +1,107 @@
+package delivery
+
+import (
+	"github.com/blent/beagle/pkg/notification"
+	"go.uber.org/zap"
+	"strings"
+	"time"
+)
+
+type trackedEndpoint struct {
+	url  string
+	used time.Time
+}
+
+// Records a delivery to the endpoint, so its health, concurrency limit, byte counter and warnings are kept.
+// Endpoints unused for longer than EndpointStateTTL, or the least recently used ones over MaxTrackedEndpoints,
+// are forgotten on the way, so endpoints used once do not hold their state forever.
+func (sender *Sender) trackEndpoint(endpoint *notification.Endpoint) {
+	now := sender.clock.Now()
+
+	sender.trackedMu.Lock()
+
+	if element, found := sender.tracked[endpoint.Url]; found {
+		element.Value.(*trackedEndpoint).used = now
+		sender.trackedOrder.MoveToFront(element)
+	} else {
+		sender.tracked[endpoint.Url] = sender.trackedOrder.PushFront(&trackedEndpoint{endpoint.Url, now})
+	}
+
+	forgotten := sender.sweepTracked(now)
+
+	sender.trackedMu.Unlock()
+
+	for _, url := range forgotten {
+		sender.forgetEndpoint(url)
+	}
+}
+
+// Called with the tracked endpoints locked, returns the forgotten urls.
+// The least recently used endpoints are at the back, so only the forgotten ones are visited.
+func (sender *Sender) sweepTracked(now time.Time) []string {
+	var forgotten []string
+
+	ttl := sender.settings.EndpointStateTTL
+	limit := sender.settings.MaxTrackedEndpoints
+
+	// the front is the current endpoint, which is never forgotten
+	for sender.trackedOrder.Len() > 1 {
+		element := sender.trackedOrder.Back()
+		tracked := element.Value.(*trackedEndpoint)
+		expired := ttl > 0 && now.Sub(tracked.used) >= ttl
+		excess := limit > 0 && sender.trackedOrder.Len() > limit
+
+		if !expired && !excess {
+			break
+		}
+
+		sender.trackedOrder.Remove(element)
+		delete(sender.tracked, tracked.url)
+		forgotten = append(forgotten, tracked.url)
+	}
+
+	return forgotten
+}
+
+// Semaphores of the endpoint with requests in flight or waiting are kept, the others are forgotten
+func (sender *Sender) forgetEndpoint(url string) {
+	key := redactUrl(url)
+
+	sender.healthMu.Lock()
+	delete(sender.health, key)
+	sender.healthMu.Unlock()
+
+	sender.forgetSemaphores(url)
+	sender.forgetBalanced(url)
+
+	sender.statsMu.Lock()
+	delete(sender.sent, key)
+	sender.statsMu.Unlock()
+
+	sender.projectionWarnings.Range(func(key, _ interface{}) bool {
+		if strings.HasSuffix(key.(string), "|"+url) {
+			sender.projectionWarnings.Delete(key)
+		}
+
+		return true
+	})
+
+	sender.logger.Debug("Forgot the state of an unused endpoint", zap.String("endpoint url", url))
+}
+
+func (sender *Sender) trackedEndpoints() int {
+	sender.trackedMu.Lock()
+	defer sender.trackedMu.Unlock()
+
+	return len(sender.tracked)
+}
+
+// Tells whether the endpoint url was requested within the duration, by a delivery or a probe
+func (sender *Sender) usedWithin(url string, duration time.Duration) bool {
+	sender.trackedMu.Lock()
+	defer sender.trackedMu.Unlock()
+
+	element, found := sender.tracked[url]
+
+	return found && sender.clock.Now().Sub(element.Value.(*trackedEndpoint).used) < duration
+}
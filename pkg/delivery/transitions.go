@@ -0,0 +1,66 @@
+package delivery
+
+import (
+	"github.com/blent/beagle/pkg/notification"
+	"go.uber.org/zap"
+)
+
+// Tells whether the message changes the presence of its peripheral: found events of present peripherals are duplicates.
+// Lost events end the presence, the ones of peripherals found before the sender started are delivered too.
+func (sender *Sender) isTransition(msg *notification.Message) bool {
+	peripheral := msg.Peripheral()
+
+	if sender.settings.Duplicates || peripheral == nil {
+		return true
+	}
+
+	key := peripheral.UniqueKey()
+
+	sender.presenceMu.Lock()
+	defer sender.presenceMu.Unlock()
+
+	switch msg.EventName() {
+	case notification.FOUND:
+		_, present := sender.present[key]
+
+		if present {
+			return false
+		}
+
+		sender.present[key] = struct{}{}
+	case notification.LOST:
+		delete(sender.present, key)
+	}
+
+	return true
+}
+
+// Emits a skipped event for every subscriber of a message suppressed as a whole
+func (sender *Sender) suppress(msg *notification.Message, reason string) {
+	subscribers := msg.Subscribers()
+	events := make([]*Event, 0, len(subscribers))
+
+	for _, subscriber := range subscribers {
+		sender.countSkipped(reason)
+
+		events = append(events, &Event{
+			Name:          msg.EventName(),
+			Timestamp:     sender.clock.Now(),
+			TargetName:    msg.TargetName(),
+			Subscriber:    subscriber,
+			SkipReason:    reason,
+			DryRun:        sender.settings.DryRun,
+			CorrelationId: msg.CorrelationId(),
+		})
+	}
+
+	sender.logger.Info(
+		"Suppressed an event of peripheral",
+		zap.String("event", msg.EventName()),
+		zap.String("peripheral", msg.TargetName()),
+		zap.String("correlation id", msg.CorrelationId()),
+		zap.String("reason", reason),
+	)
+
+	sender.emit(events)
+}
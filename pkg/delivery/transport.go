@@ -1,9 +1,165 @@
 package delivery
 
 import (
+	"bufio"
+	"compress/gzip"
+	"compress/zlib"
+	"context"
+	"fmt"
+	"github.com/pkg/errors"
+	"io"
+	"io/ioutil"
 	"net/http"
+	"strings"
+	"sync/atomic"
+	"time"
 )
 
+// Default limit of a response body read by transports
+const DEFAULT_MAX_RESPONSE_SIZE = 64 * 1024
+
 type Transport interface {
 	Do(*http.Request) error
 }
+
+type connectTimeoutKey struct{}
+
+// Passes the connect timeout of an endpoint to the transport dialing it
+func withConnectTimeout(ctx context.Context, timeout time.Duration) context.Context {
+	return context.WithValue(ctx, connectTimeoutKey{}, timeout)
+}
+
+// ConnectTimeout returns the connect timeout of the request endpoint, so custom transports can apply it.
+// ok is false if the request was not made by a sender.
+func ConnectTimeout(ctx context.Context) (time.Duration, bool) {
+	timeout, ok := ctx.Value(connectTimeoutKey{}).(time.Duration)
+
+	return timeout, ok
+}
+
+type peripheralKey struct{}
+
+// Passes the unique key of the peripheral a request notifies about to the transport
+func withPeripheral(ctx context.Context, key string) context.Context {
+	return context.WithValue(ctx, peripheralKey{}, key)
+}
+
+// PeripheralKey returns the unique key of the peripheral a request notifies about, so custom transports can route by it.
+// ok is false for batches and requests not made for a single peripheral.
+func PeripheralKey(ctx context.Context) (string, bool) {
+	key, ok := ctx.Value(peripheralKey{}).(string)
+
+	return key, ok
+}
+
+type statusKey struct{}
+
+// Keeps the status of the last response to a request, written by transports
+type statusRecorder struct {
+	code int32
+}
+
+func withStatusRecorder(ctx context.Context) (context.Context, *statusRecorder) {
+	recorder := &statusRecorder{}
+
+	return context.WithValue(ctx, statusKey{}, recorder), recorder
+}
+
+// RecordStatus reports the response status of a request made by a sender, so custom transports can surface it in the stats.
+// Transports failing with a StatusError need not call it.
+func RecordStatus(ctx context.Context, statusCode int) {
+	if recorder, ok := ctx.Value(statusKey{}).(*statusRecorder); ok {
+		atomic.StoreInt32(&recorder.code, int32(statusCode))
+	}
+}
+
+func (recorder *statusRecorder) status(err error) int {
+	if code := atomic.LoadInt32(&recorder.code); code > 0 {
+		return int(code)
+	}
+
+	var statusErr *StatusError
+
+	if errors.As(err, &statusErr) {
+		return statusErr.StatusCode
+	}
+
+	return 0
+}
+
+// StatusError reports a response with an unsuccessful status code
+type StatusError struct {
+	StatusCode int
+	Header     http.Header
+}
+
+func (err *StatusError) Error() string {
+	return fmt.Sprintf("%s %d", ErrUnexpectedStatus, err.StatusCode)
+}
+
+// Reads and closes a response body, a body larger than limit or an unsuccessful status fails the delivery.
+// Compressed bodies are decoded first, so the limit applies to the decoded size.
+func readResponse(res *http.Response, limit int64) ([]byte, error) {
+	defer res.Body.Close()
+
+	if res.Request != nil {
+		RecordStatus(res.Request.Context(), res.StatusCode)
+	}
+
+	if limit <= 0 {
+		limit = DEFAULT_MAX_RESPONSE_SIZE
+	}
+
+	reader, err := decodeResponse(res)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer reader.Close()
+
+	body, err := ioutil.ReadAll(io.LimitReader(reader, limit+1))
+
+	if err != nil {
+		return nil, err
+	}
+
+	if int64(len(body)) > limit {
+		return nil, errors.Wrapf(ErrResponseTooLarge, "more than %d bytes", limit)
+	}
+
+	if res.StatusCode >= http.StatusBadRequest {
+		return body, &StatusError{StatusCode: res.StatusCode, Header: res.Header}
+	}
+
+	return body, nil
+}
+
+// Go decodes gzip itself only when it asked for it, a request with its own Accept-Encoding gets the body as is
+func decodeResponse(res *http.Response) (io.ReadCloser, error) {
+	encoding := strings.ToLower(strings.TrimSpace(res.Header.Get("Content-Encoding")))
+	body := bufio.NewReader(res.Body)
+
+	// an empty body has nothing to decode
+	if _, err := body.Peek(1); err == io.EOF {
+		return ioutil.NopCloser(body), nil
+	}
+
+	var reader io.ReadCloser
+	var err error
+
+	switch encoding {
+	case "gzip", "x-gzip":
+		reader, err = gzip.NewReader(body)
+	case "deflate":
+		reader, err = zlib.NewReader(body)
+	default:
+		return ioutil.NopCloser(body), nil
+	}
+
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to decode response")
+	}
+
+	return reader, nil
+}
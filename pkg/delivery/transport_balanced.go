@@ -0,0 +1,319 @@
+package delivery
+
+import (
+	"bytes"
+	"github.com/blent/beagle/pkg/notification"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Modes of BalancedTransport picking the target of a request
+const (
+	// Targets are picked randomly in proportion to their weights, the default
+	BALANCE_MODE_WEIGHTED = "weighted"
+	// Targets are picked in turns, a target of weight 2 gets two turns for every turn of a target of weight 1
+	BALANCE_MODE_ROUND_ROBIN = "round_robin"
+)
+
+type (
+	// BalanceTarget is one of equivalent endpoints sharing the load
+	BalanceTarget struct {
+		// Base url replacing the scheme and the host of requests, its path prefixes the request path
+		Url string
+		// Share of requests sent to the target, a target of weight 0 only takes requests failed by the others
+		Weight int
+	}
+
+	balanceTarget struct {
+		url     *url.URL
+		weight  int
+		current int
+	}
+
+	// BalancedTransport sends every request to a single target out of several equivalent ones, unlike MultiTransport
+	// mirroring it to all of them. A failed request is sent to the next target, picked by the mode among the targets
+	// not tried yet, until one succeeds or all of them fail. Requests are sent through the underlying transport,
+	// e.g. the transport registry the balanced transport is registered to under a scheme of its own.
+	BalancedTransport struct {
+		mu        sync.Mutex
+		logger    *zap.Logger
+		mode      string
+		transport Transport
+		targets   []*balanceTarget
+		random    *rand.Rand
+	}
+
+	// Balanced transport built from the balance group of an endpoint, rebuilt once the group changes
+	balancedEndpoint struct {
+		url       string
+		group     notification.BalanceGroup
+		transport *BalancedTransport
+	}
+)
+
+// NewBalancedTransport creates a transport balancing requests across the targets by the mode,
+// an unknown mode is BALANCE_MODE_WEIGHTED
+func NewBalancedTransport(logger *zap.Logger, mode string, transport Transport, targets ...BalanceTarget) (*BalancedTransport, error) {
+	balanced := make([]*balanceTarget, 0, len(targets))
+
+	for _, target := range targets {
+		parsed, err := url.Parse(target.Url)
+
+		if err != nil || parsed.Scheme == "" {
+			return nil, errors.Wrapf(ErrInvalidBalanceTarget, "invalid url %s", target.Url)
+		}
+
+		if target.Weight < 0 {
+			return nil, errors.Wrapf(ErrInvalidBalanceTarget, "negative weight of %s", target.Url)
+		}
+
+		balanced = append(balanced, &balanceTarget{url: parsed, weight: target.Weight})
+	}
+
+	return &BalancedTransport{
+		logger:    logger,
+		mode:      mode,
+		transport: transport,
+		targets:   balanced,
+		random:    rand.New(rand.NewSource(time.Now().UnixNano())),
+	}, nil
+}
+
+func (t *BalancedTransport) Do(req *http.Request) error {
+	if len(t.targets) == 0 || t.transport == nil {
+		return ErrMissedTransport
+	}
+
+	var body []byte
+
+	if req.Body != nil {
+		var err error
+
+		body, err = ioutil.ReadAll(req.Body)
+		req.Body.Close()
+
+		if err != nil {
+			return err
+		}
+	}
+
+	var failed error
+
+	for _, target := range t.order() {
+		out := req.Clone(req.Context())
+		out.URL = target.resolve(req.URL)
+		out.Host = out.URL.Host
+		out.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+		err := t.transport.Do(out)
+
+		if err == nil {
+			return nil
+		}
+
+		t.logger.Warn(
+			"Balanced request failed",
+			zap.String("url", out.URL.String()),
+			zap.String("mode", t.mode),
+			zap.Error(err),
+		)
+
+		if failed == nil {
+			failed = errors.Wrapf(err, "target %s", target.url.String())
+		}
+
+		// the attempt ran out of time, the remaining targets would fail the same way
+		if req.Context().Err() != nil {
+			break
+		}
+	}
+
+	return failed
+}
+
+// Targets in the order of trying them, the first one is picked by the mode
+func (t *BalancedTransport) order() []*balanceTarget {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.mode == BALANCE_MODE_ROUND_ROBIN {
+		return t.roundRobin()
+	}
+
+	return t.weighted()
+}
+
+// Picks every next target randomly by weight out of the ones not picked yet, targets of weight 0 come last
+func (t *BalancedTransport) weighted() []*balanceTarget {
+	remaining := append([]*balanceTarget(nil), t.targets...)
+	order := make([]*balanceTarget, 0, len(remaining))
+
+	for len(remaining) > 0 {
+		total := 0
+
+		for _, target := range remaining {
+			total += target.weight
+		}
+
+		if total == 0 {
+			return append(order, remaining...)
+		}
+
+		pick := t.random.Intn(total)
+
+		for idx, target := range remaining {
+			if pick < target.weight {
+				order = append(order, target)
+				remaining = append(remaining[:idx], remaining[idx+1:]...)
+				break
+			}
+
+			pick -= target.weight
+		}
+	}
+
+	return order
+}
+
+// Smooth weighted round-robin picks the first target, the other weighted ones follow it in the order they were given,
+// targets of weight 0 come last
+func (t *BalancedTransport) roundRobin() []*balanceTarget {
+	total := 0
+	first := -1
+
+	for idx, target := range t.targets {
+		if target.weight == 0 {
+			continue
+		}
+
+		target.current += target.weight
+		total += target.weight
+
+		if first < 0 || target.current > t.targets[first].current {
+			first = idx
+		}
+	}
+
+	if first < 0 {
+		first = 0
+	} else {
+		t.targets[first].current -= total
+	}
+
+	order := make([]*balanceTarget, 0, len(t.targets))
+	unweighted := make([]*balanceTarget, 0, len(t.targets))
+
+	for idx := range t.targets {
+		target := t.targets[(first+idx)%len(t.targets)]
+
+		if target.weight == 0 {
+			unweighted = append(unweighted, target)
+		} else {
+			order = append(order, target)
+		}
+	}
+
+	return append(order, unweighted...)
+}
+
+// Replaces the scheme and the host of the request url, the target path prefixes the request path
+func (target *balanceTarget) resolve(reqUrl *url.URL) *url.URL {
+	resolved := *reqUrl
+	resolved.Scheme = target.url.Scheme
+	resolved.Host = target.url.Host
+	resolved.User = target.url.User
+	resolved.Path = strings.TrimSuffix(target.url.Path, "/") + reqUrl.Path
+	resolved.RawPath = ""
+
+	return &resolved
+}
+
+// ValidateBalance checks the balance group of the endpoint, its mode has to be known and it needs a target at least
+func ValidateBalance(endpoint *notification.Endpoint) error {
+	group := endpoint.Options.Balance
+
+	if group == nil {
+		return nil
+	}
+
+	switch group.Mode {
+	case "", BALANCE_MODE_WEIGHTED, BALANCE_MODE_ROUND_ROBIN:
+	default:
+		return errors.Wrapf(ErrInvalidBalanceTarget, "unsupported mode %s", group.Mode)
+	}
+
+	if len(group.Targets) == 0 {
+		return errors.Wrap(ErrInvalidBalanceTarget, "missed targets")
+	}
+
+	_, err := NewBalancedTransport(nil, group.Mode, nil, balanceTargets(group)...)
+
+	return err
+}
+
+// Returns the transport of requests to the endpoint, endpoints with a balance group share the load across its targets
+func (sender *Sender) endpointTransport(endpoint *notification.Endpoint) (Transport, error) {
+	group := endpoint.Options.Balance
+
+	if group == nil {
+		return sender.transport, nil
+	}
+
+	key := endpointKey(endpoint)
+
+	sender.balancedMu.Lock()
+	defer sender.balancedMu.Unlock()
+
+	// the round-robin turns live in the transport, so it is kept as long as the group stays the same
+	if found, ok := sender.balanced[key]; ok && reflect.DeepEqual(found.group, *group) {
+		return found.transport, nil
+	}
+
+	transport, err := NewBalancedTransport(
+		sender.logger.Named("transport:balanced"),
+		group.Mode,
+		sender.transport,
+		balanceTargets(group)...,
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	sender.balanced[key] = &balancedEndpoint{
+		url:       endpoint.Url,
+		group:     notification.BalanceGroup{Mode: group.Mode, Targets: append([]notification.BalanceTarget(nil), group.Targets...)},
+		transport: transport,
+	}
+
+	return transport, nil
+}
+
+func (sender *Sender) forgetBalanced(url string) {
+	sender.balancedMu.Lock()
+	defer sender.balancedMu.Unlock()
+
+	for key, balanced := range sender.balanced {
+		if balanced.url == url {
+			delete(sender.balanced, key)
+		}
+	}
+}
+
+func balanceTargets(group *notification.BalanceGroup) []BalanceTarget {
+	targets := make([]BalanceTarget, 0, len(group.Targets))
+
+	for _, target := range group.Targets {
+		targets = append(targets, BalanceTarget{Url: target.Url, Weight: target.Weight})
+	}
+
+	return targets
+}
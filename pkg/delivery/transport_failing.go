@@ -0,0 +1,23 @@
+package delivery
+
+import (
+	"net/http"
+)
+
+// FailingTransport fails every request with the same error
+type FailingTransport struct {
+	err error
+}
+
+func NewFailingTransport(err error) *FailingTransport {
+	return &FailingTransport{err}
+}
+
+// NewFailingStatusTransport fails every request as if the endpoint responded with the status code
+func NewFailingStatusTransport(statusCode int, header http.Header) *FailingTransport {
+	return &FailingTransport{&StatusError{StatusCode: statusCode, Header: header}}
+}
+
+func (transport *FailingTransport) Do(req *http.Request) error {
+	return transport.err
+}
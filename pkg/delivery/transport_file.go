@@ -0,0 +1,237 @@
+package delivery
+
+import (
+	"fmt"
+	"github.com/blent/beagle/pkg/clock"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const FILE_SCHEME = "file"
+
+// Limit of numeric suffixes tried for a file name taken by another payload
+const MAX_FILE_NAME_COLLISIONS = 1000
+
+// FileTransport writes payloads to files of a local directory instead of sending them,
+// e.g. for a sync agent shipping them later from an offline deployment.
+// Endpoint urls have the following format:
+//
+//	file://<directory path>
+//
+// For example, "file:///var/spool/beagle" writes every payload to a new file in "/var/spool/beagle".
+// Directories have to be under the root of the transport, urls resolving outside of it are rejected,
+// so endpoints cannot write anywhere on the host. The directory is created if it does not exist. Files are named by the peripheral and the time of writing,
+// e.g. "<peripheral key>-20060102T150405.000000000Z.json", and a name already taken gets a numeric suffix,
+// so a payload never overwrites another one.
+type FileTransport struct {
+	logger *zap.Logger
+	clock  clock.Clock
+	root   string
+}
+
+func NewFileTransport(logger *zap.Logger, root string) *FileTransport {
+	return &FileTransport{
+		logger: logger,
+		clock:  clock.New(),
+		root:   filepath.Clean(root),
+	}
+}
+
+// SetClock sets the clock of the time in file names
+func (t *FileTransport) SetClock(c clock.Clock) *FileTransport {
+	t.clock = c
+
+	return t
+}
+
+func (t *FileTransport) Do(req *http.Request) error {
+	if err := req.Context().Err(); err != nil {
+		return err
+	}
+
+	dir, err := ParseFileUrl(req.URL.Host, req.URL.Path)
+
+	if err != nil {
+		return err
+	}
+
+	if err := t.checkRoot(dir); err != nil {
+		return t.fail(req, dir, err)
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return t.fail(req, dir, errors.Wrapf(err, "failed to create directory %s", dir))
+	}
+
+	// probes only check that the directory is there
+	if req.Method == http.MethodHead {
+		return nil
+	}
+
+	data, err := t.readPayload(req)
+
+	if err != nil {
+		return t.fail(req, dir, err)
+	}
+
+	path, err := t.write(dir, t.baseName(req), fileExtension(req), data)
+
+	if err != nil {
+		return t.fail(req, dir, err)
+	}
+
+	t.logger.Debug(
+		"Wrote payload to file",
+		zap.String("path", path),
+		zap.String("correlation id", req.Header.Get(CORRELATION_ID_HEADER)),
+	)
+
+	return nil
+}
+
+// Requests without a body, e.g. GET requests, keep their payload in the query
+func (t *FileTransport) readPayload(req *http.Request) ([]byte, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return []byte(req.URL.RawQuery), nil
+	}
+
+	defer req.Body.Close()
+
+	data, err := ioutil.ReadAll(req.Body)
+
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read payload")
+	}
+
+	if len(data) == 0 {
+		return []byte(req.URL.RawQuery), nil
+	}
+
+	return data, nil
+}
+
+// Creates a file of a name not taken yet, the first attempt has no suffix
+func (t *FileTransport) write(dir, name, ext string, data []byte) (string, error) {
+	for idx := 0; idx < MAX_FILE_NAME_COLLISIONS; idx++ {
+		candidate := name
+
+		if idx > 0 {
+			candidate = fmt.Sprintf("%s-%d", name, idx)
+		}
+
+		path := filepath.Join(dir, candidate+ext)
+		file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+
+		if os.IsExist(err) {
+			continue
+		}
+
+		if err != nil {
+			return "", errors.Wrapf(err, "failed to create file %s", path)
+		}
+
+		_, err = file.Write(data)
+
+		if closeErr := file.Close(); err == nil {
+			err = closeErr
+		}
+
+		if err != nil {
+			os.Remove(path)
+
+			return "", errors.Wrapf(err, "failed to write file %s", path)
+		}
+
+		return path, nil
+	}
+
+	return "", errors.Errorf("no free file name for %s in %s", name+ext, dir)
+}
+
+func (t *FileTransport) fail(req *http.Request, dir string, err error) error {
+	t.logger.Error(
+		"failed to write a payload",
+		zap.Error(err),
+		zap.String("directory", dir),
+		zap.String("method", req.Method),
+	)
+
+	return err
+}
+
+// ParseFileUrl returns the directory of a file url by its host and path, only local hosts are supported.
+func ParseFileUrl(host, path string) (string, error) {
+	if host != "" && host != "localhost" {
+		return "", errors.Wrapf(ErrInvalidFileUrl, "unsupported host %s", host)
+	}
+
+	if path == "" {
+		return "", errors.Wrap(ErrInvalidFileUrl, "missed directory path")
+	}
+
+	return filepath.Clean(filepath.FromSlash(path)), nil
+}
+
+// Rejects directories outside of the root, the path is already cleaned of "." and ".." elements by ParseFileUrl
+func (t *FileTransport) checkRoot(dir string) error {
+	rel, err := filepath.Rel(t.root, dir)
+
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return errors.Wrapf(ErrInvalidFileUrl, "directory %s is outside of %s", dir, t.root)
+	}
+
+	return nil
+}
+
+// Names files by the peripheral of the request, or by its correlation id if the peripheral is unknown,
+// e.g. for batches or retries restored after a restart
+func (t *FileTransport) baseName(req *http.Request) string {
+	identity, ok := PeripheralKey(req.Context())
+
+	if !ok || identity == "" {
+		identity = req.Header.Get(CORRELATION_ID_HEADER)
+	}
+
+	stamp := t.clock.Now().UTC().Format("20060102T150405.000000000Z")
+
+	if identity == "" {
+		return stamp
+	}
+
+	return sanitizeFileName(identity) + "-" + stamp
+}
+
+// Keeps letters, digits, dots, dashes and underscores, e.g. colons of iBeacon keys are not allowed on every file system
+func sanitizeFileName(name string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '.', r == '-', r == '_':
+			return r
+		default:
+			return '_'
+		}
+	}, name)
+}
+
+func fileExtension(req *http.Request) string {
+	mediaType, _, err := mime.ParseMediaType(req.Header.Get("Content-Type"))
+
+	switch {
+	case err != nil:
+		return ".txt"
+	case mediaType == CONTENT_TYPE_JSON || strings.HasSuffix(mediaType, "+json"):
+		return ".json"
+	case mediaType == CONTENT_TYPE_MSGPACK:
+		return ".msgpack"
+	case mediaType == CONTENT_TYPE_FORM || strings.HasPrefix(mediaType, "text/"):
+		return ".txt"
+	default:
+		return ".bin"
+	}
+}
@@ -1,19 +1,31 @@
 package delivery
 
 import (
+	"context"
 	"github.com/sethgrid/pester"
 	"go.uber.org/zap"
+	"net"
 	"net/http"
+	"time"
 )
 
 const maxConcurrency = 250
 
 type HttpTransport struct {
-	engine *pester.Client
+	engine          *pester.Client
+	maxResponseSize int64
+	connectTimeout  time.Duration
 }
 
 func NewHttpTransport(logger *zap.Logger) *HttpTransport {
-	engine := pester.New()
+	t := &HttpTransport{
+		maxResponseSize: DEFAULT_MAX_RESPONSE_SIZE,
+	}
+
+	base := http.DefaultTransport.(*http.Transport).Clone()
+	base.DialContext = t.dial
+
+	engine := pester.NewExtendedClient(&http.Client{Transport: base})
 	engine.Backoff = pester.ExponentialBackoff
 	engine.MaxRetries = 5
 	engine.Concurrency = maxConcurrency
@@ -30,13 +42,56 @@ func NewHttpTransport(logger *zap.Logger) *HttpTransport {
 		)
 	}
 
-	return &HttpTransport{
-		engine,
+	t.engine = engine
+
+	return t
+}
+
+// SetMaxResponseSize limits the number of bytes read from a response body
+func (t *HttpTransport) SetMaxResponseSize(size int64) *HttpTransport {
+	t.maxResponseSize = size
+
+	return t
+}
+
+// SetMaxRetries sets the number of attempts of a request, 1 leaves retries to the sender
+func (t *HttpTransport) SetMaxRetries(retries int) *HttpTransport {
+	t.engine.MaxRetries = retries
+
+	return t
+}
+
+// SetConnectTimeout limits establishing a connection of requests without a connect timeout of their endpoint, 0 disables it
+func (t *HttpTransport) SetConnectTimeout(timeout time.Duration) *HttpTransport {
+	t.connectTimeout = timeout
+
+	return t
+}
+
+// Dials with the connect timeout of the request endpoint, the request timeout still bounds it through the context
+func (t *HttpTransport) dial(ctx context.Context, network, address string) (net.Conn, error) {
+	timeout, ok := ConnectTimeout(ctx)
+
+	if !ok {
+		timeout = t.connectTimeout
+	}
+
+	dialer := &net.Dialer{
+		Timeout:   timeout,
+		KeepAlive: time.Second * 30,
 	}
+
+	return dialer.DialContext(ctx, network, address)
 }
 
 func (t *HttpTransport) Do(req *http.Request) error {
-	_, err := t.engine.Do(req)
+	res, err := t.engine.Do(req)
+
+	if err != nil {
+		return err
+	}
+
+	_, err = readResponse(res, t.maxResponseSize)
 
 	return err
 }
@@ -0,0 +1,129 @@
+package delivery
+
+import (
+	"bytes"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+	"io/ioutil"
+	"net/http"
+	"sync"
+)
+
+// Policies of MultiTransport deciding whether a mirrored request succeeded
+const (
+	// Every transport must succeed, the default
+	MULTI_POLICY_ALL = "all"
+	// A single successful transport is enough
+	MULTI_POLICY_ANY = "any"
+	// Only the first transport counts, failures of the others are logged
+	MULTI_POLICY_PRIMARY = "primary"
+)
+
+// MultiTransport mirrors every request to all of its transports concurrently, e.g. to a primary webhook and a backup sink.
+// The outcome is aggregated by the policy. A failed request returns the error of the first failed transport
+// in the order they were given, so error categories and retries work as with a single transport.
+// A retry of a failed request is sent to all transports again, including the ones which succeeded.
+type MultiTransport struct {
+	logger     *zap.Logger
+	policy     string
+	transports []Transport
+}
+
+// NewMultiTransport creates a transport mirroring requests by the policy, an unknown policy is MULTI_POLICY_ALL
+func NewMultiTransport(logger *zap.Logger, policy string, transports ...Transport) *MultiTransport {
+	mirrors := make([]Transport, 0, len(transports))
+
+	for _, transport := range transports {
+		if transport != nil {
+			mirrors = append(mirrors, transport)
+		}
+	}
+
+	return &MultiTransport{
+		logger:     logger,
+		policy:     policy,
+		transports: mirrors,
+	}
+}
+
+func (t *MultiTransport) Do(req *http.Request) error {
+	if len(t.transports) == 0 {
+		return ErrMissedTransport
+	}
+
+	var body []byte
+
+	if req.Body != nil {
+		var err error
+
+		body, err = ioutil.ReadAll(req.Body)
+		req.Body.Close()
+
+		if err != nil {
+			return err
+		}
+	}
+
+	errs := make([]error, len(t.transports))
+
+	var wg sync.WaitGroup
+
+	wg.Add(len(t.transports))
+
+	for idx, transport := range t.transports {
+		go func(idx int, transport Transport) {
+			defer wg.Done()
+
+			// every transport reads its own copy of the body
+			mirror := req.Clone(req.Context())
+			mirror.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+			errs[idx] = transport.Do(mirror)
+		}(idx, transport)
+	}
+
+	wg.Wait()
+
+	return t.aggregate(req, errs)
+}
+
+func (t *MultiTransport) aggregate(req *http.Request, errs []error) error {
+	var failed error
+	succeeded := 0
+
+	for idx, err := range errs {
+		if err == nil {
+			succeeded++
+			continue
+		}
+
+		t.logger.Warn(
+			"Mirrored request failed",
+			zap.String("url", req.URL.String()),
+			zap.Int("transport", idx),
+			zap.String("policy", t.policy),
+			zap.Error(err),
+		)
+
+		if failed == nil {
+			failed = errors.Wrapf(err, "transport %d", idx)
+		}
+	}
+
+	switch t.policy {
+	case MULTI_POLICY_ANY:
+		if succeeded > 0 {
+			return nil
+		}
+
+		return failed
+	case MULTI_POLICY_PRIMARY:
+		if errs[0] != nil {
+			return errors.Wrap(errs[0], "transport 0")
+		}
+
+		return nil
+	default:
+		return failed
+	}
+}
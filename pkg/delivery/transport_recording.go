@@ -0,0 +1,70 @@
+package delivery
+
+import (
+	"io/ioutil"
+	"net/http"
+	"sync"
+)
+
+type (
+	RecordedRequest struct {
+		Method string
+		Url    string
+		Header http.Header
+		Body   []byte
+	}
+
+	// RecordingTransport captures received requests and succeeds
+	RecordingTransport struct {
+		mu       sync.Mutex
+		requests []*RecordedRequest
+	}
+)
+
+func NewRecordingTransport() *RecordingTransport {
+	return &RecordingTransport{
+		requests: make([]*RecordedRequest, 0, 10),
+	}
+}
+
+func (transport *RecordingTransport) Do(req *http.Request) error {
+	var body []byte
+
+	if req.Body != nil {
+		var err error
+
+		body, err = ioutil.ReadAll(req.Body)
+		req.Body.Close()
+
+		if err != nil {
+			return err
+		}
+	}
+
+	transport.mu.Lock()
+	defer transport.mu.Unlock()
+
+	transport.requests = append(transport.requests, &RecordedRequest{
+		Method: req.Method,
+		Url:    req.URL.String(),
+		Header: req.Header.Clone(),
+		Body:   body,
+	})
+
+	return nil
+}
+
+// Requests returns captured requests in the order they were received
+func (transport *RecordingTransport) Requests() []*RecordedRequest {
+	transport.mu.Lock()
+	defer transport.mu.Unlock()
+
+	return append([]*RecordedRequest(nil), transport.requests...)
+}
+
+func (transport *RecordingTransport) Reset() {
+	transport.mu.Lock()
+	defer transport.mu.Unlock()
+
+	transport.requests = transport.requests[:0]
+}
@@ -0,0 +1,55 @@
+package delivery
+
+import (
+	"github.com/pkg/errors"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// Scheme of endpoint urls without one
+const DEFAULT_SCHEME = "https"
+
+// TransportRegistry dispatches requests to a transport registered for the url scheme.
+type TransportRegistry struct {
+	mu         sync.RWMutex
+	transports map[string]Transport
+}
+
+func NewTransportRegistry() *TransportRegistry {
+	return &TransportRegistry{
+		transports: make(map[string]Transport),
+	}
+}
+
+func (registry *TransportRegistry) Register(scheme string, transport Transport) *TransportRegistry {
+	if scheme == "" || transport == nil {
+		return registry
+	}
+
+	registry.mu.Lock()
+	defer registry.mu.Unlock()
+
+	registry.transports[strings.ToLower(scheme)] = transport
+
+	return registry
+}
+
+// Do fails with ErrUnsupportedScheme if there is no transport for the scheme, urls without a scheme are sent over https
+func (registry *TransportRegistry) Do(req *http.Request) error {
+	scheme := strings.ToLower(req.URL.Scheme)
+
+	if scheme == "" {
+		scheme = DEFAULT_SCHEME
+	}
+
+	registry.mu.RLock()
+	transport, ok := registry.transports[scheme]
+	registry.mu.RUnlock()
+
+	if !ok {
+		return errors.Wrap(ErrUnsupportedScheme, scheme)
+	}
+
+	return transport.Do(req)
+}
@@ -0,0 +1,150 @@
+package delivery
+
+import (
+	"context"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"syscall"
+)
+
+const UNIX_SCHEME = "unix"
+
+// UnixTransport delivers requests to a local daemon listening on a Unix domain socket.
+// Endpoint urls have the following format:
+//
+//	unix://<socket path>:<request path>
+//
+// For example, "unix:///var/run/sink.sock:/events" sends requests to "/events"
+// through the "/var/run/sink.sock" socket.
+type UnixTransport struct {
+	mu              sync.Mutex
+	logger          *zap.Logger
+	clients         map[string]*http.Client
+	maxResponseSize int64
+}
+
+func NewUnixTransport(logger *zap.Logger) *UnixTransport {
+	return &UnixTransport{
+		logger:          logger,
+		clients:         make(map[string]*http.Client),
+		maxResponseSize: DEFAULT_MAX_RESPONSE_SIZE,
+	}
+}
+
+// SetMaxResponseSize limits the number of bytes read from a response body
+func (t *UnixTransport) SetMaxResponseSize(size int64) *UnixTransport {
+	t.maxResponseSize = size
+
+	return t
+}
+
+func (t *UnixTransport) Do(req *http.Request) error {
+	socket, path, err := ParseUnixSocketUrl(req.URL.EscapedPath())
+
+	if err != nil {
+		return err
+	}
+
+	target := *req.URL
+	target.Scheme = "http"
+	target.Host = UNIX_SCHEME
+	target.Path = path
+	target.RawPath = ""
+
+	out := req.WithContext(req.Context())
+	out.URL = &target
+	out.Host = UNIX_SCHEME
+
+	res, err := t.getClient(socket).Do(out)
+
+	if err != nil {
+		err = t.describeError(socket, err)
+
+		t.logger.Error(
+			"failed to do a request",
+			zap.Error(err),
+			zap.String("socket", socket),
+			zap.String("path", path),
+			zap.String("method", req.Method),
+		)
+
+		return err
+	}
+
+	_, err = readResponse(res, t.maxResponseSize)
+
+	return err
+}
+
+func (t *UnixTransport) getClient(socket string) *http.Client {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	client, ok := t.clients[socket]
+
+	if !ok {
+		dialer := &net.Dialer{}
+
+		client = &http.Client{
+			Transport: &http.Transport{
+				DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+					return dialer.DialContext(ctx, UNIX_SCHEME, socket)
+				},
+			},
+		}
+
+		t.clients[socket] = client
+	}
+
+	return client
+}
+
+func (t *UnixTransport) describeError(socket string, err error) error {
+	switch {
+	case errors.Is(err, syscall.ENOENT):
+		return errors.Wrapf(err, "unix socket %s does not exist", socket)
+	case errors.Is(err, syscall.ECONNREFUSED):
+		return errors.Wrapf(err, "connection to unix socket %s refused", socket)
+	case errors.Is(err, syscall.EACCES):
+		return errors.Wrapf(err, "permission denied to unix socket %s", socket)
+	default:
+		return errors.Wrapf(err, "failed to reach unix socket %s", socket)
+	}
+}
+
+// ParseUnixSocketUrl splits an escaped path of a unix url into a socket path and a request path at the first colon.
+// Colons of a socket path are escaped as %3A, e.g. "/run/sink%3A1.sock:/events" is the "/run/sink:1.sock" socket.
+func ParseUnixSocketUrl(path string) (string, string, error) {
+	socket := path
+	target := "/"
+
+	if idx := strings.Index(path, ":"); idx >= 0 {
+		socket = path[:idx]
+		target = path[idx+1:]
+	}
+
+	socket, err := url.PathUnescape(socket)
+
+	if err != nil {
+		return "", "", errors.Wrap(ErrInvalidUnixSocketUrl, err.Error())
+	}
+
+	if target, err = url.PathUnescape(target); err != nil {
+		return "", "", errors.Wrap(ErrInvalidUnixSocketUrl, err.Error())
+	}
+
+	if socket == "" {
+		return "", "", errors.Wrap(ErrInvalidUnixSocketUrl, "missed socket path")
+	}
+
+	if !strings.HasPrefix(target, "/") {
+		target = "/" + target
+	}
+
+	return socket, target, nil
+}
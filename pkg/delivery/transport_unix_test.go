@@ -0,0 +1,91 @@
+package delivery_test
+
+import (
+	"errors"
+	"github.com/blent/beagle/pkg/delivery"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseUnixSocketUrl(t *testing.T) {
+	cases := []struct {
+		path   string
+		socket string
+		target string
+	}{
+		{"/var/run/sink.sock:/events", "/var/run/sink.sock", "/events"},
+		{"/var/run/sink.sock", "/var/run/sink.sock", "/"},
+		{"/var/run/sink.sock:events", "/var/run/sink.sock", "/events"},
+		{"/var/run/sink.sock:/events:batch", "/var/run/sink.sock", "/events:batch"},
+		{"/run/sink%3A1.sock:/events", "/run/sink:1.sock", "/events"},
+		{"/run/sink%3A1.sock:/a%20b", "/run/sink:1.sock", "/a b"},
+	}
+
+	for _, c := range cases {
+		socket, target, err := delivery.ParseUnixSocketUrl(c.path)
+
+		assert.NoError(t, err, c.path)
+		assert.Equal(t, c.socket, socket, c.path)
+		assert.Equal(t, c.target, target, c.path)
+	}
+
+	for _, path := range []string{"", ":/events", "/run/sink%zz.sock:/events"} {
+		_, _, err := delivery.ParseUnixSocketUrl(path)
+
+		assert.True(t, errors.Is(err, delivery.ErrInvalidUnixSocketUrl), path)
+	}
+}
+
+func TestUnixTransport(t *testing.T) {
+	dir, err := ioutil.TempDir("", "beagle")
+
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	defer os.RemoveAll(dir)
+
+	socket := filepath.Join(dir, "sink:1.sock")
+	listener, err := net.Listen("unix", socket)
+
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	received := make(chan string, 1)
+	server := &http.Server{
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			body, _ := ioutil.ReadAll(req.Body)
+			received <- req.Method + " " + req.URL.Path + " " + string(body)
+		}),
+	}
+
+	go server.Serve(listener)
+	defer server.Close()
+
+	transport := delivery.NewUnixTransport(zap.NewNop())
+	escaped := strings.Replace(socket, ":", "%3A", -1)
+	req, err := http.NewRequest(http.MethodPost, "unix://"+escaped+":/events", strings.NewReader(`{"name":"test"}`))
+
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	assert.NoError(t, transport.Do(req))
+	assert.Equal(t, `POST /events {"name":"test"}`, <-received)
+
+	req, _ = http.NewRequest(http.MethodPost, "unix://"+filepath.Join(dir, "missing.sock")+":/events", nil)
+
+	err = transport.Do(req)
+
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "does not exist")
+	}
+}
@@ -0,0 +1,376 @@
+package delivery
+
+import (
+	"github.com/blent/beagle/pkg/clock"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+	"golang.org/x/net/websocket"
+	"io/ioutil"
+	"math/rand"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	WS_SCHEME  = "ws"
+	WSS_SCHEME = "wss"
+)
+
+type (
+	// WebSocketTransport pushes request bodies as text messages over a persistent connection per endpoint url.
+	// A lost connection is reestablished by the next delivery, failed connection attempts are
+	// repeated according to the reconnect policy, and deliveries in between fail with ErrWebSocketUnavailable.
+	// A message which cannot be written within the request timeout fails with ErrWebSocketBackpressure.
+	WebSocketTransport struct {
+		mu           sync.Mutex
+		logger       *zap.Logger
+		clock        clock.Clock
+		connections  map[string]*webSocketConnection
+		policy       *ReconnectPolicy
+		writeTimeout time.Duration
+		listeners    []ConnectionListener
+
+		randomMu sync.Mutex
+		random   *rand.Rand
+	}
+
+	webSocketConnection struct {
+		// serializes connecting and writing
+		mu       sync.Mutex
+		conn     *websocket.Conn
+		failures int
+		retryAt  time.Time
+	}
+)
+
+func NewWebSocketTransport(logger *zap.Logger) *WebSocketTransport {
+	return &WebSocketTransport{
+		logger:       logger,
+		clock:        clock.New(),
+		connections:  make(map[string]*webSocketConnection),
+		policy:       NewDefaultReconnectPolicy(),
+		writeTimeout: time.Second * 10,
+		listeners:    make([]ConnectionListener, 0, 2),
+		random:       rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+}
+
+// SetReconnectPolicy sets the delays between connection attempts, nil restores the default policy
+func (t *WebSocketTransport) SetReconnectPolicy(policy *ReconnectPolicy) *WebSocketTransport {
+	if policy == nil {
+		policy = NewDefaultReconnectPolicy()
+	}
+
+	t.policy = policy
+
+	return t
+}
+
+// SetBackoff sets the delay after the first failed connection attempt, doubled for every next one up to max.
+// It keeps the jitter of the reconnect policy.
+func (t *WebSocketTransport) SetBackoff(backoff, max time.Duration) *WebSocketTransport {
+	return t.SetReconnectPolicy(&ReconnectPolicy{
+		Initial: backoff,
+		Max:     max,
+		Jitter:  t.policy.Jitter,
+	})
+}
+
+// OnConnectionChange adds a listener of connection state changes, e.g. Sender.EmitConnectionChange.
+// Listeners are called outside of the locks of the transport, in the goroutine which noticed the change.
+func (t *WebSocketTransport) OnConnectionChange(listener ConnectionListener) *WebSocketTransport {
+	if listener == nil {
+		return t
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.listeners = append(t.listeners, listener)
+
+	return t
+}
+
+// SetWriteTimeout limits writes of requests without a deadline
+func (t *WebSocketTransport) SetWriteTimeout(timeout time.Duration) *WebSocketTransport {
+	t.writeTimeout = timeout
+
+	return t
+}
+
+func (t *WebSocketTransport) SetClock(timeSource clock.Clock) *WebSocketTransport {
+	t.clock = timeSource
+
+	return t
+}
+
+func (t *WebSocketTransport) Do(req *http.Request) error {
+	var body []byte
+
+	if req.Body != nil {
+		var err error
+
+		body, err = ioutil.ReadAll(req.Body)
+		req.Body.Close()
+
+		if err != nil {
+			return err
+		}
+	}
+
+	location := req.URL.String()
+	entry := t.getConnection(location)
+
+	entry.mu.Lock()
+	changes, err := t.send(req, entry, location, body)
+	entry.mu.Unlock()
+
+	for _, change := range changes {
+		t.notify(change)
+	}
+
+	return err
+}
+
+// Called with the entry locked, returns the connection state changes to report once it is unlocked
+func (t *WebSocketTransport) send(req *http.Request, entry *webSocketConnection, location string, body []byte) ([]ConnectionChange, error) {
+	changes := make([]ConnectionChange, 0, 2)
+	conn, change, err := t.connect(req, entry)
+
+	if change != nil {
+		changes = append(changes, *change)
+	}
+
+	if err != nil {
+		return changes, err
+	}
+
+	deadline, ok := req.Context().Deadline()
+
+	if !ok && t.writeTimeout > 0 {
+		deadline = time.Now().Add(t.writeTimeout)
+	}
+
+	conn.SetWriteDeadline(deadline)
+
+	if err = websocket.Message.Send(conn, string(body)); err == nil {
+		return changes, nil
+	}
+
+	// the state of a connection after a failed write is unknown
+	conn.Close()
+	entry.conn = nil
+
+	var netErr net.Error
+
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		err = errors.Wrapf(ErrWebSocketBackpressure, "%s: %s", location, err)
+	} else {
+		err = errors.Wrapf(ErrWebSocketConnectionLost, "%s: %s", location, err)
+	}
+
+	t.logger.Error(
+		"Failed to write a websocket message",
+		zap.Error(err),
+		zap.String("url", location),
+	)
+
+	changes = append(changes, ConnectionChange{
+		Url:       location,
+		State:     CONNECTION_STATE_LOST,
+		Timestamp: t.clock.Now(),
+		Error:     err,
+	})
+
+	return changes, err
+}
+
+// Close closes all open connections
+func (t *WebSocketTransport) Close() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for location, entry := range t.connections {
+		entry.mu.Lock()
+
+		if entry.conn != nil {
+			entry.conn.Close()
+			entry.conn = nil
+		}
+
+		entry.mu.Unlock()
+
+		delete(t.connections, location)
+	}
+
+	return nil
+}
+
+func (t *WebSocketTransport) getConnection(location string) *webSocketConnection {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	entry, ok := t.connections[location]
+
+	if !ok {
+		entry = &webSocketConnection{}
+		t.connections[location] = entry
+	}
+
+	return entry
+}
+
+// Called with the entry locked, reports a change if a connection attempt was made
+func (t *WebSocketTransport) connect(req *http.Request, entry *webSocketConnection) (*websocket.Conn, *ConnectionChange, error) {
+	if entry.conn != nil {
+		return entry.conn, nil, nil
+	}
+
+	location := req.URL.String()
+	now := t.clock.Now()
+
+	if now.Before(entry.retryAt) {
+		return nil, nil, errors.Wrapf(ErrWebSocketUnavailable, "%s, reconnecting in %s", location, entry.retryAt.Sub(now))
+	}
+
+	conn, err := t.dial(req)
+
+	if err != nil {
+		entry.failures++
+		delay := t.delay(entry.failures)
+		entry.retryAt = now.Add(delay)
+
+		t.logger.Error(
+			"Failed to connect to a websocket",
+			zap.Error(err),
+			zap.String("url", location),
+			zap.Int("failures", entry.failures),
+			zap.Duration("retry in", delay),
+		)
+
+		return nil, &ConnectionChange{
+			Url:       location,
+			State:     CONNECTION_STATE_FAILED,
+			Timestamp: now,
+			Failures:  entry.failures,
+			RetryIn:   delay,
+			Error:     err,
+		}, err
+	}
+
+	entry.conn = conn
+	entry.failures = 0
+	entry.retryAt = time.Time{}
+
+	t.logger.Info("Connected to a websocket", zap.String("url", location))
+
+	go t.drain(location, entry, conn)
+
+	return conn, &ConnectionChange{
+		Url:       location,
+		State:     CONNECTION_STATE_CONNECTED,
+		Timestamp: now,
+	}, nil
+}
+
+func (t *WebSocketTransport) dial(req *http.Request) (*websocket.Conn, error) {
+	origin := *req.URL
+	origin.Scheme = "http"
+
+	if req.URL.Scheme == WSS_SCHEME {
+		origin.Scheme = "https"
+	}
+
+	origin.Path = "/"
+	origin.RawPath = ""
+	origin.RawQuery = ""
+
+	config, err := websocket.NewConfig(req.URL.String(), origin.String())
+
+	if err != nil {
+		return nil, err
+	}
+
+	for name, values := range req.Header {
+		if name == "Content-Type" || name == "Content-Length" {
+			continue
+		}
+
+		config.Header[name] = values
+	}
+
+	dialer := &net.Dialer{}
+
+	if deadline, ok := req.Context().Deadline(); ok {
+		dialer.Deadline = deadline
+	}
+
+	config.Dialer = dialer
+
+	conn, err := websocket.DialConfig(config)
+
+	var dialErr *websocket.DialError
+
+	// keeps the cause visible to error categories
+	if errors.As(err, &dialErr) {
+		err = errors.Wrapf(dialErr.Err, "failed to connect to websocket %s", req.URL)
+	}
+
+	return conn, err
+}
+
+func (t *WebSocketTransport) delay(failures int) time.Duration {
+	return t.policy.delay(failures, func() float64 {
+		t.randomMu.Lock()
+		defer t.randomMu.Unlock()
+
+		return t.random.Float64()
+	})
+}
+
+func (t *WebSocketTransport) notify(change ConnectionChange) {
+	t.mu.Lock()
+	listeners := make([]ConnectionListener, len(t.listeners))
+	copy(listeners, t.listeners)
+	t.mu.Unlock()
+
+	for _, listener := range listeners {
+		listener(change)
+	}
+}
+
+// Reads and discards incoming messages, so a connection closed by the server is noticed before the next write
+func (t *WebSocketTransport) drain(location string, entry *webSocketConnection, conn *websocket.Conn) {
+	var msg []byte
+
+	for {
+		if err := websocket.Message.Receive(conn, &msg); err != nil {
+			break
+		}
+	}
+
+	entry.mu.Lock()
+	lost := entry.conn == conn
+
+	if lost {
+		conn.Close()
+		entry.conn = nil
+	}
+
+	entry.mu.Unlock()
+
+	if !lost {
+		return
+	}
+
+	t.logger.Warn("Websocket connection lost", zap.String("url", location))
+
+	t.notify(ConnectionChange{
+		Url:       location,
+		State:     CONNECTION_STATE_LOST,
+		Timestamp: t.clock.Now(),
+		Error:     errors.Wrap(ErrWebSocketConnectionLost, location),
+	})
+}
@@ -0,0 +1,149 @@
+package delivery
+
+import (
+	"context"
+	"github.com/blent/beagle/pkg/clock"
+	"github.com/blent/beagle/pkg/notification"
+	"go.uber.org/zap"
+	"sync"
+	"time"
+)
+
+type (
+	WarmUpSettings struct {
+		// Interval of probing the endpoints, 0 disables warm-up.
+		// It should be shorter than the idle connection timeout of the transport, 90 seconds for HTTP.
+		Interval time.Duration
+		// Endpoints kept warm besides the default endpoints of the sender
+		Endpoints []*notification.Endpoint
+		Clock     clock.Clock
+	}
+
+	// WarmUp probes endpoints when it starts and then periodically, so deliveries reuse open connections
+	// instead of paying for the connection setup and TLS handshake. The endpoints are probed one at a time
+	// and an endpoint requested by the sender within the interval is not probed at all,
+	// so warm-up adds at most a single request per endpoint and interval.
+	WarmUp struct {
+		mu       sync.Mutex
+		logger   *zap.Logger
+		settings *WarmUpSettings
+		sender   *Sender
+		timer    clock.Timer
+		running  bool
+	}
+)
+
+func NewDefaultWarmUpSettings() *WarmUpSettings {
+	return &WarmUpSettings{
+		Endpoints: make([]*notification.Endpoint, 0),
+		Clock:     clock.New(),
+	}
+}
+
+func NewWarmUp(logger *zap.Logger, settings *WarmUpSettings, sender *Sender) *WarmUp {
+	if settings == nil {
+		settings = NewDefaultWarmUpSettings()
+	}
+
+	return &WarmUp{
+		logger:   logger,
+		settings: settings,
+		sender:   sender,
+	}
+}
+
+// Start warms up the endpoints right away and then every interval
+func (w *WarmUp) Start() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.running || w.settings.Interval <= 0 {
+		return
+	}
+
+	w.running = true
+	w.schedule(0)
+}
+
+// Stop cancels the next warm-up, a warm-up in progress stops before its next probe
+func (w *WarmUp) Stop() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.running = false
+
+	if w.timer != nil {
+		w.timer.Stop()
+		w.timer = nil
+	}
+}
+
+// Called with the lock held
+func (w *WarmUp) schedule(delay time.Duration) {
+	w.timer = w.settings.Clock.AfterFunc(delay, func() {
+		w.warm()
+
+		w.mu.Lock()
+		defer w.mu.Unlock()
+
+		if w.running {
+			w.schedule(w.settings.Interval)
+		}
+	})
+}
+
+func (w *WarmUp) warm() {
+	probed := 0
+
+	for _, endpoint := range w.endpoints() {
+		if !w.isRunning() {
+			return
+		}
+
+		if !endpoint.IsEnabled() || !isProbed(endpoint) || w.sender.usedWithin(endpoint.Url, w.settings.Interval) {
+			continue
+		}
+
+		// the outcome is logged by the probe, it does not affect the endpoint health
+		w.sender.Probe(context.Background(), endpoint)
+		probed++
+	}
+
+	w.logger.Debug("Warmed up endpoints", zap.Int("probed", probed))
+}
+
+// Configured endpoints and the default endpoints of the sender, every url once
+func (w *WarmUp) endpoints() []*notification.Endpoint {
+	seen := make(map[string]struct{})
+	endpoints := make([]*notification.Endpoint, 0, len(w.settings.Endpoints))
+
+	add := func(list []*notification.Endpoint) {
+		for _, endpoint := range list {
+			if endpoint == nil {
+				continue
+			}
+
+			if _, found := seen[endpoint.Url]; found {
+				continue
+			}
+
+			seen[endpoint.Url] = struct{}{}
+			endpoints = append(endpoints, endpoint)
+		}
+	}
+
+	add(w.settings.Endpoints)
+
+	for _, list := range w.sender.settings.DefaultEndpoints {
+		add(list)
+	}
+
+	return endpoints
+}
+
+func (w *WarmUp) isRunning() bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return w.running
+}
@@ -19,18 +19,25 @@ type (
 		isScanning bool
 		logger     *zap.Logger
 		engine     ble.Device
+		factory    *peripherals.Factory
 	}
 )
 
 const bufferSize = 500
 
-func NewBleDevice(logger *zap.Logger, engine ble.Device) *BleDevice {
+// NewBleDevice creates a device scanning by the engine, the factory creates the discovered peripherals
+func NewBleDevice(logger *zap.Logger, engine ble.Device, factory *peripherals.Factory) *BleDevice {
 	ble.SetDefaultDevice(engine)
 
+	if factory == nil {
+		factory = peripherals.NewFactory(nil)
+	}
+
 	device := &BleDevice{
 		isScanning: false,
 		logger:     logger,
 		engine:     engine,
+		factory:    factory,
 	}
 
 	return device
@@ -64,7 +71,7 @@ func (device *BleDevice) start(ctx context.Context, inData chan<- peripherals.Pe
 			return
 		}
 
-		peripheral, err := peripherals.NewPeripheral(
+		peripheral, err := device.factory.NewPeripheral(
 			localName,
 			manufacturerData,
 			float64(adv.TxPowerLevel()),
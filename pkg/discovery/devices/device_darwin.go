@@ -1,16 +1,17 @@
 package devices
 
 import (
+	"github.com/blent/beagle/pkg/discovery/peripherals"
 	"github.com/go-ble/ble/darwin"
 	"go.uber.org/zap"
 )
 
-func NewDevice(logger *zap.Logger) (*BleDevice, error) {
+func NewDevice(logger *zap.Logger, factory *peripherals.Factory) (*BleDevice, error) {
 	engine, err := darwin.NewDevice()
 
 	if err != nil {
 		return nil, err
 	}
 
-	return NewBleDevice(logger, engine), nil
+	return NewBleDevice(logger, engine, factory), nil
 }
@@ -0,0 +1,28 @@
+package peripherals
+
+import (
+	"math"
+)
+
+// AccuracyCalculator estimates the distance to a peripheral in meters, which also decides its proximity band.
+// It gets the measured power, the signal strength expected at 1 meter reported by the peripheral as its TxPowerLevel,
+// and the RSSI of the received advertisement, both in dBm. A negative distance means it cannot be estimated
+// and puts the peripheral into the unknown proximity band.
+type AccuracyCalculator func(power float64, rssi float64) float64
+
+// DefaultAccuracyCalculator is the built-in estimation by the ratio of the RSSI to the measured power.
+func DefaultAccuracyCalculator(power float64, rssi float64) float64 {
+	return math.Pow(12.0, 1.5*((rssi/power)-1))
+}
+
+// NewPathLossCalculator estimates distances by the log-distance path loss model calibrated for a site by its exponent:
+// about 2 in free space, 2.5-3 in open offices and 3-4 in buildings with walls in the way. A non-positive exponent estimates nothing.
+func NewPathLossCalculator(exponent float64) AccuracyCalculator {
+	return func(power float64, rssi float64) float64 {
+		if exponent <= 0 {
+			return -1
+		}
+
+		return math.Pow(10, (power-rssi)/(10*exponent))
+	}
+}
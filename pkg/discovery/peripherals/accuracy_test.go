@@ -0,0 +1,38 @@
+package peripherals_test
+
+import (
+	"github.com/blent/beagle/pkg/discovery/peripherals"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestFactoryAccuracyCalculator(t *testing.T) {
+	calibrated := peripherals.NewFactory(peripherals.NewPathLossCalculator(2)).
+		NewMockPeripheral("calibrated", "mock", "test", nil, -59, -79, "")
+
+	assert.InDelta(t, 10, calibrated.Accuracy(), 0.0001, "path loss distance")
+	assert.Equal(t, peripherals.PROXIMITY_FAR, calibrated.Proximity(), "path loss proximity")
+
+	unknown := peripherals.NewFactory(func(power float64, rssi float64) float64 {
+		return -1
+	}).NewMockPeripheral("unknown", "mock", "test", nil, -59, -79, "")
+
+	assert.Equal(t, peripherals.PROXIMITY_UKNOWN, unknown.Proximity(), "unknown distance")
+	assert.InDelta(t, 10, calibrated.Accuracy(), 0.0001, "factories do not affect each other")
+
+	for _, peripheral := range []peripherals.Peripheral{
+		peripherals.NewFactory(nil).NewMockPeripheral("default", "mock", "test", nil, -59, -59, ""),
+		peripherals.NewMockPeripheral("package", "mock", "test", nil, -59, -59, ""),
+	} {
+		assert.Equal(t, float64(1), peripheral.Accuracy(), "default distance")
+		assert.Equal(t, peripherals.PROXIMITY_NEAR, peripheral.Proximity(), "default proximity")
+	}
+}
+
+func TestPathLossCalculator(t *testing.T) {
+	calculator := peripherals.NewPathLossCalculator(3)
+
+	assert.InDelta(t, 1, calculator(-59, -59), 0.0001, "distance of the measured power")
+	assert.InDelta(t, 10, calculator(-59, -89), 0.0001, "distance of 30 dBm less")
+	assert.Equal(t, float64(-1), peripherals.NewPathLossCalculator(0)(-59, -79), "no estimation without an exponent")
+}
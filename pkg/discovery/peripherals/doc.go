@@ -0,0 +1,4 @@
+// Package peripherals is the only definition of discovered peripherals, shared by discovery, tracking,
+// notification and delivery, so a peripheral found by a device flows into delivery serialization as is.
+// The import comment makes GOPATH builds reject any other path of the package.
+package peripherals // import "github.com/blent/beagle/pkg/discovery/peripherals"
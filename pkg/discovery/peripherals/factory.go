@@ -0,0 +1,39 @@
+package peripherals
+
+// Factory creates peripherals of advertisements, estimating their distance by its accuracy calculator,
+// so every device or embedder sets the estimation of its own peripherals
+type Factory struct {
+	calculator AccuracyCalculator
+}
+
+// Peripherals created by the package functions estimate their distance by DefaultAccuracyCalculator
+var defaultFactory = NewFactory(nil)
+
+// NewFactory creates a factory estimating distances by the calculator, nil is DefaultAccuracyCalculator
+func NewFactory(calculator AccuracyCalculator) *Factory {
+	if calculator == nil {
+		calculator = DefaultAccuracyCalculator
+	}
+
+	return &Factory{calculator}
+}
+
+func (factory *Factory) NewPeripheral(localName string, data []byte, power float64, rssi float64, address string) (Peripheral, error) {
+	if isIBeacon(data) {
+		return factory.NewIBeaconPeripheral(localName, data, power, rssi, address)
+	}
+
+	return nil, ErrUnsupportedPeripheral
+}
+
+func (factory *Factory) NewIBeaconPeripheral(localName string, data []byte, power float64, rssi float64, address string) (*IBeaconPeripheral, error) {
+	return newIBeaconPeripheral(factory.calculator, localName, data, power, rssi, address)
+}
+
+func (factory *Factory) NewEddystonePeripheral(localName string, data []byte, power float64, rssi float64, address string) (*EddystonePeripheral, error) {
+	return newEddystonePeripheral(factory.calculator, localName, data, power, rssi, address)
+}
+
+func (factory *Factory) NewMockPeripheral(id string, kind string, localName string, data []byte, power float64, rssi float64, address string) *MockPeripheral {
+	return newMockPeripheral(factory.calculator, id, kind, localName, data, power, rssi, address)
+}
@@ -14,8 +14,13 @@ type (
 )
 
 func NewEddystonePeripheral(localName string, data []byte, power float64, rssi float64, address string) (*EddystonePeripheral, error) {
+	return defaultFactory.NewEddystonePeripheral(localName, data, power, rssi, address)
+}
+
+func newEddystonePeripheral(calculator AccuracyCalculator, localName string, data []byte, power float64, rssi float64, address string) (*EddystonePeripheral, error) {
 	return &EddystonePeripheral{
 		GenericPeripheral: newGenericPeripheral(
+			calculator,
 			GenerateEddystoneId(),
 			PERIPHERAL_EDDYSTONE,
 			localName,
@@ -24,6 +24,10 @@ type IBeaconPeripheral struct {
 }
 
 func NewIBeaconPeripheral(localName string, data []byte, power float64, rssi float64, address string) (*IBeaconPeripheral, error) {
+	return defaultFactory.NewIBeaconPeripheral(localName, data, power, rssi, address)
+}
+
+func newIBeaconPeripheral(calculator AccuracyCalculator, localName string, data []byte, power float64, rssi float64, address string) (*IBeaconPeripheral, error) {
 	uuid := getIBeaconUuid(data)
 	major := getIBeaconMajor(data)
 	minor := getIBeaconMinor(data)
@@ -35,6 +39,7 @@ func NewIBeaconPeripheral(localName string, data []byte, power float64, rssi flo
 
 	return &IBeaconPeripheral{
 		GenericPeripheral: newGenericPeripheral(
+			calculator,
 			id,
 			PERIPHERAL_IBEACON,
 			localName,
@@ -7,8 +7,13 @@ type (
 )
 
 func NewMockPeripheral(id string, kind string, localName string, data []byte, power float64, rssi float64, address string) *MockPeripheral {
+	return defaultFactory.NewMockPeripheral(id, kind, localName, data, power, rssi, address)
+}
+
+func newMockPeripheral(calculator AccuracyCalculator, id string, kind string, localName string, data []byte, power float64, rssi float64, address string) *MockPeripheral {
 	return &MockPeripheral{
 		GenericPeripheral: newGenericPeripheral(
+			calculator,
 			id,
 			kind,
 			localName,
@@ -1,9 +1,5 @@
 package peripherals
 
-import (
-	"math"
-)
-
 type (
 	Peripheral interface {
 		UniqueKey() string
@@ -75,19 +71,15 @@ func (peripheral *GenericPeripheral) Accuracy() float64 {
 }
 
 func NewPeripheral(localName string, data []byte, power float64, rssi float64, address string) (Peripheral, error) {
-	if isIBeacon(data) {
-		return NewIBeaconPeripheral(localName, data, power, rssi, address)
-	}
-
-	return nil, ErrUnsupportedPeripheral
+	return defaultFactory.NewPeripheral(localName, data, power, rssi, address)
 }
 
 func IsSupportedPeripheral(data []byte) bool {
 	return isIBeacon(data) || isEddystone()
 }
 
-func newGenericPeripheral(uniqueKey string, kind string, localName string, data []byte, power float64, rssi float64, address string) *GenericPeripheral {
-	accuracy := calculateAccuracy(power, rssi)
+func newGenericPeripheral(calculator AccuracyCalculator, uniqueKey string, kind string, localName string, data []byte, power float64, rssi float64, address string) *GenericPeripheral {
+	accuracy := calculator(power, rssi)
 
 	return &GenericPeripheral{
 		uniqueKey:        uniqueKey,
@@ -102,10 +94,6 @@ func newGenericPeripheral(uniqueKey string, kind string, localName string, data
 	}
 }
 
-func calculateAccuracy(power float64, rssi float64) float64 {
-	return math.Pow(12.0, 1.5*((rssi/power)-1))
-}
-
 func calculateProximity(accuracy float64) string {
 	if accuracy < 0 {
 		return PROXIMITY_UKNOWN
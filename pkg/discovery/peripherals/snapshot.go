@@ -0,0 +1,58 @@
+package peripherals
+
+// Snapshot copies a peripheral, so the copy keeps the current state whatever happens to the original.
+// Peripherals of unknown types are copied as generic ones by their getters.
+func Snapshot(peripheral Peripheral) Peripheral {
+	switch target := peripheral.(type) {
+	case nil:
+		return nil
+	case *IBeaconPeripheral:
+		copied := *target
+		copied.GenericPeripheral = target.GenericPeripheral.snapshot()
+
+		return &copied
+	case *EddystonePeripheral:
+		copied := *target
+		copied.GenericPeripheral = target.GenericPeripheral.snapshot()
+
+		return &copied
+	case *MockPeripheral:
+		return &MockPeripheral{target.GenericPeripheral.snapshot()}
+	case *GenericPeripheral:
+		return target.snapshot()
+	default:
+		return &GenericPeripheral{
+			uniqueKey:        peripheral.UniqueKey(),
+			localName:        peripheral.LocalName(),
+			kind:             peripheral.Kind(),
+			manufacturerData: copyBytes(peripheral.ManufacturerData()),
+			txPowerLevel:     peripheral.TxPowerLevel(),
+			rssi:             peripheral.RSSI(),
+			address:          peripheral.Address(),
+			proximity:        peripheral.Proximity(),
+			accuracy:         peripheral.Accuracy(),
+		}
+	}
+}
+
+func (peripheral *GenericPeripheral) snapshot() *GenericPeripheral {
+	if peripheral == nil {
+		return nil
+	}
+
+	copied := *peripheral
+	copied.manufacturerData = copyBytes(peripheral.manufacturerData)
+
+	return &copied
+}
+
+func copyBytes(data []byte) []byte {
+	if data == nil {
+		return nil
+	}
+
+	copied := make([]byte, len(data))
+	copy(copied, data)
+
+	return copied
+}
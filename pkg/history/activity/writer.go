@@ -15,7 +15,7 @@ func New(logger *zap.Logger) *Writer {
 	}
 }
 
-func (history *Writer) Use(broker *notification.Broker) {
+func (history *Writer) Use(broker notification.EventSource) {
 	if broker == nil {
 		return
 	}
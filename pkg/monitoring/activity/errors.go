@@ -0,0 +1,7 @@
+package activity
+
+import "github.com/pkg/errors"
+
+var (
+	ErrEmptyRecordKey = errors.New("record key is empty")
+)
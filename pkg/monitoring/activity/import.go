@@ -0,0 +1,77 @@
+package activity
+
+import (
+	"container/list"
+	"github.com/pkg/errors"
+	"sort"
+)
+
+// ImportRecords merges records, e.g. exported by another instance, into the monitored ones.
+// A record replaces the monitored one with the same key only if it is newer. Nothing is imported if any record has an empty key.
+// Imported records are placed among the monitored ones by their time, so the records limit still evicts the least recently seen.
+func (s *Monitoring) ImportRecords(records []*Record) error {
+	imported := make([]*Record, 0, len(records))
+
+	for idx, record := range records {
+		if record == nil || record.Key == "" {
+			return errors.Wrapf(ErrEmptyRecordKey, "record %d", idx)
+		}
+
+		// copying..
+		item := *record
+		item.Time = item.Time.UTC()
+		item.FirstSeen = item.FirstSeen.UTC()
+
+		if item.ProximityChangedAt != nil {
+			changedAt := item.ProximityChangedAt.UTC()
+			item.ProximityChangedAt = &changedAt
+		}
+
+		imported = append(imported, &item)
+	}
+
+	// the oldest first, so a newer record of the same key in the batch wins
+	sort.SliceStable(imported, func(i, j int) bool {
+		return imported[i].Time.Before(imported[j].Time)
+	})
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, record := range imported {
+		existing, ok := s.records[record.Key]
+
+		if ok {
+			if !record.Time.After(existing.Time) {
+				continue
+			}
+
+			// the last seen state of the peripheral is still the best known one
+			record.peripheral = existing.peripheral
+
+			if !existing.FirstSeen.IsZero() && (record.FirstSeen.IsZero() || existing.FirstSeen.Before(record.FirstSeen)) {
+				record.FirstSeen = existing.FirstSeen
+			}
+
+			s.order.Remove(s.elements[record.Key])
+		}
+
+		s.records[record.Key] = record
+		s.elements[record.Key] = s.insertByTime(record)
+	}
+
+	s.evict()
+
+	return nil
+}
+
+// Keeps the order of keys the most recently updated first
+func (s *Monitoring) insertByTime(record *Record) *list.Element {
+	for element := s.order.Front(); element != nil; element = element.Next() {
+		if s.records[element.Value.(string)].Time.Before(record.Time) {
+			return s.order.InsertBefore(record.Key, element)
+		}
+	}
+
+	return s.order.PushBack(record.Key)
+}
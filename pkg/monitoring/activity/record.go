@@ -1,6 +1,7 @@
 package activity
 
 import (
+	"github.com/blent/beagle/pkg/discovery/peripherals"
 	"time"
 )
 
@@ -10,4 +11,25 @@ type Record struct {
 	Proximity  string    `json:"proximity"`
 	Registered bool      `json:"registered"`
 	Time       time.Time `json:"time"`
+	// Time the peripheral was found first, repeated found events keep it, so the dwell time is Time - FirstSeen
+	FirstSeen time.Time `json:"firstSeen"`
+	// Number of found events of the peripheral since it was found first
+	Count int `json:"count"`
+	// Last time the peripheral moved into another proximity band, nil until it does
+	ProximityChangedAt *time.Time `json:"proximityChangedAt,omitempty"`
+	// The peripheral as it was seen last time
+	peripheral peripherals.Peripheral
+}
+
+// In returns a copy of the record with its times rendered in the location, stored times are always UTC
+func (r Record) In(location *time.Location) Record {
+	r.Time = r.Time.In(location)
+	r.FirstSeen = r.FirstSeen.In(location)
+
+	if r.ProximityChangedAt != nil {
+		changedAt := r.ProximityChangedAt.In(location)
+		r.ProximityChangedAt = &changedAt
+	}
+
+	return r
 }
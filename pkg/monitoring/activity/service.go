@@ -1,23 +1,65 @@
 package activity
 
 import (
+	"container/list"
+	"encoding/json"
+	"github.com/blent/beagle/pkg/clock"
+	"github.com/blent/beagle/pkg/discovery/peripherals"
 	"github.com/blent/beagle/pkg/notification"
 	"github.com/bradfitz/slice"
 	"go.uber.org/zap"
+	"io"
+	"sort"
 	"sync"
+	"time"
 )
 
-type Monitoring struct {
-	mu      *sync.RWMutex
-	logger  *zap.Logger
-	records map[string]*Record
-}
+type (
+	// ProximityListener is called with a copy of the record of a peripheral which moved into another proximity band
+	ProximityListener func(record Record, previous string)
+
+	Monitoring struct {
+		mu       *sync.RWMutex
+		logger   *zap.Logger
+		settings *Settings
+		clock    clock.Clock
+		records  map[string]*Record
+		// removes the listener of the broker, nil until Use
+		unsubscribe func()
+		stopped     bool
+		proximity   ProximityListener
+		// keys of records, the most recently updated first
+		order    *list.List
+		elements map[string]*list.Element
+		evicted  uint64
+		found    uint64
+		lost     uint64
+	}
+)
 
 func New(logger *zap.Logger) *Monitoring {
+	return NewWithSettings(logger, NewDefaultSettings())
+}
+
+func NewWithSettings(logger *zap.Logger, settings *Settings) *Monitoring {
+	if settings == nil {
+		settings = NewDefaultSettings()
+	}
+
+	timeSource := settings.Clock
+
+	if timeSource == nil {
+		timeSource = clock.New()
+	}
+
 	return &Monitoring{
-		mu:      &sync.RWMutex{},
-		logger:  logger,
-		records: make(map[string]*Record),
+		mu:       &sync.RWMutex{},
+		logger:   logger,
+		settings: settings,
+		clock:    timeSource,
+		records:  make(map[string]*Record),
+		order:    list.New(),
+		elements: make(map[string]*list.Element),
 	}
 }
 
@@ -28,6 +70,23 @@ func (s *Monitoring) Quantity() int {
 	return len(s.records)
 }
 
+// Evicted returns the number of records evicted by the records limit
+func (s *Monitoring) Evicted() uint64 {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.evicted
+}
+
+// Location returns the timezone records are rendered in on export
+func (s *Monitoring) Location() *time.Location {
+	if s.settings.Location == nil {
+		return time.UTC
+	}
+
+	return s.settings.Location
+}
+
 func (s *Monitoring) GetRecords(take, skip int) []*Record {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
@@ -66,29 +125,282 @@ func (s *Monitoring) GetRecords(take, skip int) []*Record {
 	return result
 }
 
-func (s *Monitoring) Use(broker *notification.Broker) *Monitoring {
+// IsPresent tells whether the peripheral of the key has a record, i.e. it is found and not lost yet
+func (s *Monitoring) IsPresent(key string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	_, found := s.records[key]
+
+	return found
+}
+
+// PresentKeys returns the keys of all records, ordered by key
+func (s *Monitoring) PresentKeys() []string {
+	s.mu.RLock()
+	result := make([]string, 0, len(s.records))
+
+	for key := range s.records {
+		result = append(result, key)
+	}
+
+	s.mu.RUnlock()
+
+	sort.Strings(result)
+
+	return result
+}
+
+// Present returns the last seen state of the peripherals not lost yet, it makes the monitoring a notification.PresenceSource
+func (s *Monitoring) Present() []peripherals.Peripheral {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	result := make([]peripherals.Peripheral, 0, len(s.records))
+
+	for _, record := range s.records {
+		if record.peripheral != nil {
+			result = append(result, record.peripheral)
+		}
+	}
+
+	return result
+}
+
+// StaleRecords returns copies of records not updated for longer than olderThan, the oldest first.
+// Such peripherals are probably gone but not lost yet.
+func (s *Monitoring) StaleRecords(olderThan time.Duration) []*Record {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	threshold := s.clock.Now().Add(-olderThan)
+	result := make([]*Record, 0, 10)
+
+	for _, record := range s.records {
+		if record.Time.Before(threshold) {
+			// copying..
+			item := *record
+			result = append(result, &item)
+		}
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Time.Before(result[j].Time)
+	})
+
+	return result
+}
+
+// ExportJSON streams records to the writer as JSON lines: one record object per line, ordered by key.
+// Records are copied one by one, so the export never holds all of them in memory.
+// Records removed during the export are skipped. Times are rendered in the configured location.
+func (s *Monitoring) ExportJSON(w io.Writer) error {
+	location := s.Location()
+
+	s.mu.RLock()
+	keys := make([]string, 0, len(s.records))
+
+	for key := range s.records {
+		keys = append(keys, key)
+	}
+
+	s.mu.RUnlock()
+
+	sort.Strings(keys)
+
+	encoder := json.NewEncoder(w)
+
+	for _, key := range keys {
+		s.mu.RLock()
+		record, ok := s.records[key]
+
+		var item Record
+
+		if ok {
+			item = *record
+		}
+
+		s.mu.RUnlock()
+
+		if !ok {
+			continue
+		}
+
+		item = item.In(location)
+
+		if err := encoder.Encode(&item); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Use subscribes the monitoring to the events of the broker. Any number of monitorings with their own settings
+// can use the same broker, each of them keeps its own records and Stop unsubscribes only its own listener.
+func (s *Monitoring) Use(broker notification.EventSource) *Monitoring {
 	if broker == nil {
 		return s
 	}
 
-	broker.AddEventListener(func(evt notification.Event) {
+	listener := func(evt notification.Event) {
 		s.mu.Lock()
-		defer s.mu.Unlock()
 
-		peripheral := evt.Peripheral
+		// the broker may still be delivering events emitted before Stop
+		if s.stopped {
+			s.mu.Unlock()
+			return
+		}
 
-		if evt.Name == notification.FOUND {
-			s.records[peripheral.UniqueKey()] = &Record{
-				Key:        peripheral.UniqueKey(),
+		changed, previous := s.update(evt)
+		callback := s.proximity
+
+		s.mu.Unlock()
+
+		if changed != nil && callback != nil {
+			callback(*changed, previous)
+		}
+	}
+
+	// every monitoring has a subscription of its own, so monitorings sharing a broker stop independently
+	unsubscribe := broker.Subscribe(listener)
+
+	s.mu.Lock()
+	s.unsubscribe = unsubscribe
+	s.mu.Unlock()
+
+	return s
+}
+
+// OnProximityChange sets the listener of proximity changes of known peripherals
+func (s *Monitoring) OnProximityChange(listener ProximityListener) *Monitoring {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.proximity = listener
+
+	return s
+}
+
+// Applies the event to the records under the lock, returns a copy of the record if its proximity changed
+func (s *Monitoring) update(evt notification.Event) (*Record, string) {
+	peripheral := evt.Peripheral
+	key := peripheral.UniqueKey()
+	timestamp := evt.Timestamp.UTC()
+
+	switch evt.Name {
+	case notification.FOUND:
+		s.found++
+		record, ok := s.records[key]
+
+		if !ok {
+			s.records[key] = &Record{
+				Key:        key,
 				Kind:       peripheral.Kind(),
 				Proximity:  peripheral.Proximity(),
 				Registered: evt.Registered,
-				Time:       evt.Timestamp,
+				Time:       timestamp,
+				FirstSeen:  timestamp,
+				Count:      1,
+				peripheral: peripheral,
 			}
-		} else {
-			delete(s.records, peripheral.UniqueKey())
+
+			s.elements[key] = s.order.PushFront(key)
+			s.evict()
+
+			return nil, ""
 		}
-	})
 
-	return s
+		s.order.MoveToFront(s.elements[key])
+
+		// the peripheral is still there, so it keeps the time it was found first
+		record.Kind = peripheral.Kind()
+		record.Registered = evt.Registered
+		record.Time = timestamp
+		record.Count++
+		record.peripheral = peripheral
+
+		return s.changeProximity(record, peripheral.Proximity(), timestamp)
+	case notification.PROXIMITY_CHANGED:
+		record, ok := s.records[key]
+
+		if ok {
+			s.order.MoveToFront(s.elements[key])
+			record.Time = timestamp
+			record.peripheral = peripheral
+
+			return s.changeProximity(record, peripheral.Proximity(), timestamp)
+		}
+	case notification.LOST:
+		s.lost++
+		s.remove(key)
+	}
+
+	return nil, ""
+}
+
+func (s *Monitoring) remove(key string) {
+	if element, ok := s.elements[key]; ok {
+		s.order.Remove(element)
+		delete(s.elements, key)
+	}
+
+	delete(s.records, key)
+}
+
+// Removes the least recently updated records over the limit
+func (s *Monitoring) evict() {
+	max := s.settings.MaxRecords
+
+	if max <= 0 {
+		return
+	}
+
+	for len(s.records) > max {
+		oldest := s.order.Back()
+
+		if oldest == nil {
+			return
+		}
+
+		s.remove(oldest.Value.(string))
+		s.evicted++
+	}
+}
+
+func (s *Monitoring) changeProximity(record *Record, proximity string, timestamp time.Time) (*Record, string) {
+	previous := record.Proximity
+
+	if previous == proximity {
+		return nil, ""
+	}
+
+	record.Proximity = proximity
+	record.ProximityChangedAt = &timestamp
+
+	// copying..
+	item := *record
+
+	return &item, previous
+}
+
+// Stop removes the listener from the broker, events arriving afterwards are ignored.
+// Records are kept, so the last known activity can still be read.
+func (s *Monitoring) Stop() {
+	s.mu.Lock()
+
+	if s.stopped {
+		s.mu.Unlock()
+		return
+	}
+
+	s.stopped = true
+	unsubscribe := s.unsubscribe
+	s.unsubscribe = nil
+
+	s.mu.Unlock()
+
+	if unsubscribe != nil {
+		unsubscribe()
+	}
 }
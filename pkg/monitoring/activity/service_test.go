@@ -0,0 +1,562 @@
+package activity_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"github.com/blent/beagle/pkg/clock"
+	"github.com/blent/beagle/pkg/discovery/peripherals"
+	"github.com/blent/beagle/pkg/monitoring/activity"
+	"github.com/blent/beagle/pkg/notification"
+	"github.com/blent/beagle/pkg/notification/notificationtest"
+	"github.com/blent/beagle/pkg/tracking"
+	"github.com/brianvoe/gofakeit"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func createPeripheral() peripherals.Peripheral {
+	return peripherals.NewMockPeripheral(
+		gofakeit.UUID(),
+		"mock",
+		gofakeit.BuzzWord(),
+		nil,
+		-59,
+		-59,
+		gofakeit.IPv4Address(),
+	)
+}
+
+func TestMonitoringRecords(t *testing.T) {
+	broker := notificationtest.NewBroker()
+	recorder := notificationtest.NewRecorder()
+	broker.AddEventListener(recorder.Listener())
+
+	monitoring := activity.New(zap.NewNop()).Use(broker)
+
+	first := createPeripheral()
+	second := createPeripheral()
+
+	broker.Found(first, true)
+	broker.Found(second, false)
+
+	assert.Equal(t, 2, monitoring.Quantity(), "records")
+
+	records := make(map[string]*activity.Record)
+
+	for _, record := range monitoring.GetRecords(0, 0) {
+		records[record.Key] = record
+	}
+
+	assert.True(t, records[first.UniqueKey()].Registered, "registered")
+	assert.False(t, records[second.UniqueKey()].Registered, "not registered")
+	assert.Equal(t, peripherals.PROXIMITY_NEAR, records[first.UniqueKey()].Proximity, "proximity")
+
+	broker.Lost(first, true)
+
+	assert.Equal(t, 1, monitoring.Quantity(), "records")
+	assert.Equal(t, second.UniqueKey(), monitoring.GetRecords(0, 0)[0].Key, "remaining record")
+
+	recorder.AssertCount(t, 3)
+	recorder.AssertCalledWith(t, notification.FOUND, first)
+	recorder.AssertCalledWith(t, notification.LOST, first)
+	recorder.AssertCalledWith(t, notification.FOUND, second)
+}
+
+func TestMonitoringPresence(t *testing.T) {
+	broker := notificationtest.NewBroker()
+	monitoring := activity.New(zap.NewNop()).Use(broker)
+
+	first := createPeripheral()
+	second := createPeripheral()
+
+	assert.False(t, monitoring.IsPresent(first.UniqueKey()), "not found yet")
+	assert.Empty(t, monitoring.PresentKeys(), "no keys")
+
+	broker.Found(first, true)
+	broker.Found(second, false)
+
+	assert.True(t, monitoring.IsPresent(first.UniqueKey()), "registered present")
+	assert.True(t, monitoring.IsPresent(second.UniqueKey()), "not registered present")
+
+	keys := []string{first.UniqueKey(), second.UniqueKey()}
+	sort.Strings(keys)
+
+	assert.Equal(t, keys, monitoring.PresentKeys(), "present keys")
+
+	broker.Lost(first, true)
+
+	assert.False(t, monitoring.IsPresent(first.UniqueKey()), "lost")
+	assert.Equal(t, []string{second.UniqueKey()}, monitoring.PresentKeys(), "remaining keys")
+}
+
+func TestMonitoringStop(t *testing.T) {
+	broker := notificationtest.NewBroker()
+	monitoring := activity.New(zap.NewNop()).Use(broker)
+
+	assert.Equal(t, 1, broker.Listeners(), "listeners")
+
+	broker.Found(createPeripheral(), true)
+
+	var wg sync.WaitGroup
+
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			monitoring.Stop()
+		}()
+	}
+
+	wg.Wait()
+
+	assert.Equal(t, 0, broker.Listeners(), "listeners")
+
+	broker.Found(createPeripheral(), true)
+
+	assert.Equal(t, 1, monitoring.Quantity(), "records kept after stop")
+}
+
+func TestMonitoringSharedBroker(t *testing.T) {
+	broker := notificationtest.NewBroker()
+
+	limited := activity.NewDefaultSettings()
+	limited.MaxRecords = 1
+
+	reporting := activity.New(zap.NewNop()).Use(broker)
+	live := activity.NewWithSettings(zap.NewNop(), limited).Use(broker)
+
+	assert.Equal(t, 2, broker.Listeners(), "listeners")
+
+	first := createPeripheral()
+	second := createPeripheral()
+
+	broker.Found(first, true)
+	broker.Found(second, true)
+
+	assert.Equal(t, 1, live.Quantity(), "live records")
+	assert.Equal(t, uint64(1), live.Evicted(), "live evicted")
+	assert.False(t, live.IsPresent(first.UniqueKey()), "evicted from live")
+	assert.Equal(t, 2, reporting.Quantity(), "reporting records")
+	assert.Equal(t, uint64(0), reporting.Evicted(), "reporting evicted")
+
+	// listeners of both are closures of the same function, stopping one must not unsubscribe the other
+	live.Stop()
+
+	assert.Equal(t, 1, broker.Listeners(), "listeners after stop")
+
+	third := createPeripheral()
+	broker.Found(third, true)
+
+	assert.False(t, live.IsPresent(third.UniqueKey()), "stopped live")
+	assert.True(t, reporting.IsPresent(third.UniqueKey()), "reporting keeps receiving")
+
+	reporting.Stop()
+
+	assert.Equal(t, 0, broker.Listeners(), "no listeners")
+}
+
+func TestMonitoringProximityChanges(t *testing.T) {
+	broker := notificationtest.NewBroker()
+	changes := make([]string, 0, 2)
+
+	monitoring := activity.New(zap.NewNop()).
+		OnProximityChange(func(record activity.Record, previous string) {
+			changes = append(changes, previous+">"+record.Proximity)
+		}).
+		Use(broker)
+
+	near := createPeripheral()
+	// twice the tx power estimates much more than 4 meters
+	far := peripherals.NewMockPeripheral(near.UniqueKey(), "mock", near.LocalName(), nil, -59, -118, near.Address())
+
+	broker.Found(near, true)
+	broker.Found(near, true)
+
+	assert.Nil(t, monitoring.GetRecords(0, 0)[0].ProximityChangedAt, "same proximity")
+
+	broker.Found(far, true)
+
+	record := monitoring.GetRecords(0, 0)[0]
+
+	assert.Equal(t, peripherals.PROXIMITY_FAR, record.Proximity, "proximity")
+	assert.NotNil(t, record.ProximityChangedAt, "changed at")
+	assert.Equal(t, record.Time, *record.ProximityChangedAt, "changed at")
+
+	broker.ProximityChanged(near, true, peripherals.PROXIMITY_FAR)
+
+	assert.Equal(t, []string{"near>far", "far>near"}, changes, "changes")
+}
+
+func TestMonitoringFirstSeen(t *testing.T) {
+	broker := notificationtest.NewBroker()
+	monitoring := activity.New(zap.NewNop()).Use(broker)
+
+	start := time.Now()
+	near := createPeripheral()
+	far := peripherals.NewMockPeripheral(near.UniqueKey(), "mock", near.LocalName(), nil, -59, -118, near.Address())
+
+	for idx, peripheral := range []peripherals.Peripheral{near, near, far} {
+		broker.Publish(notification.Event{
+			Name:       notification.FOUND,
+			Timestamp:  start.Add(time.Duration(idx) * time.Minute),
+			Peripheral: peripheral,
+		})
+	}
+
+	record := monitoring.GetRecords(0, 0)[0]
+
+	assert.True(t, record.FirstSeen.Equal(start), "first seen")
+	assert.True(t, record.Time.Equal(start.Add(time.Minute*2)), "time")
+	assert.Equal(t, peripherals.PROXIMITY_FAR, record.Proximity, "proximity")
+	assert.Equal(t, 3, record.Count, "count")
+
+	broker.Lost(near, false)
+	broker.Publish(notification.Event{
+		Name:       notification.FOUND,
+		Timestamp:  start.Add(time.Hour),
+		Peripheral: near,
+	})
+
+	record = monitoring.GetRecords(0, 0)[0]
+
+	assert.True(t, record.FirstSeen.Equal(start.Add(time.Hour)), "first seen after lost")
+	assert.Equal(t, 1, record.Count, "count after lost")
+}
+
+func TestMonitoringMaxRecords(t *testing.T) {
+	broker := notificationtest.NewBroker()
+	settings := activity.NewDefaultSettings()
+	settings.MaxRecords = 2
+
+	monitoring := activity.NewWithSettings(zap.NewNop(), settings).Use(broker)
+
+	first := createPeripheral()
+	second := createPeripheral()
+	third := createPeripheral()
+
+	broker.Found(first, true)
+	broker.Found(second, true)
+	// first becomes the most recently updated one
+	broker.Found(first, true)
+	broker.Found(third, true)
+
+	keys := make(map[string]bool)
+
+	for _, record := range monitoring.GetRecords(0, 0) {
+		keys[record.Key] = true
+	}
+
+	assert.Equal(t, 2, monitoring.Quantity(), "records")
+	assert.Equal(t, uint64(1), monitoring.Evicted(), "evicted")
+	assert.Equal(t, map[string]bool{first.UniqueKey(): true, third.UniqueKey(): true}, keys, "kept records")
+
+	broker.Lost(first, true)
+	broker.Found(second, true)
+
+	assert.Equal(t, 2, monitoring.Quantity(), "records")
+	assert.Equal(t, uint64(1), monitoring.Evicted(), "evicted")
+}
+
+func TestMonitoringStaleRecords(t *testing.T) {
+	now := time.Now()
+	broker := notificationtest.NewBroker()
+	settings := activity.NewDefaultSettings()
+	settings.Clock = clock.NewMockClock(now)
+
+	monitoring := activity.NewWithSettings(zap.NewNop(), settings).Use(broker)
+
+	fresh := createPeripheral()
+	stale := createPeripheral()
+	older := createPeripheral()
+
+	for peripheral, age := range map[peripherals.Peripheral]time.Duration{
+		fresh: time.Second,
+		stale: time.Second * 30,
+		older: time.Minute,
+	} {
+		broker.Publish(notification.Event{
+			Name:       notification.FOUND,
+			Timestamp:  now.Add(-age),
+			Peripheral: peripheral,
+		})
+	}
+
+	records := monitoring.StaleRecords(time.Second * 10)
+
+	assert.Len(t, records, 2, "stale records")
+	assert.Equal(t, older.UniqueKey(), records[0].Key, "oldest first")
+	assert.Equal(t, stale.UniqueKey(), records[1].Key, "stale record")
+
+	records[0].Proximity = peripherals.PROXIMITY_FAR
+
+	assert.Equal(t, peripherals.PROXIMITY_NEAR, monitoring.StaleRecords(time.Minute - time.Second)[0].Proximity, "copies")
+	assert.Len(t, monitoring.StaleRecords(time.Hour), 0, "no stale records")
+}
+
+func TestMonitoringDefaultSettings(t *testing.T) {
+	broker := notificationtest.NewBroker()
+	settings := activity.NewDefaultSettings()
+	settings.Clock = nil
+
+	for _, monitoring := range []*activity.Monitoring{
+		activity.NewWithSettings(zap.NewNop(), nil).Use(broker),
+		activity.NewWithSettings(zap.NewNop(), settings).Use(broker),
+	} {
+		broker.Publish(notification.Event{
+			Name:       notification.FOUND,
+			Timestamp:  time.Now().Add(-time.Minute),
+			Peripheral: createPeripheral(),
+		})
+
+		assert.NotEmpty(t, monitoring.StaleRecords(time.Second), "stale records")
+		assert.Equal(t, time.UTC, monitoring.Location(), "location")
+	}
+}
+
+func TestMonitoringTimezone(t *testing.T) {
+	broker := notificationtest.NewBroker()
+	settings := activity.NewDefaultSettings()
+	settings.Location = time.FixedZone("UTC+3", 3*60*60)
+
+	monitoring := activity.NewWithSettings(zap.NewNop(), settings).Use(broker)
+
+	timestamp := time.Date(2020, 1, 2, 10, 30, 0, 0, time.FixedZone("UTC-5", -5*60*60))
+	peripheral := createPeripheral()
+
+	broker.Publish(notification.Event{
+		Name:       notification.FOUND,
+		Timestamp:  timestamp,
+		Peripheral: peripheral,
+	})
+
+	record := monitoring.GetRecords(0, 0)[0]
+
+	assert.Equal(t, time.UTC, record.Time.Location(), "stored in utc")
+	assert.True(t, record.Time.Equal(timestamp), "same instant")
+
+	var buf bytes.Buffer
+
+	assert.NoError(t, monitoring.ExportJSON(&buf), "export")
+	assert.True(t, strings.Contains(buf.String(), `"time":"2020-01-02T18:30:00+03:00"`), "rendered in location")
+
+	assert.Equal(t, "2020-01-02T15:30:00Z", record.In(time.UTC).Time.Format(time.RFC3339), "rendered in utc")
+}
+
+type failingWriter struct{}
+
+func (w failingWriter) Write(p []byte) (int, error) {
+	return 0, errors.New("closed")
+}
+
+func TestMonitoringExportJSON(t *testing.T) {
+	broker := notificationtest.NewBroker()
+	monitoring := activity.New(zap.NewNop()).Use(broker)
+
+	var buf bytes.Buffer
+
+	assert.NoError(t, monitoring.ExportJSON(&buf), "export of no records")
+	assert.Empty(t, buf.String(), "no lines")
+
+	keys := make([]string, 0, 3)
+
+	for i := 0; i < 3; i++ {
+		peripheral := createPeripheral()
+		keys = append(keys, peripheral.UniqueKey())
+		broker.Found(peripheral, i%2 == 0)
+	}
+
+	sort.Strings(keys)
+
+	assert.NoError(t, monitoring.ExportJSON(&buf), "export")
+
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+
+	if !assert.Len(t, lines, 3, "a line per record") {
+		return
+	}
+
+	for idx, line := range lines {
+		record := &activity.Record{}
+
+		assert.NoError(t, json.Unmarshal([]byte(line), record), "record object")
+		assert.Equal(t, keys[idx], record.Key, "ordered by key")
+		assert.Equal(t, 1, record.Count, "count")
+	}
+
+	assert.EqualError(t, monitoring.ExportJSON(failingWriter{}), "closed", "writer error")
+}
+
+func TestMonitoringStats(t *testing.T) {
+	broker := notificationtest.NewBroker()
+	monitoring := activity.New(zap.NewNop()).Use(broker)
+
+	first := createPeripheral()
+	second := createPeripheral()
+
+	broker.Found(first, true)
+	broker.Found(second, true)
+	broker.Found(first, true)
+	broker.Lost(second, true)
+
+	assert.Equal(t, &activity.Stats{Records: 1, Found: 3, Lost: 1}, monitoring.Stats(), "stats")
+}
+
+type (
+	presenceRegistry struct {
+		subscribers []*notification.Subscriber
+	}
+
+	presenceSender struct {
+		mu       sync.Mutex
+		messages []*notification.Message
+	}
+)
+
+func (r *presenceRegistry) FindTarget(key string) (*tracking.Peripheral, error) {
+	return &tracking.Peripheral{Id: 1, Key: key, Name: "test", Enabled: true}, nil
+}
+
+func (r *presenceRegistry) FindSubscribers(targetId uint64, events ...string) ([]*notification.Subscriber, error) {
+	return r.subscribers, nil
+}
+
+func (s *presenceSender) Send(msg *notification.Message) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.messages = append(s.messages, msg)
+
+	return nil
+}
+
+func (s *presenceSender) Received() map[uint64]int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	received := make(map[uint64]int)
+
+	for _, msg := range s.messages {
+		for _, subscriber := range msg.Subscribers() {
+			received[subscriber.Id]++
+		}
+	}
+
+	return received
+}
+
+func TestMonitoringRenotification(t *testing.T) {
+	broker := notificationtest.NewBroker()
+	monitoring := activity.New(zap.NewNop()).Use(broker)
+
+	mockClock := clock.NewMockClock(time.Now())
+	settings := notification.NewDefaultRenotifierSettings()
+	settings.Interval = time.Minute * 5
+	settings.Jitter = 0
+	settings.Resolution = time.Minute
+	settings.Clock = mockClock
+
+	frequent := &notification.Subscriber{Id: 1, Endpoint: &notification.Endpoint{}}
+	frequent.Endpoint.Options.RenotifyInterval = 120
+
+	registry := &presenceRegistry{[]*notification.Subscriber{
+		frequent,
+		{Id: 2, Endpoint: &notification.Endpoint{}},
+	}}
+	sender := &presenceSender{}
+
+	renotifier, err := notification.NewRenotifier(zap.NewNop(), settings, monitoring, registry, sender)
+
+	assert.NoError(t, err, "renotifier error")
+
+	renotifier.Start()
+	defer renotifier.Stop()
+
+	peripheral := createPeripheral()
+	broker.Found(peripheral, true)
+
+	for i := 0; i < 10; i++ {
+		mockClock.Add(time.Minute)
+	}
+
+	assert.Equal(t, map[uint64]int{1: 4, 2: 1}, sender.Received(), "present events by subscriber")
+	assert.Equal(t, notification.PRESENT, sender.messages[0].EventName(), "event name")
+	assert.Equal(t, peripheral.UniqueKey(), sender.messages[0].Peripheral().UniqueKey(), "peripheral")
+
+	broker.Lost(peripheral, true)
+	mockClock.Add(time.Minute * 10)
+
+	assert.Equal(t, map[uint64]int{1: 4, 2: 1}, sender.Received(), "no events after lost")
+}
+
+func TestMonitoringImportRecords(t *testing.T) {
+	now := time.Now().UTC()
+	broker := notificationtest.NewBroker()
+	settings := activity.NewDefaultSettings()
+	settings.MaxRecords = 3
+
+	monitoring := activity.NewWithSettings(zap.NewNop(), settings).Use(broker)
+
+	live := createPeripheral()
+
+	broker.Publish(notification.Event{
+		Name:       notification.FOUND,
+		Timestamp:  now,
+		Peripheral: live,
+	})
+
+	err := monitoring.ImportRecords([]*activity.Record{
+		{Key: "imported", Kind: "mock", Time: now.Add(-time.Minute)},
+		{Key: ""},
+	})
+
+	assert.True(t, errors.Is(err, activity.ErrEmptyRecordKey), "empty key")
+	assert.Equal(t, 1, monitoring.Quantity(), "nothing imported")
+
+	err = monitoring.ImportRecords([]*activity.Record{
+		{Key: live.UniqueKey(), Kind: "stale", Time: now.Add(-time.Hour)},
+		{Key: "oldest", Kind: "mock", Time: now.Add(-time.Hour * 2)},
+		{Key: "imported", Kind: "mock", Time: now.Add(-time.Minute * 2)},
+		{Key: "imported", Kind: "newer", Time: now.Add(-time.Minute)},
+	})
+
+	assert.NoError(t, err, "import")
+	assert.Equal(t, 3, monitoring.Quantity(), "records")
+
+	records := make(map[string]*activity.Record)
+
+	for _, record := range monitoring.GetRecords(0, 0) {
+		records[record.Key] = record
+	}
+
+	assert.Equal(t, "mock", records[live.UniqueKey()].Kind, "older import ignored")
+	assert.Equal(t, "newer", records["imported"].Kind, "newest wins")
+
+	err = monitoring.ImportRecords([]*activity.Record{
+		{Key: live.UniqueKey(), Kind: "moved", Time: now.Add(time.Second)},
+		{Key: "recent", Kind: "mock", Time: now.Add(-time.Second)},
+	})
+
+	assert.NoError(t, err, "import")
+	assert.Equal(t, 3, monitoring.Quantity(), "records limit")
+	assert.Equal(t, uint64(1), monitoring.Evicted(), "least recently seen evicted")
+
+	records = make(map[string]*activity.Record)
+
+	for _, record := range monitoring.GetRecords(0, 0) {
+		records[record.Key] = record
+	}
+
+	assert.Equal(t, "moved", records[live.UniqueKey()].Kind, "newer import wins")
+	assert.Contains(t, records, "recent", "recent record")
+	assert.Contains(t, records, "imported", "imported record")
+	assert.NotContains(t, records, "oldest", "oldest record")
+	assert.Len(t, monitoring.Present(), 1, "live peripheral kept")
+}
@@ -0,0 +1,21 @@
+package activity
+
+import (
+	"github.com/blent/beagle/pkg/clock"
+	"time"
+)
+
+type Settings struct {
+	// Maximum number of records, the least recently updated one is evicted to add a new one. 0 disables the limit.
+	MaxRecords int
+	// Time source of record ages, replaceable by clock.MockClock in tests
+	Clock clock.Clock
+	// Timezone of exported and served record times, records are stored in UTC regardless. nil means UTC.
+	Location *time.Location
+}
+
+func NewDefaultSettings() *Settings {
+	return &Settings{
+		Clock: clock.New(),
+	}
+}
@@ -0,0 +1,22 @@
+package activity
+
+type Stats struct {
+	// Number of currently monitored peripherals
+	Records int `json:"records"`
+	// Number of found and lost events received since start
+	Found   uint64 `json:"found"`
+	Lost    uint64 `json:"lost"`
+	Evicted uint64 `json:"evicted"`
+}
+
+func (s *Monitoring) Stats() *Stats {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return &Stats{
+		Records: len(s.records),
+		Found:   s.found,
+		Lost:    s.lost,
+		Evicted: s.evicted,
+	}
+}
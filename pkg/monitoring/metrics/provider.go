@@ -0,0 +1,70 @@
+package metrics
+
+import (
+	"github.com/blent/beagle/pkg/clock"
+	"github.com/blent/beagle/pkg/delivery"
+	"github.com/blent/beagle/pkg/monitoring/activity"
+	"time"
+)
+
+type (
+	ActivitySource interface {
+		Stats() *activity.Stats
+	}
+
+	DeliverySource interface {
+		Stats() *delivery.Stats
+	}
+
+	// Snapshot combines the counters of discovery and delivery taken at the same moment
+	Snapshot struct {
+		Time time.Time `json:"time"`
+		// Seconds since the provider was created
+		Uptime float64 `json:"uptime"`
+		// Average numbers of found and lost events per minute since start
+		FoundRate float64         `json:"foundRate"`
+		LostRate  float64         `json:"lostRate"`
+		Activity  *activity.Stats `json:"activity"`
+		Delivery  *delivery.Stats `json:"delivery"`
+	}
+
+	// MetricsProvider aggregates the stats of the activity monitoring and the sender
+	MetricsProvider struct {
+		activity ActivitySource
+		delivery DeliverySource
+		clock    clock.Clock
+		start    time.Time
+	}
+)
+
+func New(activity ActivitySource, delivery DeliverySource) *MetricsProvider {
+	return NewWithClock(activity, delivery, clock.New())
+}
+
+func NewWithClock(activity ActivitySource, delivery DeliverySource, timeSource clock.Clock) *MetricsProvider {
+	return &MetricsProvider{
+		activity: activity,
+		delivery: delivery,
+		clock:    timeSource,
+		start:    timeSource.Now(),
+	}
+}
+
+func (provider *MetricsProvider) Snapshot() *Snapshot {
+	now := provider.clock.Now()
+	uptime := now.Sub(provider.start)
+
+	snapshot := &Snapshot{
+		Time:     now,
+		Uptime:   uptime.Seconds(),
+		Activity: provider.activity.Stats(),
+		Delivery: provider.delivery.Stats(),
+	}
+
+	if minutes := uptime.Minutes(); minutes > 0 {
+		snapshot.FoundRate = float64(snapshot.Activity.Found) / minutes
+		snapshot.LostRate = float64(snapshot.Activity.Lost) / minutes
+	}
+
+	return snapshot
+}
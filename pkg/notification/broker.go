@@ -1,24 +1,45 @@
 package notification
 
 import (
+	"github.com/blent/beagle/pkg/clock"
 	"github.com/blent/beagle/pkg/discovery/peripherals"
 	"github.com/blent/beagle/pkg/tracking"
 	"github.com/pkg/errors"
 	"go.uber.org/zap"
 	"reflect"
+	"sync"
 	"time"
 )
 
 type (
 	Event struct {
-		Name       string                 `json:"name"`
-		Timestamp  time.Time              `json:"timestamp"`
-		Peripheral peripherals.Peripheral `json:"peripheral"`
-		Registered bool                   `json:"registered"`
+		Name              string                 `json:"name"`
+		Timestamp         time.Time              `json:"timestamp"`
+		Peripheral        peripherals.Peripheral `json:"peripheral"`
+		Registered        bool                   `json:"registered"`
+		PreviousProximity string                 `json:"previousProximity,omitempty"`
+		CorrelationId     string                 `json:"correlationId,omitempty"`
 	}
 
 	EventListener func(evt Event)
 
+	// EventSource is implemented by Broker and by notificationtest.Broker for tests
+	EventSource interface {
+		AddEventListener(listener EventListener)
+
+		// RemoveEventListener removes a listener by its function,
+		// closures of the same function literal are indistinguishable by it
+		RemoveEventListener(listener EventListener) bool
+
+		// Subscribe adds the listener and returns the function removing exactly this subscription,
+		// so any number of consumers can subscribe and leave independently
+		Subscribe(listener EventListener) func()
+	}
+
+	subscription struct {
+		listener EventListener
+	}
+
 	Registry interface {
 		FindTarget(key string) (*tracking.Peripheral, error)
 
@@ -33,7 +54,9 @@ type (
 		logger    *zap.Logger
 		sender    MessageSender
 		registry  Registry
-		listeners []EventListener
+		clock     clock.Clock
+		mu        sync.RWMutex
+		listeners []*subscription
 	}
 )
 
@@ -51,23 +74,29 @@ func NewBroker(logger *zap.Logger, sender MessageSender, registry Registry) (*Br
 	}
 
 	return &Broker{
-		logger,
-		sender,
-		registry,
-		make([]EventListener, 0, 5),
+		logger:    logger,
+		sender:    sender,
+		registry:  registry,
+		clock:     clock.New(),
+		listeners: make([]*subscription, 0, 5),
 	}, nil
 }
 
+// SetClock replaces the time source of event timestamps
+func (broker *Broker) SetClock(timeSource clock.Clock) *Broker {
+	if timeSource != nil {
+		broker.clock = timeSource
+	}
+
+	return broker
+}
+
 func (broker *Broker) Use(stream *tracking.Stream) {
 	go broker.doUse(stream)
 }
 
 func (broker *Broker) AddEventListener(listener EventListener) {
-	if listener == nil {
-		return
-	}
-
-	broker.listeners = append(broker.listeners, listener)
+	broker.Subscribe(listener)
 }
 
 func (broker *Broker) RemoveEventListener(listener EventListener) bool {
@@ -75,11 +104,14 @@ func (broker *Broker) RemoveEventListener(listener EventListener) bool {
 		return false
 	}
 
+	broker.mu.Lock()
+	defer broker.mu.Unlock()
+
 	idx := -1
 	handlerPointer := reflect.ValueOf(listener).Pointer()
 
 	for i, element := range broker.listeners {
-		currentPointer := reflect.ValueOf(element).Pointer()
+		currentPointer := reflect.ValueOf(element.listener).Pointer()
 
 		if currentPointer == handlerPointer {
 			idx = i
@@ -95,6 +127,31 @@ func (broker *Broker) RemoveEventListener(listener EventListener) bool {
 	return true
 }
 
+func (broker *Broker) Subscribe(listener EventListener) func() {
+	if listener == nil {
+		return func() {}
+	}
+
+	sub := &subscription{listener}
+
+	broker.mu.Lock()
+	broker.listeners = append(broker.listeners, sub)
+	broker.mu.Unlock()
+
+	return func() {
+		broker.mu.Lock()
+		defer broker.mu.Unlock()
+
+		for idx, current := range broker.listeners {
+			if current == sub {
+				broker.listeners = append(broker.listeners[:idx], broker.listeners[idx+1:]...)
+
+				return
+			}
+		}
+	}
+}
+
 func (broker *Broker) doUse(stream *tracking.Stream) {
 	streamIsClosed := false
 
@@ -107,13 +164,19 @@ func (broker *Broker) doUse(stream *tracking.Stream) {
 		select {
 		case peripheral, isOpen := <-stream.Found():
 			if isOpen {
-				broker.notify(FOUND, peripheral)
+				broker.notify(FOUND, peripheral, "")
 			}
 
 			streamIsClosed = !isOpen
 		case peripheral, isOpen := <-stream.Lost():
 			if isOpen {
-				broker.notify(LOST, peripheral)
+				broker.notify(LOST, peripheral, "")
+			}
+
+			streamIsClosed = !isOpen
+		case change, isOpen := <-stream.Changed():
+			if isOpen {
+				broker.notify(PROXIMITY_CHANGED, change.Peripheral, change.Previous)
 			}
 
 			streamIsClosed = !isOpen
@@ -128,7 +191,7 @@ func (broker *Broker) doUse(stream *tracking.Stream) {
 	}
 }
 
-func (broker *Broker) notify(eventName string, peripheral peripherals.Peripheral) {
+func (broker *Broker) notify(eventName string, peripheral peripherals.Peripheral, previousProximity string) {
 	go func() {
 		key := peripheral.UniqueKey()
 
@@ -137,13 +200,22 @@ func (broker *Broker) notify(eventName string, peripheral peripherals.Peripheral
 			return
 		}
 
+		// the sender generates another one if it fails
+		correlationId, idErr := GenerateId()
+
+		if idErr != nil {
+			broker.logger.Error("Failed to generate a correlation id", zap.Error(idErr))
+		}
+
 		found, err := broker.registry.FindTarget(key)
 
 		evt := &Event{
-			Timestamp:  time.Now(),
-			Name:       eventName,
-			Peripheral: peripheral,
-			Registered: found != nil,
+			Timestamp:         broker.clock.Now(),
+			Name:              eventName,
+			Peripheral:        peripheral,
+			Registered:        found != nil,
+			PreviousProximity: previousProximity,
+			CorrelationId:     correlationId,
 		}
 
 		if err != nil {
@@ -160,12 +232,19 @@ func (broker *Broker) notify(eventName string, peripheral peripherals.Peripheral
 
 		broker.emit(evt)
 
+		// the sender decides whether unregistered peripherals are delivered to its default endpoints
 		if found == nil {
 			broker.logger.Info(
 				"Peripheral is not registered",
 				zap.String("key", key),
 			)
 
+			broker.sender.Send(NewMessage(eventName, "", peripheral, nil).
+				SetPreviousProximity(previousProximity).
+				SetCorrelationId(correlationId).
+				SetDetectedAt(evt.Timestamp).
+				SetRegistered(false))
+
 			return
 		}
 
@@ -180,22 +259,42 @@ func (broker *Broker) notify(eventName string, peripheral peripherals.Peripheral
 
 		subscribers, err := broker.registry.FindSubscribers(found.Id, eventName, "*")
 
+		if err != nil {
+			broker.logger.Error(
+				"Failed to retrieve subscribers",
+				zap.String("key", key),
+				zap.Error(err),
+			)
+
+			return
+		}
+
+		// the sender may still deliver it to its default endpoints
 		if subscribers == nil || len(subscribers) == 0 {
 			broker.logger.Info(
 				"Peripheral does not have any enabled subscribers",
 				zap.String("key", key),
 			)
-			return
 		}
 
-		broker.sender.Send(NewMessage(eventName, found.Name, peripheral, subscribers))
+		msg := NewMessage(eventName, found.Name, peripheral, subscribers).
+			SetPreviousProximity(previousProximity).
+			SetCorrelationId(correlationId).
+			SetDetectedAt(evt.Timestamp).
+			SetMetadata(found.Metadata)
+
+		broker.sender.Send(msg)
 	}()
 }
 
 func (broker *Broker) emit(evt *Event) {
+	broker.mu.RLock()
+	listeners := append([]*subscription(nil), broker.listeners...)
+	broker.mu.RUnlock()
+
 	go func() {
-		for _, handler := range broker.listeners {
-			handler(*evt)
+		for _, sub := range listeners {
+			sub.listener(*evt)
 		}
 	}()
 }
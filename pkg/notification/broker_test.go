@@ -0,0 +1,204 @@
+package notification_test
+
+import (
+	"errors"
+	"github.com/blent/beagle/pkg/clock"
+	"github.com/blent/beagle/pkg/discovery/peripherals"
+	"github.com/blent/beagle/pkg/notification"
+	"github.com/blent/beagle/pkg/tracking"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"sync"
+	"testing"
+	"time"
+)
+
+type (
+	fakeRegistry struct {
+		targets     map[string]*tracking.Peripheral
+		subscribers map[string][]*notification.Subscriber
+		err         error
+	}
+
+	// Passes sent messages to a channel, as the broker sends them from its own goroutines
+	fakeSender struct {
+		messages chan *notification.Message
+	}
+)
+
+func (registry *fakeRegistry) FindTarget(key string) (*tracking.Peripheral, error) {
+	return registry.targets[key], nil
+}
+
+func (registry *fakeRegistry) FindSubscribers(targetId uint64, events ...string) ([]*notification.Subscriber, error) {
+	if registry.err != nil {
+		return nil, registry.err
+	}
+
+	return registry.subscribers[events[0]], nil
+}
+
+func (sender *fakeSender) Send(msg *notification.Message) error {
+	sender.messages <- msg
+
+	return nil
+}
+
+func createBroker(t *testing.T, registry notification.Registry) (*notification.Broker, *fakeSender) {
+	sender := &fakeSender{messages: make(chan *notification.Message, 10)}
+	broker, err := notification.NewBroker(zap.NewNop(), sender, registry)
+
+	assert.NoError(t, err, "broker")
+
+	return broker, sender
+}
+
+func createStream() (*tracking.Stream, chan peripherals.Peripheral, chan peripherals.Peripheral, chan *tracking.ProximityChange) {
+	found := make(chan peripherals.Peripheral, 1)
+	lost := make(chan peripherals.Peripheral, 1)
+	changed := make(chan *tracking.ProximityChange, 1)
+
+	return tracking.NewStream(found, lost, changed, make(chan error)), found, lost, changed
+}
+
+func receive(t *testing.T, sender *fakeSender) *notification.Message {
+	select {
+	case msg := <-sender.messages:
+		return msg
+	case <-time.After(time.Second):
+		t.Fatal("no message sent")
+	}
+
+	return nil
+}
+
+func TestBrokerProximityChanges(t *testing.T) {
+	subscriber := &notification.Subscriber{Id: 1, Name: "lobby", Event: notification.PROXIMITY_CHANGED, Enabled: true}
+	registry := &fakeRegistry{
+		targets: map[string]*tracking.Peripheral{
+			"beacon": {Id: 1, Key: "beacon", Name: "entrance", Enabled: true},
+		},
+		subscribers: map[string][]*notification.Subscriber{
+			notification.PROXIMITY_CHANGED: {subscriber},
+		},
+	}
+
+	broker, sender := createBroker(t, registry)
+
+	var mu sync.Mutex
+
+	events := make([]notification.Event, 0, 1)
+
+	broker.AddEventListener(func(evt notification.Event) {
+		mu.Lock()
+		events = append(events, evt)
+		mu.Unlock()
+	})
+
+	stream, _, _, changed := createStream()
+	broker.Use(stream)
+
+	peripheral := peripherals.NewMockPeripheral("beacon", "mock", "beacon", nil, -59, -177, "")
+	changed <- &tracking.ProximityChange{Peripheral: peripheral, Previous: peripherals.PROXIMITY_NEAR}
+
+	msg := receive(t, sender)
+
+	assert.Equal(t, notification.PROXIMITY_CHANGED, msg.EventName(), "event name")
+	assert.Equal(t, "entrance", msg.TargetName(), "target name")
+	assert.Equal(t, peripherals.PROXIMITY_NEAR, msg.PreviousProximity(), "previous proximity")
+	assert.Equal(t, []*notification.Subscriber{subscriber}, msg.Subscribers(), "subscribers")
+
+	assert.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+
+		return len(events) == 1
+	}, time.Second, time.Millisecond*10, "events")
+
+	mu.Lock()
+	assert.Equal(t, peripherals.PROXIMITY_NEAR, events[0].PreviousProximity, "previous proximity of the event")
+	mu.Unlock()
+}
+
+func TestBrokerSubscribersFailure(t *testing.T) {
+	registry := &fakeRegistry{
+		targets: map[string]*tracking.Peripheral{
+			"beacon": {Id: 1, Key: "beacon", Name: "entrance", Enabled: true},
+		},
+		err: errors.New("storage is down"),
+	}
+
+	broker, sender := createBroker(t, registry)
+
+	stream, found, _, _ := createStream()
+	broker.Use(stream)
+
+	found <- peripherals.NewMockPeripheral("beacon", "mock", "beacon", nil, -59, -59, "")
+
+	// a message without subscribers would be delivered to the default endpoints
+	select {
+	case msg := <-sender.messages:
+		t.Fatalf("message sent for %s", msg.TargetName())
+	case <-time.After(time.Millisecond * 50):
+	}
+}
+
+func TestBrokerClock(t *testing.T) {
+	now := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	registry := &fakeRegistry{
+		targets: map[string]*tracking.Peripheral{
+			"beacon": {Id: 1, Key: "beacon", Name: "entrance", Enabled: true},
+		},
+	}
+
+	broker, sender := createBroker(t, registry)
+	broker.SetClock(clock.NewMockClock(now))
+
+	events := make(chan notification.Event, 1)
+
+	broker.AddEventListener(func(evt notification.Event) {
+		events <- evt
+	})
+
+	stream, found, _, _ := createStream()
+	broker.Use(stream)
+
+	found <- peripherals.NewMockPeripheral("beacon", "mock", "beacon", nil, -59, -59, "")
+
+	msg := receive(t, sender)
+
+	assert.True(t, now.Equal(msg.DetectedAt()), "detection time")
+
+	select {
+	case evt := <-events:
+		assert.True(t, now.Equal(evt.Timestamp), "event timestamp")
+	case <-time.After(time.Second):
+		t.Fatal("no event")
+	}
+}
+
+func TestBrokerMetadata(t *testing.T) {
+	registry := &fakeRegistry{
+		targets: map[string]*tracking.Peripheral{
+			"beacon": {
+				Id:       1,
+				Key:      "beacon",
+				Name:     "entrance",
+				Enabled:  true,
+				Metadata: tracking.Metadata{"floor": "2"},
+			},
+		},
+	}
+
+	broker, sender := createBroker(t, registry)
+
+	stream, found, _, _ := createStream()
+	broker.Use(stream)
+
+	found <- peripherals.NewMockPeripheral("beacon", "mock", "beacon", nil, -59, -59, "")
+
+	msg := receive(t, sender)
+
+	assert.True(t, msg.Registered(), "registered")
+	assert.Equal(t, map[string]string{"floor": "2"}, msg.Metadata(), "metadata of the registered peripheral")
+}
@@ -9,14 +9,83 @@ import (
 type (
 	Headers  map[string]string
 	Endpoint struct {
-		Id      uint64  `json:"id"`
-		Name    string  `json:"name"`
-		Url     string  `json:"url"`
-		Method  string  `json:"method"`
-		Headers Headers `json:"headers"`
+		Id      uint64          `json:"id"`
+		Name    string          `json:"name"`
+		Url     string          `json:"url"`
+		Method  string          `json:"method"`
+		Headers Headers         `json:"headers"`
+		Options EndpointOptions `json:"options"`
+		// Disabled endpoints keep their configuration but receive nothing, nil means enabled
+		Enabled *bool `json:"enabled,omitempty"`
+	}
+
+	// EndpointOptions override the sender settings for a particular endpoint
+	EndpointOptions struct {
+		// Delivery timeout in milliseconds, 0 inherits the sender default
+		Timeout uint64 `json:"timeout"`
+		// Timeout of establishing a connection in milliseconds, 0 inherits the sender default
+		ConnectTimeout uint64 `json:"connectTimeout,omitempty"`
+		// Name of the serializer of request bodies, empty inherits the sender default
+		Serializer string `json:"serializer,omitempty"`
+		// Name of the schema request bodies must conform to, empty disables validation
+		Schema string `json:"schema,omitempty"`
+		// Limit of request bodies in bytes, larger ones fail before they are sent, 0 disables the limit
+		MaxBodySize uint64 `json:"maxBodySize,omitempty"`
+		// Path requested by probes of the endpoint with GET, empty probes the endpoint url with HEAD
+		HealthPath string `json:"healthPath,omitempty"`
+		// Limit of concurrent requests to the endpoint, 0 inherits the sender default
+		MaxInFlight int `json:"maxInFlight,omitempty"`
+		// Proximity bands of peripherals delivered to the endpoint, empty matches any band
+		Proximity []string `json:"proximity,omitempty"`
+		// Range of the estimated distance of peripherals delivered to the endpoint, nil matches any distance
+		Distance *DistanceRange `json:"distance,omitempty"`
+		// Expression over serialized fields peripherals delivered to the endpoint must meet, e.g. proximity == "immediate" && major == 1,
+		// empty matches any peripheral
+		Condition string `json:"condition,omitempty"`
+		// Serialized fields sent to the endpoint by their names before renaming, empty sends all of them
+		Fields []string `json:"fields,omitempty"`
+		// Serialized fields never sent to the endpoint
+		ExcludeFields []string `json:"excludeFields,omitempty"`
+		// Key of the peripheral name in payloads and queries of the endpoint, e.g. device, empty keeps the key named by the sender
+		NameKey string `json:"nameKey,omitempty"`
+		// Interval of present events in seconds, 0 inherits the renotifier default
+		RenotifyInterval uint64 `json:"renotifyInterval,omitempty"`
+		// Interval of sending collected messages as a single JSON array in milliseconds, 0 sends every message right away
+		BatchInterval uint64 `json:"batchInterval,omitempty"`
+		// Number of collected messages which sends the batch before its interval elapses, 0 waits for the interval
+		BatchSize int `json:"batchSize,omitempty"`
+		// Headers merged over the endpoint headers for deliveries of an event, keyed by the event name
+		EventHeaders map[string]Headers `json:"eventHeaders,omitempty"`
+		// Equivalent targets sharing the load of the endpoint, nil sends every request to the endpoint url
+		Balance *BalanceGroup `json:"balance,omitempty"`
+	}
+
+	// BalanceGroup sends every request of an endpoint to a single one of its targets, failing over to the others
+	BalanceGroup struct {
+		// How the target of a request is picked, weighted or round_robin, empty is weighted
+		Mode    string          `json:"mode,omitempty"`
+		Targets []BalanceTarget `json:"targets"`
+	}
+
+	// BalanceTarget is a target of a balance group, its scheme and host replace the ones of the endpoint url
+	// and its path prefixes the endpoint path
+	BalanceTarget struct {
+		Url string `json:"url"`
+		// Share of requests sent to the target, 0 only takes requests failed by the others
+		Weight int `json:"weight"`
+	}
+
+	// DistanceRange bounds the estimated distance in meters, 0 leaves a bound open
+	DistanceRange struct {
+		Min float64 `json:"min,omitempty"`
+		Max float64 `json:"max,omitempty"`
 	}
 )
 
+func (e *Endpoint) IsEnabled() bool {
+	return e.Enabled == nil || *e.Enabled
+}
+
 func (h Headers) Value() (driver.Value, error) {
 	j, err := json.Marshal(h)
 
@@ -46,3 +115,34 @@ func (h Headers) Scan(src interface{}) error {
 
 	return nil
 }
+
+func (o EndpointOptions) Value() (driver.Value, error) {
+	j, err := json.Marshal(o)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return driver.Value(string(j)), nil
+}
+
+func (o *EndpointOptions) Scan(src interface{}) error {
+	var value []byte
+
+	switch src := src.(type) {
+	case nil:
+		return nil
+	case []byte:
+		value = src
+	case string:
+		value = []byte(src)
+	default:
+		return fmt.Errorf("options field must be a string, got %T instead", src)
+	}
+
+	if len(value) == 0 {
+		return nil
+	}
+
+	return json.Unmarshal(value, o)
+}
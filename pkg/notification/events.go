@@ -1,6 +1,9 @@
 package notification
 
 const (
-	FOUND = "found"
-	LOST  = "lost"
+	FOUND             = "found"
+	LOST              = "lost"
+	PROXIMITY_CHANGED = "proximity_changed"
+	// Periodic re-notification of a peripheral which is still present
+	PRESENT = "present"
 )
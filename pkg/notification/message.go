@@ -1,24 +1,34 @@
 package notification
 
 import (
+	"context"
 	"github.com/blent/beagle/pkg/discovery/peripherals"
+	"time"
 )
 
 type (
 	Message struct {
-		eventName   string
-		targetName  string
-		peripheral  peripherals.Peripheral
-		subscribers []*Subscriber
+		eventName         string
+		targetName        string
+		peripheral        peripherals.Peripheral
+		subscribers       []*Subscriber
+		previousProximity string
+		correlationId     string
+		detectedAt        time.Time
+		// zero value keeps messages registered, as most of them are
+		unregistered bool
+		metadata     map[string]string
+		// bounds the deliveries of the message, retries included, nil means none
+		ctx context.Context
 	}
 )
 
 func NewMessage(eventName, targetName string, peripheral peripherals.Peripheral, subscribers []*Subscriber) *Message {
 	return &Message{
-		eventName,
-		targetName,
-		peripheral,
-		subscribers,
+		eventName:   eventName,
+		targetName:  targetName,
+		peripheral:  peripheral,
+		subscribers: subscribers,
 	}
 }
 
@@ -37,3 +47,103 @@ func (event *Message) Peripheral() peripherals.Peripheral {
 func (event *Message) Subscribers() []*Subscriber {
 	return event.subscribers
 }
+
+// PreviousProximity returns the proximity a peripheral had before a proximity change
+func (event *Message) PreviousProximity() string {
+	return event.previousProximity
+}
+
+func (event *Message) SetPreviousProximity(proximity string) *Message {
+	event.previousProximity = proximity
+
+	return event
+}
+
+// CorrelationId returns the id tracing the event from its discovery to deliveries
+func (event *Message) CorrelationId() string {
+	return event.correlationId
+}
+
+func (event *Message) SetCorrelationId(id string) *Message {
+	event.correlationId = id
+
+	return event
+}
+
+// DetectedAt returns the time the event was detected, zero if unknown
+func (event *Message) DetectedAt() time.Time {
+	return event.detectedAt
+}
+
+func (event *Message) SetDetectedAt(detectedAt time.Time) *Message {
+	event.detectedAt = detectedAt
+
+	return event
+}
+
+// Registered tells whether the peripheral is registered, messages are registered unless set otherwise
+func (event *Message) Registered() bool {
+	return !event.unregistered
+}
+
+func (event *Message) SetRegistered(registered bool) *Message {
+	event.unregistered = !registered
+
+	return event
+}
+
+// Metadata returns details of a registered peripheral, e.g. its label or tags, nil if there are none
+func (event *Message) Metadata() map[string]string {
+	return event.metadata
+}
+
+func (event *Message) SetMetadata(metadata map[string]string) *Message {
+	event.metadata = metadata
+
+	return event
+}
+
+// Context returns the context bounding the deliveries of the message, context.Background() if none is set
+func (event *Message) Context() context.Context {
+	if event.ctx == nil {
+		return context.Background()
+	}
+
+	return event.ctx
+}
+
+func (event *Message) SetContext(ctx context.Context) *Message {
+	event.ctx = ctx
+
+	return event
+}
+
+// Snapshot copies the message with its peripheral, so it can be delivered asynchronously
+// while discovery goes on with the original peripheral
+func (event *Message) Snapshot() *Message {
+	subscribers := make([]*Subscriber, len(event.subscribers))
+	copy(subscribers, event.subscribers)
+
+	var metadata map[string]string
+
+	if event.metadata != nil {
+		metadata = make(map[string]string, len(event.metadata))
+
+		for key, value := range event.metadata {
+			metadata[key] = value
+		}
+	}
+
+	return &Message{
+		eventName:         event.eventName,
+		targetName:        event.targetName,
+		peripheral:        peripherals.Snapshot(event.peripheral),
+		subscribers:       subscribers,
+		previousProximity: event.previousProximity,
+		correlationId:     event.correlationId,
+		detectedAt:        event.detectedAt,
+		unregistered:      event.unregistered,
+		metadata:          metadata,
+		ctx:               event.ctx,
+	}
+}
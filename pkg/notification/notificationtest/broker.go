@@ -0,0 +1,138 @@
+// Package notificationtest provides utilities for testing consumers of notification events.
+package notificationtest
+
+import (
+	"github.com/blent/beagle/pkg/discovery/peripherals"
+	"github.com/blent/beagle/pkg/notification"
+	"reflect"
+	"sync"
+	"time"
+)
+
+type (
+	// Broker is an in-memory notification.EventSource, published events reach the listeners
+	// synchronously in the goroutine calling Publish, so tests need no waiting
+	Broker struct {
+		mu        sync.Mutex
+		listeners []*subscription
+		published []notification.Event
+	}
+
+	subscription struct {
+		listener notification.EventListener
+	}
+)
+
+func NewBroker() *Broker {
+	return &Broker{
+		listeners: make([]*subscription, 0, 5),
+		published: make([]notification.Event, 0, 10),
+	}
+}
+
+func (broker *Broker) AddEventListener(listener notification.EventListener) {
+	broker.Subscribe(listener)
+}
+
+func (broker *Broker) RemoveEventListener(listener notification.EventListener) bool {
+	if listener == nil {
+		return false
+	}
+
+	broker.mu.Lock()
+	defer broker.mu.Unlock()
+
+	pointer := reflect.ValueOf(listener).Pointer()
+
+	for idx, current := range broker.listeners {
+		if reflect.ValueOf(current.listener).Pointer() == pointer {
+			broker.listeners = append(broker.listeners[:idx], broker.listeners[idx+1:]...)
+
+			return true
+		}
+	}
+
+	return false
+}
+
+func (broker *Broker) Subscribe(listener notification.EventListener) func() {
+	if listener == nil {
+		return func() {}
+	}
+
+	sub := &subscription{listener}
+
+	broker.mu.Lock()
+	broker.listeners = append(broker.listeners, sub)
+	broker.mu.Unlock()
+
+	return func() {
+		broker.mu.Lock()
+		defer broker.mu.Unlock()
+
+		for idx, current := range broker.listeners {
+			if current == sub {
+				broker.listeners = append(broker.listeners[:idx], broker.listeners[idx+1:]...)
+
+				return
+			}
+		}
+	}
+}
+
+// Listeners returns the number of added listeners
+func (broker *Broker) Listeners() int {
+	broker.mu.Lock()
+	defer broker.mu.Unlock()
+
+	return len(broker.listeners)
+}
+
+// Publish records the event and passes it to the listeners, a zero timestamp is set to the current time
+func (broker *Broker) Publish(evt notification.Event) {
+	if evt.Timestamp.IsZero() {
+		evt.Timestamp = time.Now()
+	}
+
+	broker.mu.Lock()
+	broker.published = append(broker.published, evt)
+	listeners := append([]*subscription(nil), broker.listeners...)
+	broker.mu.Unlock()
+
+	for _, sub := range listeners {
+		sub.listener(evt)
+	}
+}
+
+func (broker *Broker) Found(peripheral peripherals.Peripheral, registered bool) {
+	broker.Publish(notification.Event{
+		Name:       notification.FOUND,
+		Peripheral: peripheral,
+		Registered: registered,
+	})
+}
+
+func (broker *Broker) Lost(peripheral peripherals.Peripheral, registered bool) {
+	broker.Publish(notification.Event{
+		Name:       notification.LOST,
+		Peripheral: peripheral,
+		Registered: registered,
+	})
+}
+
+func (broker *Broker) ProximityChanged(peripheral peripherals.Peripheral, registered bool, previous string) {
+	broker.Publish(notification.Event{
+		Name:              notification.PROXIMITY_CHANGED,
+		Peripheral:        peripheral,
+		Registered:        registered,
+		PreviousProximity: previous,
+	})
+}
+
+// Published returns a copy of all published events in order
+func (broker *Broker) Published() []notification.Event {
+	broker.mu.Lock()
+	defer broker.mu.Unlock()
+
+	return append([]notification.Event(nil), broker.published...)
+}
@@ -0,0 +1,65 @@
+package notificationtest
+
+import (
+	"github.com/blent/beagle/pkg/discovery/peripherals"
+	"github.com/blent/beagle/pkg/notification"
+	"sync"
+	"testing"
+)
+
+// Recorder records events passed to its listener for assertions
+type Recorder struct {
+	mu     sync.Mutex
+	events []notification.Event
+}
+
+func NewRecorder() *Recorder {
+	return &Recorder{
+		events: make([]notification.Event, 0, 10),
+	}
+}
+
+func (recorder *Recorder) Listener() notification.EventListener {
+	return func(evt notification.Event) {
+		recorder.mu.Lock()
+		defer recorder.mu.Unlock()
+
+		recorder.events = append(recorder.events, evt)
+	}
+}
+
+// Events returns a copy of the recorded events in order
+func (recorder *Recorder) Events() []notification.Event {
+	recorder.mu.Lock()
+	defer recorder.mu.Unlock()
+
+	return append([]notification.Event(nil), recorder.events...)
+}
+
+// AssertCalledWith checks that the listener got the event of the peripheral, peripherals are compared by unique keys
+func (recorder *Recorder) AssertCalledWith(t testing.TB, eventName string, peripheral peripherals.Peripheral) bool {
+	t.Helper()
+
+	for _, evt := range recorder.Events() {
+		if evt.Name == eventName && evt.Peripheral != nil && evt.Peripheral.UniqueKey() == peripheral.UniqueKey() {
+			return true
+		}
+	}
+
+	t.Errorf("listener was not called with %s event of peripheral %s", eventName, peripheral.UniqueKey())
+
+	return false
+}
+
+// AssertCount checks the number of recorded events
+func (recorder *Recorder) AssertCount(t testing.TB, expected int) bool {
+	t.Helper()
+
+	if actual := len(recorder.Events()); actual != expected {
+		t.Errorf("listener was called %d times, expected %d", actual, expected)
+
+		return false
+	}
+
+	return true
+}
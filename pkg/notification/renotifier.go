@@ -0,0 +1,256 @@
+package notification
+
+import (
+	"github.com/blent/beagle/pkg/clock"
+	"github.com/blent/beagle/pkg/discovery/peripherals"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+type (
+	// PresenceSource lists peripherals currently present, implemented by the activity monitoring
+	PresenceSource interface {
+		Present() []peripherals.Peripheral
+	}
+
+	RenotifierSettings struct {
+		// Default interval of present events, subscriber endpoints may override it, 0 disables them
+		Interval time.Duration
+		// Fraction of the interval by which every next event is randomly moved earlier or later
+		Jitter float64
+		// How often present peripherals are checked for due events
+		Resolution time.Duration
+		Clock      clock.Clock
+	}
+
+	// Renotifier periodically sends PRESENT messages of peripherals which are still present
+	// to the subscribers of the event, every subscriber at the interval of its endpoint.
+	Renotifier struct {
+		mu       sync.Mutex
+		logger   *zap.Logger
+		settings *RenotifierSettings
+		presence PresenceSource
+		registry Registry
+		sender   MessageSender
+		random   *rand.Rand
+		// next due time by peripheral key and subscriber id
+		due     map[string]map[uint64]time.Time
+		timer   clock.Timer
+		running bool
+	}
+)
+
+func NewDefaultRenotifierSettings() *RenotifierSettings {
+	return &RenotifierSettings{
+		Jitter:     0.1,
+		Resolution: time.Second * 10,
+		Clock:      clock.New(),
+	}
+}
+
+func NewRenotifier(logger *zap.Logger, settings *RenotifierSettings, presence PresenceSource, registry Registry, sender MessageSender) (*Renotifier, error) {
+	if logger == nil {
+		return nil, errors.Wrap(ErrMissedArg, "logger")
+	}
+
+	if presence == nil {
+		return nil, errors.Wrap(ErrMissedArg, "presence")
+	}
+
+	if registry == nil {
+		return nil, errors.Wrap(ErrMissedArg, "registry")
+	}
+
+	if sender == nil {
+		return nil, errors.Wrap(ErrMissedArg, "sender")
+	}
+
+	if settings == nil {
+		settings = NewDefaultRenotifierSettings()
+	}
+
+	return &Renotifier{
+		logger:   logger,
+		settings: settings,
+		presence: presence,
+		registry: registry,
+		sender:   sender,
+		random:   rand.New(rand.NewSource(time.Now().UnixNano())),
+		due:      make(map[string]map[uint64]time.Time),
+	}, nil
+}
+
+func (r *Renotifier) Start() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.running || r.settings.Resolution <= 0 {
+		return
+	}
+
+	r.running = true
+	r.schedule()
+}
+
+func (r *Renotifier) Stop() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.running = false
+
+	if r.timer != nil {
+		r.timer.Stop()
+		r.timer = nil
+	}
+}
+
+// Called with the lock held
+func (r *Renotifier) schedule() {
+	r.timer = r.settings.Clock.AfterFunc(r.settings.Resolution, func() {
+		r.check()
+
+		r.mu.Lock()
+		defer r.mu.Unlock()
+
+		if r.running {
+			r.schedule()
+		}
+	})
+}
+
+func (r *Renotifier) check() {
+	now := r.settings.Clock.Now()
+	present := make(map[string]bool)
+
+	for _, peripheral := range r.presence.Present() {
+		key := peripheral.UniqueKey()
+
+		if key == "" {
+			continue
+		}
+
+		present[key] = true
+
+		r.notify(peripheral, now)
+	}
+
+	r.mu.Lock()
+
+	// a peripheral seen again later starts over with the found event
+	for key := range r.due {
+		if !present[key] {
+			delete(r.due, key)
+		}
+	}
+
+	r.mu.Unlock()
+}
+
+func (r *Renotifier) notify(peripheral peripherals.Peripheral, now time.Time) {
+	key := peripheral.UniqueKey()
+	found, err := r.registry.FindTarget(key)
+
+	if err != nil {
+		r.logger.Error(
+			"Failed to retrieve a peripheral",
+			zap.String("key", key),
+			zap.Error(err),
+		)
+
+		return
+	}
+
+	if found == nil || !found.Enabled {
+		return
+	}
+
+	subscribers, err := r.registry.FindSubscribers(found.Id, PRESENT)
+
+	if err != nil {
+		r.logger.Error(
+			"Failed to retrieve subscribers",
+			zap.String("key", key),
+			zap.Error(err),
+		)
+
+		return
+	}
+
+	due := r.dueSubscribers(key, subscribers, now)
+
+	if len(due) == 0 {
+		return
+	}
+
+	msg := NewMessage(PRESENT, found.Name, peripheral, due)
+
+	if correlationId, idErr := GenerateId(); idErr == nil {
+		msg.SetCorrelationId(correlationId)
+	}
+
+	if err := r.sender.Send(msg); err != nil {
+		r.logger.Error(
+			"Failed to send a present event",
+			zap.String("key", key),
+			zap.Error(err),
+		)
+	}
+}
+
+// Returns the subscribers whose interval has passed and schedules their next events
+func (r *Renotifier) dueSubscribers(key string, subscribers []*Subscriber, now time.Time) []*Subscriber {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	next, ok := r.due[key]
+
+	if !ok {
+		next = make(map[uint64]time.Time)
+		r.due[key] = next
+	}
+
+	result := make([]*Subscriber, 0, len(subscribers))
+
+	for _, subscriber := range subscribers {
+		interval := r.interval(subscriber)
+
+		if interval <= 0 {
+			continue
+		}
+
+		at, scheduled := next[subscriber.Id]
+
+		// the found event has just been sent, the first present one follows after the interval
+		if scheduled && !now.Before(at) {
+			result = append(result, subscriber)
+		}
+
+		if !scheduled || !now.Before(at) {
+			next[subscriber.Id] = now.Add(r.jitter(interval))
+		}
+	}
+
+	return result
+}
+
+func (r *Renotifier) interval(subscriber *Subscriber) time.Duration {
+	if subscriber.Endpoint != nil && subscriber.Endpoint.Options.RenotifyInterval > 0 {
+		return time.Duration(subscriber.Endpoint.Options.RenotifyInterval) * time.Second
+	}
+
+	return r.settings.Interval
+}
+
+// Called with the lock held
+func (r *Renotifier) jitter(interval time.Duration) time.Duration {
+	if r.settings.Jitter <= 0 {
+		return interval
+	}
+
+	spread := float64(interval) * r.settings.Jitter
+
+	return interval + time.Duration(spread*(2*r.random.Float64()-1))
+}
@@ -7,5 +7,7 @@ type (
 		Event    string    `json:"event"`
 		Endpoint *Endpoint `json:"endpoint"`
 		Enabled  bool      `json:"enabled"`
+		// Priority subscribers are notified during quiet hours
+		Priority bool `json:"priority"`
 	}
 )
@@ -1,9 +1,52 @@
 package tracking
 
-type Peripheral struct {
-	Id      uint64 `json:"id"`
-	Key     string `json:"key"`
-	Name    string `json:"name"`
-	Kind    string `json:"kind"`
-	Enabled bool   `json:"enabled"`
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+)
+
+type (
+	// Metadata holds details of a registered peripheral, e.g. its label or tags, delivered with its notifications
+	Metadata map[string]string
+
+	Peripheral struct {
+		Id       uint64   `json:"id"`
+		Key      string   `json:"key"`
+		Name     string   `json:"name"`
+		Kind     string   `json:"kind"`
+		Enabled  bool     `json:"enabled"`
+		Metadata Metadata `json:"metadata,omitempty"`
+	}
+)
+
+func (m Metadata) Value() (driver.Value, error) {
+	j, err := json.Marshal(m)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return driver.Value(string(j)), nil
+}
+
+func (m *Metadata) Scan(src interface{}) error {
+	var value []byte
+
+	switch src := src.(type) {
+	case nil:
+		return nil
+	case []byte:
+		value = src
+	case string:
+		value = []byte(src)
+	default:
+		return fmt.Errorf("metadata field must be a string, got %T instead", src)
+	}
+
+	if len(value) == 0 {
+		return nil
+	}
+
+	return json.Unmarshal(value, m)
 }
@@ -1,10 +1,17 @@
 package tracking
 
-import "time"
+import (
+	"github.com/blent/beagle/pkg/clock"
+	"time"
+)
 
 type Settings struct {
 	Ttl       time.Duration
 	Heartbeat time.Duration
+	// Number of consecutive readings in a new proximity band required to report a proximity change
+	ProximityConfirmations int
+	// Time source of peripheral ttls, the real clock if nil
+	Clock clock.Clock
 }
 
 func (s *Settings) Equals(other *Settings) bool {
@@ -20,5 +27,9 @@ func (s *Settings) Equals(other *Settings) bool {
 		return false
 	}
 
+	if s.ProximityConfirmations != other.ProximityConfirmations {
+		return false
+	}
+
 	return true
 }
@@ -2,14 +2,22 @@ package tracking
 
 import "github.com/blent/beagle/pkg/discovery/peripherals"
 
-type Stream struct {
-	found <-chan peripherals.Peripheral
-	lost  <-chan peripherals.Peripheral
-	error <-chan error
-}
+type (
+	ProximityChange struct {
+		Peripheral peripherals.Peripheral
+		Previous   string
+	}
+
+	Stream struct {
+		found   <-chan peripherals.Peripheral
+		lost    <-chan peripherals.Peripheral
+		changed <-chan *ProximityChange
+		error   <-chan error
+	}
+)
 
-func NewStream(found <-chan peripherals.Peripheral, lost <-chan peripherals.Peripheral, error <-chan error) *Stream {
-	return &Stream{found, lost, error}
+func NewStream(found <-chan peripherals.Peripheral, lost <-chan peripherals.Peripheral, changed <-chan *ProximityChange, error <-chan error) *Stream {
+	return &Stream{found, lost, changed, error}
 }
 
 func (stream *Stream) Found() <-chan peripherals.Peripheral {
@@ -20,6 +28,10 @@ func (stream *Stream) Lost() <-chan peripherals.Peripheral {
 	return stream.lost
 }
 
+func (stream *Stream) Changed() <-chan *ProximityChange {
+	return stream.changed
+}
+
 func (stream *Stream) Error() <-chan error {
 	return stream.error
 }
@@ -1,23 +1,30 @@
 package tracking
 
 import (
+	"github.com/blent/beagle/pkg/clock"
 	"github.com/blent/beagle/pkg/discovery/peripherals"
 	"time"
 )
 
 type (
 	Track struct {
-		peripheral peripherals.Peripheral
-		ttl        time.Duration
-		lastSeen   time.Time
+		peripheral    peripherals.Peripheral
+		clock         clock.Clock
+		ttl           time.Duration
+		lastSeen      time.Time
+		proximity     string
+		candidate     string
+		confirmations int
 	}
 )
 
-func NewTrack(peripheral peripherals.Peripheral, ttl time.Duration) *Track {
+func NewTrack(peripheral peripherals.Peripheral, ttl time.Duration, timeSource clock.Clock) *Track {
 	return &Track{
 		peripheral: peripheral,
+		clock:      timeSource,
 		ttl:        ttl,
-		lastSeen:   time.Now(),
+		lastSeen:   timeSource.Now(),
+		proximity:  peripheral.Proximity(),
 	}
 }
 
@@ -26,9 +33,43 @@ func (record *Track) Peripheral() peripherals.Peripheral {
 }
 
 func (record *Track) Update() {
-	record.lastSeen = time.Now()
+	record.lastSeen = record.clock.Now()
+}
+
+// Observe stores the latest state of the peripheral and reports a proximity change
+// once the new proximity has been seen in the given number of consecutive readings.
+// Returns the previously reported proximity when the change is confirmed.
+func (record *Track) Observe(peripheral peripherals.Peripheral, confirmations int) (string, bool) {
+	record.peripheral = peripheral
+
+	proximity := peripheral.Proximity()
+
+	if proximity == record.proximity {
+		record.candidate = ""
+		record.confirmations = 0
+
+		return "", false
+	}
+
+	if proximity != record.candidate {
+		record.candidate = proximity
+		record.confirmations = 0
+	}
+
+	record.confirmations++
+
+	if record.confirmations < confirmations {
+		return "", false
+	}
+
+	previous := record.proximity
+	record.proximity = proximity
+	record.candidate = ""
+	record.confirmations = 0
+
+	return previous, true
 }
 
 func (record *Track) IsActive() bool {
-	return record.ttl > time.Since(record.lastSeen)
+	return record.ttl > record.clock.Now().Sub(record.lastSeen)
 }
@@ -4,6 +4,7 @@ import (
 	"context"
 	"time"
 
+	"github.com/blent/beagle/pkg/clock"
 	"github.com/blent/beagle/pkg/discovery"
 	"github.com/blent/beagle/pkg/discovery/devices"
 	"github.com/blent/beagle/pkg/discovery/peripherals"
@@ -19,16 +20,24 @@ type (
 		logger    *zap.Logger
 		device    devices.Device
 		settings  *Settings
+		clock     clock.Clock
 		tracks    map[string]*Track
 		isRunning bool
 	}
 )
 
 func NewTracker(logger *zap.Logger, device devices.Device, settings *Settings) *Tracker {
+	timeSource := settings.Clock
+
+	if timeSource == nil {
+		timeSource = clock.New()
+	}
+
 	return &Tracker{
 		logger:    logger,
 		device:    device,
 		settings:  settings,
+		clock:     timeSource,
 		tracks:    make(map[string]*Track),
 		isRunning: false,
 	}
@@ -49,6 +58,7 @@ func (tracker *Tracker) Track(ctx context.Context) (*Stream, error) {
 
 	inFound := make(chan peripherals.Peripheral, bufferSize)
 	inLost := make(chan peripherals.Peripheral, bufferSize)
+	inChanged := make(chan *ProximityChange, bufferSize)
 	inError := make(chan error)
 
 	output, err := tracker.device.Scan(ctx)
@@ -59,13 +69,13 @@ func (tracker *Tracker) Track(ctx context.Context) (*Stream, error) {
 
 	tracker.isRunning = true
 
-	go tracker.start(ctx, output, inFound, inLost, inError)
-	go tracker.stopOnDone(ctx, inFound, inLost, inError)
+	go tracker.start(ctx, output, inFound, inLost, inChanged, inError)
+	go tracker.stopOnDone(ctx, inFound, inLost, inChanged, inError)
 
-	return NewStream(inFound, inLost, inError), nil
+	return NewStream(inFound, inLost, inChanged, inError), nil
 }
 
-func (tracker *Tracker) start(ctx context.Context, stream *discovery.Stream, inFound chan<- peripherals.Peripheral, inLost chan<- peripherals.Peripheral, inError chan<- error) {
+func (tracker *Tracker) start(ctx context.Context, stream *discovery.Stream, inFound chan<- peripherals.Peripheral, inLost chan<- peripherals.Peripheral, inChanged chan<- *ProximityChange, inError chan<- error) {
 	tracker.logger.Info("Started tracking")
 
 	done := false
@@ -87,7 +97,7 @@ func (tracker *Tracker) start(ctx context.Context, stream *discovery.Stream, inF
 			done = !isOpen
 
 			if done == false {
-				tracker.push(peripheral, inFound)
+				tracker.push(peripheral, inFound, inChanged)
 			}
 		case err, _ := <-stream.Error():
 			done = true
@@ -104,11 +114,12 @@ func (tracker *Tracker) start(ctx context.Context, stream *discovery.Stream, inF
 	}
 }
 
-func (tracker *Tracker) stopOnDone(ctx context.Context, inFound chan peripherals.Peripheral, inLost chan peripherals.Peripheral, inError chan error) {
+func (tracker *Tracker) stopOnDone(ctx context.Context, inFound chan peripherals.Peripheral, inLost chan peripherals.Peripheral, inChanged chan *ProximityChange, inError chan error) {
 	<-ctx.Done()
 	tracker.isRunning = false
 	close(inFound)
 	close(inLost)
+	close(inChanged)
 	close(inError)
 }
 
@@ -135,7 +146,7 @@ func (tracker *Tracker) heartbeat(inLost chan<- peripherals.Peripheral) {
 	tracker.tracks = active
 }
 
-func (tracker *Tracker) push(peripheral peripherals.Peripheral, inFound chan<- peripherals.Peripheral) {
+func (tracker *Tracker) push(peripheral peripherals.Peripheral, inFound chan<- peripherals.Peripheral, inChanged chan<- *ProximityChange) {
 	if peripheral == nil {
 		return
 	}
@@ -146,8 +157,21 @@ func (tracker *Tracker) push(peripheral peripherals.Peripheral, inFound chan<- p
 
 	if ok {
 		found.Update()
+
+		previous, changed := found.Observe(peripheral, tracker.settings.ProximityConfirmations)
+
+		if changed {
+			inChanged <- &ProximityChange{peripheral, previous}
+
+			tracker.logger.Info(
+				"Peripheral changed proximity",
+				zap.String("key", key),
+				zap.String("previous", previous),
+				zap.String("current", peripheral.Proximity()),
+			)
+		}
 	} else {
-		tracker.tracks[key] = NewTrack(peripheral, tracker.settings.Ttl)
+		tracker.tracks[key] = NewTrack(peripheral, tracker.settings.Ttl, tracker.clock)
 		inFound <- peripheral
 
 		tracker.logger.Info(
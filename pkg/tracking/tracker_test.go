@@ -0,0 +1,144 @@
+package tracking_test
+
+import (
+	"context"
+	"github.com/blent/beagle/pkg/clock"
+	"github.com/blent/beagle/pkg/discovery"
+	"github.com/blent/beagle/pkg/discovery/peripherals"
+	"github.com/blent/beagle/pkg/tracking"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"testing"
+	"time"
+)
+
+// Scans peripherals pushed to its channel
+type fakeDevice struct {
+	data chan peripherals.Peripheral
+}
+
+func newFakeDevice() *fakeDevice {
+	return &fakeDevice{data: make(chan peripherals.Peripheral, 10)}
+}
+
+func (device *fakeDevice) IsScanning() bool {
+	return false
+}
+
+func (device *fakeDevice) Scan(ctx context.Context) (*discovery.Stream, error) {
+	return discovery.NewStream(device.data, make(chan error)), nil
+}
+
+// Equal power and rssi put a peripheral 1 meter away, thrice the power puts it far
+func createPeripheral(key string, rssi float64) peripherals.Peripheral {
+	return peripherals.NewMockPeripheral(key, "mock", key, nil, -59, rssi, "")
+}
+
+func TestTrackerProximityChanges(t *testing.T) {
+	device := newFakeDevice()
+	tracker := tracking.NewTracker(zap.NewNop(), device, &tracking.Settings{
+		Ttl:                    time.Hour,
+		Heartbeat:              time.Hour,
+		ProximityConfirmations: 2,
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	stream, err := tracker.Track(ctx)
+
+	if !assert.NoError(t, err, "track") {
+		return
+	}
+
+	device.data <- createPeripheral("beacon", -59)
+
+	select {
+	case found := <-stream.Found():
+		assert.Equal(t, peripherals.PROXIMITY_NEAR, found.Proximity(), "found")
+	case <-time.After(time.Second):
+		t.Fatal("no found peripheral")
+	}
+
+	// a single reading in another band is not enough, another one in the former band resets it
+	device.data <- createPeripheral("beacon", -177)
+	device.data <- createPeripheral("beacon", -59)
+	device.data <- createPeripheral("beacon", -177)
+	device.data <- createPeripheral("beacon", -177)
+
+	select {
+	case change := <-stream.Changed():
+		assert.Equal(t, peripherals.PROXIMITY_NEAR, change.Previous, "previous proximity")
+		assert.Equal(t, peripherals.PROXIMITY_FAR, change.Peripheral.Proximity(), "current proximity")
+	case <-time.After(time.Second):
+		t.Fatal("no proximity change")
+	}
+
+	select {
+	case change := <-stream.Changed():
+		t.Fatalf("unconfirmed change from %s", change.Previous)
+	case <-time.After(time.Millisecond * 50):
+	}
+}
+
+func TestTrackerTtl(t *testing.T) {
+	mockClock := clock.NewMockClock(time.Now())
+	device := newFakeDevice()
+	tracker := tracking.NewTracker(zap.NewNop(), device, &tracking.Settings{
+		Ttl:                    time.Minute,
+		Heartbeat:              time.Millisecond * 10,
+		ProximityConfirmations: 1,
+		Clock:                  mockClock,
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	stream, err := tracker.Track(ctx)
+
+	if !assert.NoError(t, err, "track") {
+		return
+	}
+
+	device.data <- createPeripheral("beacon", -59)
+
+	select {
+	case <-stream.Found():
+	case <-time.After(time.Second):
+		t.Fatal("no found peripheral")
+	}
+
+	// heartbeats pass, but the peripheral is not lost until its ttl passes by the clock
+	select {
+	case lost := <-stream.Lost():
+		t.Fatalf("lost %s before its ttl", lost.UniqueKey())
+	case <-time.After(time.Millisecond * 50):
+	}
+
+	// a reading renews the ttl, it is in another band to tell when it is taken
+	mockClock.Add(time.Second * 40)
+	device.data <- createPeripheral("beacon", -177)
+
+	select {
+	case <-stream.Changed():
+	case <-time.After(time.Second):
+		t.Fatal("reading not taken")
+	}
+
+	mockClock.Add(time.Second * 40)
+
+	select {
+	case lost := <-stream.Lost():
+		t.Fatalf("lost %s despite a renewed ttl", lost.UniqueKey())
+	case <-time.After(time.Millisecond * 50):
+	}
+
+	mockClock.Add(time.Second * 20)
+
+	select {
+	case lost := <-stream.Lost():
+		assert.Equal(t, "beacon", lost.UniqueKey(), "lost peripheral")
+	case <-time.After(time.Second):
+		t.Fatal("no lost peripheral")
+	}
+}
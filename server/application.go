@@ -54,10 +54,26 @@ func (app *Application) Run() error {
 	// Closes db connection
 	defer app.container.GetStorageProvider().Close()
 
+	// Closes websocket connections once the queue is delivered
+	defer app.container.GetWebSocketTransport().Close()
+
+	// Delivers queued notifications
+	defer app.container.GetSender().Close()
+
 	app.container.GetEventBroker().Use(stream)
 
 	app.container.GetActivityWriter().Use(app.container.GetEventBroker())
 	app.container.GetActivityService().Use(app.container.GetEventBroker())
+	defer app.container.GetActivityService().Stop()
+
+	app.container.GetRenotifier().Start()
+	defer app.container.GetRenotifier().Stop()
+
+	app.container.GetHeartbeat().Start()
+	defer app.container.GetHeartbeat().Stop()
+
+	app.container.GetWarmUp().Start()
+	defer app.container.GetWarmUp().Stop()
 
 	err = app.container.GetServer().Run(ctx)
 
@@ -3,8 +3,10 @@ package server
 import (
 	"github.com/blent/beagle/pkg/delivery"
 	"github.com/blent/beagle/pkg/discovery/devices"
+	"github.com/blent/beagle/pkg/discovery/peripherals"
 	"github.com/blent/beagle/pkg/history/activity"
 	activityMonitor "github.com/blent/beagle/pkg/monitoring/activity"
+	"github.com/blent/beagle/pkg/monitoring/metrics"
 	systemMonitor "github.com/blent/beagle/pkg/monitoring/system"
 	"github.com/blent/beagle/pkg/notification"
 	"github.com/blent/beagle/pkg/tracking"
@@ -26,6 +28,11 @@ type Container struct {
 	initializers    map[string]initialization.Initializer
 	tracker         *tracking.Tracker
 	eventBroker     *notification.Broker
+	renotifier      *notification.Renotifier
+	heartbeat       *delivery.Heartbeat
+	warmUp          *delivery.WarmUp
+	sender          *delivery.Sender
+	webSockets      *delivery.WebSocketTransport
 	storageProvider storage.Provider
 	activityService *activityMonitor.Monitoring
 	activityWriter  *activity.Writer
@@ -45,7 +52,7 @@ func NewContainer(settings *Settings) (*Container, error) {
 	}
 
 	// Core
-	device, err := devices.NewDevice(logger.Named("device"))
+	device, err := devices.NewDevice(logger.Named("device"), peripherals.NewFactory(settings.AccuracyCalculator))
 
 	if err != nil {
 		return nil, err
@@ -72,7 +79,7 @@ func NewContainer(settings *Settings) (*Container, error) {
 	activityWriter := activity.New(logger.Named("activity:writer"))
 
 	// Monitoring
-	activityService := activityMonitor.New(logger.Named("activity:monitor"))
+	activityService := activityMonitor.NewWithSettings(logger.Named("activity:monitor"), settings.Activity)
 
 	if err != nil {
 		return nil, err
@@ -84,12 +91,41 @@ func NewContainer(settings *Settings) (*Container, error) {
 		return nil, err
 	}
 
+	httpTransport := delivery.NewHttpTransport(logger.Named("transport")).
+		SetMaxResponseSize(settings.Delivery.MaxResponseSize)
+
+	// attempts of the transport would multiply the attempts of the sender
+	if settings.Delivery.MaxAttempts > 1 {
+		httpTransport.SetMaxRetries(1)
+	}
+
+	unixTransport := delivery.NewUnixTransport(logger.Named("transport:unix")).
+		SetMaxResponseSize(settings.Delivery.MaxResponseSize)
+
+	webSocketTransport := delivery.NewWebSocketTransport(logger.Named("transport:websocket")).
+		SetReconnectPolicy(settings.Delivery.Reconnect)
+
+	transport := delivery.NewTransportRegistry().
+		Register("http", httpTransport).
+		Register("https", httpTransport).
+		Register(delivery.UNIX_SCHEME, unixTransport).
+		Register(delivery.WS_SCHEME, webSocketTransport).
+		Register(delivery.WSS_SCHEME, webSocketTransport)
+
+	// file endpoints are opt-in, as they write to the host file system
+	if settings.Delivery.FileRoot != "" {
+		transport.Register(delivery.FILE_SCHEME, delivery.NewFileTransport(logger.Named("transport:file"), settings.Delivery.FileRoot))
+	}
+
+	sender := delivery.NewWithSettings(logger.Named("sender"), transport, settings.Delivery)
+
+	if settings.Delivery.ConnectionEvents {
+		webSocketTransport.OnConnectionChange(sender.EmitConnectionChange)
+	}
+
 	eventBroker, err := notification.NewBroker(
 		logger.Named("broker"),
-		delivery.New(
-			logger.Named("sender"),
-			delivery.NewHttpTransport(logger.Named("transport")),
-		),
+		sender,
 		registry,
 	)
 
@@ -97,6 +133,32 @@ func NewContainer(settings *Settings) (*Container, error) {
 		return nil, err
 	}
 
+	renotifier, err := notification.NewRenotifier(
+		logger.Named("renotifier"),
+		settings.Renotify,
+		activityService,
+		registry,
+		sender,
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	heartbeat := delivery.NewHeartbeat(
+		logger.Named("heartbeat"),
+		settings.Heartbeat,
+		activityService,
+		registry,
+		sender,
+	)
+
+	warmUp := delivery.NewWarmUp(
+		logger.Named("warmup"),
+		settings.WarmUp,
+		sender,
+	)
+
 	// Http
 	var webServer *http.Server
 
@@ -108,6 +170,7 @@ func NewContainer(settings *Settings) (*Container, error) {
 			logger.Named("route:monitoring"),
 			activityService,
 			systemMonitor.New(logger.Named("service:monitoring:system")),
+			metrics.New(activityService, sender),
 		)
 
 		peripheralsRoute := routes.NewPeripheralsRoute(
@@ -120,6 +183,7 @@ func NewContainer(settings *Settings) (*Container, error) {
 			path.Join(settings.Http.Api.Route, "registry"),
 			logger.Named("route:endpoints"),
 			storageManager,
+			sender,
 		)
 
 		inits["routes"] = initializers.NewRoutesInitializer(
@@ -140,6 +204,11 @@ func NewContainer(settings *Settings) (*Container, error) {
 		inits,
 		tracker,
 		eventBroker,
+		renotifier,
+		heartbeat,
+		warmUp,
+		sender,
+		webSocketTransport,
 		storageProvider,
 		activityService,
 		activityWriter,
@@ -172,6 +241,26 @@ func (c *Container) GetEventBroker() *notification.Broker {
 	return c.eventBroker
 }
 
+func (c *Container) GetRenotifier() *notification.Renotifier {
+	return c.renotifier
+}
+
+func (c *Container) GetHeartbeat() *delivery.Heartbeat {
+	return c.heartbeat
+}
+
+func (c *Container) GetWarmUp() *delivery.WarmUp {
+	return c.warmUp
+}
+
+func (c *Container) GetSender() *delivery.Sender {
+	return c.sender
+}
+
+func (c *Container) GetWebSocketTransport() *delivery.WebSocketTransport {
+	return c.webSockets
+}
+
 func (c *Container) GetStorageProvider() storage.Provider {
 	return c.storageProvider
 }
@@ -1,6 +1,7 @@
 package routes
 
 import (
+	"github.com/blent/beagle/pkg/delivery"
 	"github.com/blent/beagle/pkg/notification"
 	"github.com/blent/beagle/server/storage"
 	"github.com/blent/beagle/server/utils"
@@ -19,10 +20,11 @@ type EndpointsRoute struct {
 	baseUrl string
 	logger  *zap.Logger
 	storage *storage.Manager
+	sender  *delivery.Sender
 }
 
-func NewEndpointsRoute(baseUrl string, logger *zap.Logger, storage *storage.Manager) *EndpointsRoute {
-	return &EndpointsRoute{baseUrl, logger, storage}
+func NewEndpointsRoute(baseUrl string, logger *zap.Logger, storage *storage.Manager, sender *delivery.Sender) *EndpointsRoute {
+	return &EndpointsRoute{baseUrl, logger, storage, sender}
 }
 
 func (rt *EndpointsRoute) Use(routes gin.IRoutes) {
@@ -41,6 +43,12 @@ func (rt *EndpointsRoute) Use(routes gin.IRoutes) {
 	// Update existing endpoint by id
 	routes.PUT(path.Join("/", rt.baseUrl, singular), rt.updateEndpoint)
 
+	// Send a test notification to existing endpoint by id
+	routes.POST(path.Join("/", rt.baseUrl, singular, ":id", "test"), rt.testEndpoint)
+
+	// Check reachability of existing endpoint by id
+	routes.POST(path.Join("/", rt.baseUrl, singular, ":id", "probe"), rt.probeEndpoint)
+
 	// Delete existing endpoint by id
 	routes.DELETE(path.Join("/", rt.baseUrl, singular, ":id"), rt.deleteEndpoint)
 
@@ -110,6 +118,100 @@ func (rt *EndpointsRoute) getEndpoint(ctx *gin.Context) {
 	ctx.JSON(http.StatusOK, endpoint)
 }
 
+func (rt *EndpointsRoute) testEndpoint(ctx *gin.Context) {
+	id, err := utils.StringToUint64(ctx.Params.ByName("id"))
+
+	if err != nil {
+		rt.logger.Error("Failed to parse endpoint id", zap.Error(err))
+		ctx.AbortWithError(http.StatusBadRequest, errors.New("missed id"))
+		return
+	}
+
+	endpoint, err := rt.storage.GetEndpoint(id)
+
+	if err != nil {
+		rt.logger.Error(
+			"Failed to retrieve endpoint",
+			zap.Uint64("id", id),
+			zap.Error(err),
+		)
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	if endpoint == nil {
+		ctx.AbortWithStatus(http.StatusNotFound)
+		return
+	}
+
+	evt, err := rt.sender.SendTest(ctx.Request.Context(), endpoint)
+
+	result := gin.H{
+		"delivered":     evt.Delivered,
+		"duration":      evt.Duration.Milliseconds(),
+		"correlationId": evt.CorrelationId,
+	}
+
+	if err != nil {
+		result["error"] = err.Error()
+		result["category"] = evt.Category
+
+		var deliveryErr *delivery.DeliveryError
+
+		if errors.As(err, &deliveryErr) && deliveryErr.StatusCode > 0 {
+			result["statusCode"] = deliveryErr.StatusCode
+		}
+	}
+
+	ctx.JSON(http.StatusOK, result)
+}
+
+func (rt *EndpointsRoute) probeEndpoint(ctx *gin.Context) {
+	id, err := utils.StringToUint64(ctx.Params.ByName("id"))
+
+	if err != nil {
+		rt.logger.Error("Failed to parse endpoint id", zap.Error(err))
+		ctx.AbortWithError(http.StatusBadRequest, errors.New("missed id"))
+		return
+	}
+
+	endpoint, err := rt.storage.GetEndpoint(id)
+
+	if err != nil {
+		rt.logger.Error(
+			"Failed to retrieve endpoint",
+			zap.Uint64("id", id),
+			zap.Error(err),
+		)
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	if endpoint == nil {
+		ctx.AbortWithStatus(http.StatusNotFound)
+		return
+	}
+
+	err = rt.sender.Probe(ctx.Request.Context(), endpoint)
+
+	result := gin.H{
+		"reachable": err == nil,
+	}
+
+	if err != nil {
+		result["error"] = err.Error()
+		result["category"] = delivery.CategorizeError(err)
+
+		var statusErr *delivery.StatusError
+
+		if errors.As(err, &statusErr) {
+			result["statusCode"] = statusErr.StatusCode
+		}
+	}
+
+	ctx.JSON(http.StatusOK, result)
+}
+
 func (rt *EndpointsRoute) createEndpoint(ctx *gin.Context) {
 	endpoint, ok := rt.deserializeEndpoint(ctx)
 
@@ -218,5 +320,76 @@ func (rt *EndpointsRoute) deserializeEndpoint(ctx *gin.Context) (*notification.E
 		return nil, false
 	}
 
+	if endpoint == nil {
+		ctx.AbortWithError(http.StatusBadRequest, ErrEndpointsRouteInvalidEndpoint)
+
+		return nil, false
+	}
+
+	if err := delivery.ValidateHeaders(endpoint.Headers); err != nil {
+		rt.logger.Error("Invalid endpoint headers", zap.Error(err))
+		ctx.AbortWithError(http.StatusBadRequest, err)
+
+		return nil, false
+	}
+
+	for event, headers := range endpoint.Options.EventHeaders {
+		if err := delivery.ValidateHeaders(headers); err != nil {
+			rt.logger.Error("Invalid endpoint event headers", zap.String("event name", event), zap.Error(err))
+			ctx.AbortWithError(http.StatusBadRequest, err)
+
+			return nil, false
+		}
+	}
+
+	if err := delivery.ValidateFields(endpoint); err != nil {
+		rt.logger.Error("Invalid endpoint fields", zap.Error(err))
+		ctx.AbortWithError(http.StatusBadRequest, err)
+
+		return nil, false
+	}
+
+	if err := rt.sender.ValidateNameKey(endpoint); err != nil {
+		rt.logger.Error("Invalid endpoint name key", zap.Error(err))
+		ctx.AbortWithError(http.StatusBadRequest, err)
+
+		return nil, false
+	}
+
+	if err := delivery.ValidateRouting(endpoint); err != nil {
+		rt.logger.Error("Invalid endpoint routing", zap.Error(err))
+		ctx.AbortWithError(http.StatusBadRequest, err)
+
+		return nil, false
+	}
+
+	if err := delivery.ValidateBalance(endpoint); err != nil {
+		rt.logger.Error("Invalid endpoint balance group", zap.Error(err))
+		ctx.AbortWithError(http.StatusBadRequest, err)
+
+		return nil, false
+	}
+
+	if err := delivery.ValidateBatch(endpoint); err != nil {
+		rt.logger.Error("Invalid endpoint batch", zap.Error(err))
+		ctx.AbortWithError(http.StatusBadRequest, err)
+
+		return nil, false
+	}
+
+	if err := rt.sender.ValidateSchema(endpoint); err != nil {
+		rt.logger.Error("Invalid endpoint schema", zap.Error(err))
+		ctx.AbortWithError(http.StatusBadRequest, err)
+
+		return nil, false
+	}
+
+	if err := delivery.ValidateEnv(endpoint); err != nil {
+		rt.logger.Error("Invalid endpoint environment variables", zap.Error(err))
+		ctx.AbortWithError(http.StatusBadRequest, err)
+
+		return nil, false
+	}
+
 	return endpoint, true
 }
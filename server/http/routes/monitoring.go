@@ -2,6 +2,7 @@ package routes
 
 import (
 	"github.com/blent/beagle/pkg/monitoring/activity"
+	"github.com/blent/beagle/pkg/monitoring/metrics"
 	"github.com/blent/beagle/pkg/monitoring/system"
 	"github.com/blent/beagle/server/utils"
 	"github.com/gin-gonic/gin"
@@ -9,6 +10,7 @@ import (
 	"go.uber.org/zap"
 	"net/http"
 	"path"
+	"time"
 )
 
 type MonitoringRoute struct {
@@ -16,10 +18,11 @@ type MonitoringRoute struct {
 	logger   *zap.Logger
 	activity *activity.Monitoring
 	system   *system.Monitoring
+	metrics  *metrics.MetricsProvider
 }
 
-func NewMonitoringRoute(baseUrl string, logger *zap.Logger, activity *activity.Monitoring, system *system.Monitoring) *MonitoringRoute {
-	return &MonitoringRoute{baseUrl, logger, activity, system}
+func NewMonitoringRoute(baseUrl string, logger *zap.Logger, activity *activity.Monitoring, system *system.Monitoring, metrics *metrics.MetricsProvider) *MonitoringRoute {
+	return &MonitoringRoute{baseUrl, logger, activity, system, metrics}
 }
 
 func (rt *MonitoringRoute) Use(routes gin.IRoutes) {
@@ -41,11 +44,41 @@ func (rt *MonitoringRoute) Use(routes gin.IRoutes) {
 		}
 
 		ctx.JSON(http.StatusOK, gin.H{
-			"items":    rt.activity.GetRecords(int(take), int(skip)),
+			"items":    rt.localize(rt.activity.GetRecords(int(take), int(skip))),
 			"quantity": rt.activity.Quantity(),
 		})
 	})
 
+	routes.GET(path.Join("/", rt.baseUrl, "activity", "stale"), func(ctx *gin.Context) {
+		olderThan, err := utils.StringToUint64(ctx.Query("olderThan"))
+
+		if err != nil {
+			rt.logger.Error("failed to parse parameter: olderThan")
+			ctx.AbortWithError(http.StatusBadRequest, errors.New("invalid parameter: olderThan"))
+			return
+		}
+
+		ctx.JSON(http.StatusOK, gin.H{
+			"items": rt.localize(rt.activity.StaleRecords(time.Second * time.Duration(olderThan))),
+		})
+	})
+
+	routes.GET(path.Join("/", rt.baseUrl, "activity", "export"), func(ctx *gin.Context) {
+		ctx.Header("Content-Type", "application/x-ndjson")
+		ctx.Status(http.StatusOK)
+
+		if err := rt.activity.ExportJSON(ctx.Writer); err != nil {
+			rt.logger.Error(
+				"Failed to export activity",
+				zap.Error(err),
+			)
+		}
+	})
+
+	routes.GET(path.Join("/", rt.baseUrl, "metrics"), func(ctx *gin.Context) {
+		ctx.JSON(http.StatusOK, rt.metrics.Snapshot())
+	})
+
 	routes.GET(path.Join("/", rt.baseUrl, "system"), func(ctx *gin.Context) {
 		stats, err := rt.system.GetStats()
 
@@ -63,3 +96,15 @@ func (rt *MonitoringRoute) Use(routes gin.IRoutes) {
 		ctx.JSON(http.StatusOK, stats)
 	})
 }
+
+// Renders record times in the timezone of the activity monitoring
+func (rt *MonitoringRoute) localize(records []*activity.Record) []*activity.Record {
+	location := rt.activity.Location()
+
+	for idx, record := range records {
+		item := record.In(location)
+		records[idx] = &item
+	}
+
+	return records
+}
@@ -30,6 +30,7 @@ type (
 		Major       uint16                     `json:"major, omitempty"`
 		Minor       uint16                     `json:"minor, omitempty"`
 		Subscribers []*notification.Subscriber `json:"subscribers"`
+		Metadata    tracking.Metadata          `json:"metadata,omitempty"`
 	}
 
 	PeripheralsRoute struct {
@@ -244,6 +245,7 @@ func (rt *PeripheralsRoute) serializePeripheral(target *tracking.Peripheral, sub
 		Name:        target.Name,
 		Enabled:     target.Enabled,
 		Subscribers: subscribers,
+		Metadata:    target.Metadata,
 	}
 
 	switch target.Kind {
@@ -313,11 +315,12 @@ func (rt *PeripheralsRoute) deserializePeripheral(ctx *gin.Context) (*tracking.P
 	}
 
 	peripheral := &tracking.Peripheral{
-		Id:      dto.Id,
-		Key:     key,
-		Name:    dto.Name,
-		Kind:    dto.Kind,
-		Enabled: dto.Enabled,
+		Id:       dto.Id,
+		Key:      key,
+		Name:     dto.Name,
+		Kind:     dto.Kind,
+		Enabled:  dto.Enabled,
+		Metadata: dto.Metadata,
 	}
 
 	return peripheral, dto.Subscribers, nil
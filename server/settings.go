@@ -1,6 +1,10 @@
 package server
 
 import (
+	"github.com/blent/beagle/pkg/delivery"
+	"github.com/blent/beagle/pkg/discovery/peripherals"
+	"github.com/blent/beagle/pkg/monitoring/activity"
+	"github.com/blent/beagle/pkg/notification"
 	"github.com/blent/beagle/pkg/tracking"
 	"github.com/blent/beagle/server/http"
 	"github.com/blent/beagle/server/storage"
@@ -8,11 +12,18 @@ import (
 )
 
 type Settings struct {
-	Version  string
-	Name     string
-	Http     *http.Settings
-	Storage  *storage.Settings
-	Tracking *tracking.Settings
+	Version   string
+	Name      string
+	Http      *http.Settings
+	Storage   *storage.Settings
+	Tracking  *tracking.Settings
+	Delivery  *delivery.Settings
+	Activity  *activity.Settings
+	Renotify  *notification.RenotifierSettings
+	Heartbeat *delivery.HeartbeatSettings
+	WarmUp    *delivery.WarmUpSettings
+	// Estimates distances to discovered peripherals, nil keeps the built-in estimation
+	AccuracyCalculator peripherals.AccuracyCalculator
 }
 
 func NewDefaultSettings() *Settings {
@@ -35,8 +46,14 @@ func NewDefaultSettings() *Settings {
 			Provider:         "sqlite3",
 		},
 		Tracking: &tracking.Settings{
-			Heartbeat: time.Second * 5,
-			Ttl:       time.Second * 5,
+			Heartbeat:              time.Second * 5,
+			Ttl:                    time.Second * 5,
+			ProximityConfirmations: 3,
 		},
+		Delivery:  delivery.NewDefaultSettings(),
+		Activity:  activity.NewDefaultSettings(),
+		Renotify:  notification.NewDefaultRenotifierSettings(),
+		Heartbeat: delivery.NewDefaultHeartbeatSettings(),
+		WarmUp:    delivery.NewDefaultWarmUpSettings(),
 	}
 }
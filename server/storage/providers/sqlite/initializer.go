@@ -5,7 +5,23 @@ import (
 	"fmt"
 )
 
-type tableCreator func(tx *sql.Tx) error
+type (
+	tableCreator func(tx *sql.Tx) error
+
+	column struct {
+		table      string
+		name       string
+		definition string
+	}
+)
+
+// Columns added after the initial schema, databases created earlier get them on startup
+var addedColumns = []column{
+	{endpointTableName, "options", "TEXT"},
+	{endpointTableName, "enabled", "INTEGER NOT NULL DEFAULT 1"},
+	{subscriberTableName, "priority", "INTEGER NOT NULL DEFAULT 0"},
+	{peripheralTableName, "metadata", "TEXT"},
+}
 
 func initialize(tx *sql.Tx) (bool, error) {
 	tables, err := getTableCreators(tx)
@@ -14,10 +30,6 @@ func initialize(tx *sql.Tx) (bool, error) {
 		return false, err
 	}
 
-	if len(tables) == 0 {
-		return false, nil
-	}
-
 	for _, table := range tables {
 		if err = table(tx); err != nil {
 			break
@@ -28,7 +40,68 @@ func initialize(tx *sql.Tx) (bool, error) {
 		return false, err
 	}
 
-	return true, nil
+	migrated, err := addColumns(tx)
+
+	if err != nil {
+		return false, err
+	}
+
+	return len(tables) > 0 || migrated, nil
+}
+
+func addColumns(tx *sql.Tx) (bool, error) {
+	added := false
+
+	for _, col := range addedColumns {
+		exists, err := hasColumn(tx, col.table, col.name)
+
+		if err != nil {
+			return false, err
+		}
+
+		if exists {
+			continue
+		}
+
+		_, err = tx.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s;", col.table, col.name, col.definition))
+
+		if err != nil {
+			return false, err
+		}
+
+		added = true
+	}
+
+	return added, nil
+}
+
+func hasColumn(tx *sql.Tx, table, name string) (bool, error) {
+	rows, err := tx.Query(fmt.Sprintf("PRAGMA table_info(%s);", table))
+
+	if err != nil {
+		return false, err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var cid int
+		var columnName string
+		var columnType string
+		var notNull int
+		var defaultValue interface{}
+		var primaryKey int
+
+		if err = rows.Scan(&cid, &columnName, &columnType, &notNull, &defaultValue, &primaryKey); err != nil {
+			return false, err
+		}
+
+		if columnName == name {
+			return true, nil
+		}
+	}
+
+	return false, rows.Err()
 }
 
 func getTableCreators(tx *sql.Tx) (map[string]tableCreator, error) {
@@ -86,7 +159,8 @@ func createPeripheralsTable(tx *sql.Tx) error {
 				"key TEXT NOT NULL,"+
 				"name TEXT NOT NULL,"+
 				"kind TEXT NOT NULL,"+
-				"enabled INTEGER NOT NULL"+
+				"enabled INTEGER NOT NULL,"+
+				"metadata TEXT"+
 				");",
 			peripheralTableName,
 		),
@@ -111,7 +185,9 @@ func createEndpointsTable(tx *sql.Tx) error {
 				"name TEXT NOT NULL,"+
 				"url TEXT NOT NULL,"+
 				"method TEXT NOT NULL,"+
-				"headers TEXT"+
+				"headers TEXT,"+
+				"options TEXT,"+
+				"enabled INTEGER NOT NULL DEFAULT 1"+
 				");",
 			endpointTableName,
 		),
@@ -131,6 +207,7 @@ func createSubscribersTable(tx *sql.Tx) error {
 				"name TEXT NOT NULL,"+
 				"event TEXT NOT NULL,"+
 				"enabled INTEGER NOT NULL,"+
+				"priority INTEGER NOT NULL DEFAULT 0,"+
 				"target_id INTEGER REFERENCES %s(id) ON DELETE CASCADE,"+
 				"endpoint_id INTEGER REFERENCES %s(id) ON DELETE CASCADE"+
 				");",
@@ -13,10 +13,10 @@ import (
 )
 
 const (
-	endpointSelectQuery       = "SELECT id, name, url, method, headers FROM %s"
-	endpointInsertQuery       = "INSERT INTO %s (name, url, method, headers) VALUES %s"
-	endpointInsertValuesQuery = "(?, ?, ?, ?)"
-	endpointUpdateQuery       = "UPDATE %s SET name=?, url=?, method=?, headers=? WHERE id=?"
+	endpointSelectQuery       = "SELECT id, name, url, method, headers, options, enabled FROM %s"
+	endpointInsertQuery       = "INSERT INTO %s (name, url, method, headers, options, enabled) VALUES %s"
+	endpointInsertValuesQuery = "(?, ?, ?, ?, ?, ?)"
+	endpointUpdateQuery       = "UPDATE %s SET name=?, url=?, method=?, headers=?, options=?, enabled=? WHERE id=?"
 	endpointDeleteQuery       = "DELETE FROM %s"
 	endpointCountQuery        = "SELECT COUNT(id) from %s"
 )
@@ -158,7 +158,7 @@ func (r *SQLiteEndpointRepository) Create(endpoint *notification.Endpoint, tx *s
 		return 0, storage.TryToRollback(tx, err, closeTx)
 	}
 
-	res, err := stmt.Exec(endpoint.Name, endpoint.Url, endpoint.Method, endpoint.Headers)
+	res, err := stmt.Exec(endpoint.Name, endpoint.Url, endpoint.Method, endpoint.Headers, endpoint.Options, endpoint.IsEnabled())
 
 	if err != nil {
 		return 0, storage.TryToRollback(tx, err, closeTx)
@@ -207,7 +207,7 @@ func (r *SQLiteEndpointRepository) Update(endpoint *notification.Endpoint, tx *s
 		return storage.TryToRollback(tx, err, closeTx)
 	}
 
-	_, err = stmt.Exec(endpoint.Name, endpoint.Url, endpoint.Method, endpoint.Headers, endpoint.Id)
+	_, err = stmt.Exec(endpoint.Name, endpoint.Url, endpoint.Method, endpoint.Headers, endpoint.Options, endpoint.IsEnabled(), endpoint.Id)
 
 	if err != nil {
 		return storage.TryToRollback(tx, err, closeTx)
@@ -11,8 +11,10 @@ func ToEndpoint(row DataRow) (*notification.Endpoint, error) {
 	var url string
 	var method string
 	headers := notification.Headers{}
+	options := notification.EndpointOptions{}
+	var enabled bool
 
-	if err := row.Scan(&id, &name, &url, &method, &headers); err != nil {
+	if err := row.Scan(&id, &name, &url, &method, &headers, &options, &enabled); err != nil {
 		if err == sql.ErrNoRows {
 			return nil, nil
 		}
@@ -26,6 +28,8 @@ func ToEndpoint(row DataRow) (*notification.Endpoint, error) {
 		Url:     url,
 		Method:  method,
 		Headers: headers,
+		Options: options,
+		Enabled: &enabled,
 	}, nil
 }
 
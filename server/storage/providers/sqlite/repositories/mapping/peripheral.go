@@ -23,8 +23,9 @@ func ToPeripheral(row DataRow) (*tracking.Peripheral, error) {
 	var name string
 	var kind string
 	var enabled int
+	var metadata tracking.Metadata
 
-	if err := row.Scan(&id, &key, &name, &kind, &enabled); err != nil {
+	if err := row.Scan(&id, &key, &name, &kind, &enabled, &metadata); err != nil {
 		if err == sql.ErrNoRows {
 			return nil, nil
 		}
@@ -33,11 +34,12 @@ func ToPeripheral(row DataRow) (*tracking.Peripheral, error) {
 	}
 
 	return &tracking.Peripheral{
-		Id:      id,
-		Key:     key,
-		Name:    name,
-		Kind:    kind,
-		Enabled: enabled == 1,
+		Id:       id,
+		Key:      key,
+		Name:     name,
+		Kind:     kind,
+		Enabled:  enabled == 1,
+		Metadata: metadata,
 	}, nil
 }
 
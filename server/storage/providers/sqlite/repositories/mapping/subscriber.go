@@ -11,23 +11,29 @@ func ToSubscriber(row DataRow) (*notification.Subscriber, error) {
 	var name string
 	var event string
 	var enabled uint64
+	var priority uint64
 
 	var endpointId uint64
 	var endpointName string
 	var endpointUrl string
 	var endpointMethod string
 	endpointHeaders := notification.Headers{}
+	endpointOptions := notification.EndpointOptions{}
+	var endpointEnabled bool
 
 	if err := row.Scan(
 		&id,
 		&name,
 		&event,
 		&enabled,
+		&priority,
 		&endpointId,
 		&endpointName,
 		&endpointUrl,
 		&endpointMethod,
 		&endpointHeaders,
+		&endpointOptions,
+		&endpointEnabled,
 	); err != nil {
 		if err == sql.ErrNoRows {
 			return nil, nil
@@ -37,16 +43,19 @@ func ToSubscriber(row DataRow) (*notification.Subscriber, error) {
 	}
 
 	return &notification.Subscriber{
-		Id:      id,
-		Name:    name,
-		Event:   event,
-		Enabled: enabled > 0,
+		Id:       id,
+		Name:     name,
+		Event:    event,
+		Enabled:  enabled > 0,
+		Priority: priority > 0,
 		Endpoint: &notification.Endpoint{
 			Id:      endpointId,
 			Name:    endpointName,
 			Url:     endpointUrl,
 			Method:  endpointMethod,
 			Headers: endpointHeaders,
+			Options: endpointOptions,
+			Enabled: &endpointEnabled,
 		},
 	}, nil
 }
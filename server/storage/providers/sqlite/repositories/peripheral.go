@@ -13,10 +13,10 @@ import (
 )
 
 const (
-	peripheralSelectQuery       = "SELECT id, key, name, kind, enabled FROM %s"
-	peripheralInsertQuery       = "INSERT INTO %s (key, name, kind, enabled) VALUES %s"
-	peripheralInsertValuesQuery = "(?, ?, ?, ?)"
-	peripheralUpdateQuery       = "UPDATE %s SET name=?, enabled=? WHERE id=?"
+	peripheralSelectQuery       = "SELECT id, key, name, kind, enabled, metadata FROM %s"
+	peripheralInsertQuery       = "INSERT INTO %s (key, name, kind, enabled, metadata) VALUES %s"
+	peripheralInsertValuesQuery = "(?, ?, ?, ?, ?)"
+	peripheralUpdateQuery       = "UPDATE %s SET name=?, enabled=?, metadata=? WHERE id=?"
 	peripheralDeleteQuery       = "DELETE FROM %s"
 	peripheralCountQuery        = "SELECT COUNT(id) from %s"
 )
@@ -204,7 +204,7 @@ func (r *SQLitePeripheralRepository) Create(target *tracking.Peripheral, tx *sql
 		return 0, storage.TryToRollback(tx, err, closeTx)
 	}
 
-	res, err := stmt.Exec(target.Key, target.Name, target.Kind, boolToInt(target.Enabled))
+	res, err := stmt.Exec(target.Key, target.Name, target.Kind, boolToInt(target.Enabled), target.Metadata)
 
 	if err != nil {
 		return 0, storage.TryToRollback(tx, err, closeTx)
@@ -251,7 +251,7 @@ func (r *SQLitePeripheralRepository) Update(target *tracking.Peripheral, tx *sql
 		return storage.TryToRollback(tx, err, closeTx)
 	}
 
-	_, err = stmt.Exec(target.Name, boolToInt(target.Enabled), target.Id)
+	_, err = stmt.Exec(target.Name, boolToInt(target.Enabled), target.Metadata, target.Id)
 
 	if err != nil {
 		return storage.TryToRollback(tx, err, closeTx)
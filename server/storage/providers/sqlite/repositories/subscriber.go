@@ -18,16 +18,19 @@ const (
 		"t1.name as t1_name, " +
 		"t1.event as t1_event, " +
 		"t1.enabled as t1_enabled, " +
+		"t1.priority as t1_priority, " +
 		"t2.id AS t2_id, " +
 		"t2.name AS t2_name, " +
 		"t2.url AS t2_url, " +
 		"t2.method AS t2_method, " +
-		"t2.headers AS t2_headers " +
+		"t2.headers AS t2_headers, " +
+		"t2.options AS t2_options, " +
+		"t2.enabled AS t2_enabled " +
 		"FROM %s AS t1 " +
 		"INNER JOIN %s AS t2 ON t1.endpoint_id = t2.id "
-	subscriberInsertQuery       = "INSERT INTO %s (name, event, enabled, endpoint_id, target_id) VALUES %s"
-	subscriberInsertValuesQuery = "(?, ?, ?, ?, ?)"
-	subscriberUpdateQuery       = "UPDATE %s SET name=?, event=?, enabled=? WHERE id=?"
+	subscriberInsertQuery       = "INSERT INTO %s (name, event, enabled, priority, endpoint_id, target_id) VALUES %s"
+	subscriberInsertValuesQuery = "(?, ?, ?, ?, ?, ?)"
+	subscriberUpdateQuery       = "UPDATE %s SET name=?, event=?, enabled=?, priority=? WHERE id=?"
 	subscriberDeleteQuery       = "DELETE FROM %s"
 	subscriberCountQuery        = "SELECT COUNT(id) FROM %s"
 )
@@ -176,6 +179,7 @@ func (r *SQLiteSubscriberRepository) Create(subscriber *notification.Subscriber,
 		subscriber.Name,
 		subscriber.Event,
 		boolToInt(subscriber.Enabled),
+		boolToInt(subscriber.Priority),
 		subscriber.Endpoint.Id,
 		targetId,
 	)
@@ -206,7 +210,7 @@ func (r *SQLiteSubscriberRepository) CreateMany(subscribers []*notification.Subs
 
 	var err error
 	valueStrings := make([]string, 0, len(subscribers))
-	valueArgs := make([]interface{}, 0, len(subscribers)*5)
+	valueArgs := make([]interface{}, 0, len(subscribers)*6)
 
 	for _, subscriber := range subscribers {
 		err := r.validate(subscriber, true)
@@ -215,13 +219,14 @@ func (r *SQLiteSubscriberRepository) CreateMany(subscribers []*notification.Subs
 			break
 		}
 
-		// name, event, enabled, endpoint_id, target_id
+		// name, event, enabled, priority, endpoint_id, target_id
 		valueStrings = append(valueStrings, subscriberInsertValuesQuery)
 		valueArgs = append(
 			valueArgs,
 			subscriber.Name,
 			subscriber.Event,
 			boolToInt(subscriber.Enabled),
+			boolToInt(subscriber.Priority),
 			subscriber.Endpoint.Id,
 			targetId,
 		)
@@ -430,7 +435,13 @@ func (r *SQLiteSubscriberRepository) DeleteMany(query *storage.DeletionQuery, tx
 }
 
 func (r *SQLiteSubscriberRepository) doUpdate(stmt *sql.Stmt, subscriber *notification.Subscriber) error {
-	_, err := stmt.Exec(subscriber.Name, subscriber.Event, boolToInt(subscriber.Enabled), subscriber.Id)
+	_, err := stmt.Exec(
+		subscriber.Name,
+		subscriber.Event,
+		boolToInt(subscriber.Enabled),
+		boolToInt(subscriber.Priority),
+		subscriber.Id,
+	)
 
 	return err
 }